@@ -1,36 +1,800 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"scramTrimmer/trimmer"
 )
 
 var (
-	inputFile  = flag.String("i", "", "Input file (required)")
-	outputFile = flag.String("o", "", "Output file (required)")
-	adapter    = flag.String("a", "", "Adapter sequence (required)")
-	minLen     = flag.Int("minLen", 18, "Minimum length of read")
-	trim5      = flag.Int("trim5", 0, "5' trim length")
-	trim3      = flag.Int("trim3", 0, "3' trim length")
-	min5Match  = flag.Int("min5Match", 8, "Minimum match length at 5' end")
-	maxError   = flag.Float64("maxError", 0.1, "Maximum mean error rate")
+	inputFile     = listFlag("i", "Input FASTQ or BAM `file`, or comma-separated files or globs to trim separately into the -o directory (or together with -combineInputs); may be repeated; - or omitted reads stdin")
+	outputFile    = flag.String("o", "", "Output file, or comma-separated files/FIFOs to fan out to; - or omitted writes stdout")
+	adapter       = listFlag("a", "Adapter `sequence`, or comma-separated sequences to cut at whichever is found first; may be repeated (required unless -adapterPFM is given)")
+	minLen        = flag.Int("minLen", 18, "Minimum length of read")
+	trim5         = flag.Int("trim5", 0, "5' trim length")
+	trim3         = flag.String("trim3", "0", "3' trim length, or a comma-separated length for each -a adapter")
+	min5Match     = flag.Int("min5Match", 8, "Minimum match length at 5' end")
+	maxError      = flag.Float64("maxError", 0.1, "Maximum mean error rate (<= 0 disables the quality filter)")
+	reverse       = flag.Bool("reverseInput", false, "Reverse sequence and quality of each read before trimming")
+	headerLen     = flag.Bool("headerLen", false, "Append the trimmed length to each output header")
+	noQual        = flag.Bool("noQualFilter", false, "Disable the mean error quality filter")
+	rsyncable     = flag.Bool("rsyncable", false, "Write rsync-friendly gzip output")
+	skipFailed    = flag.Bool("skipFailedOutputs", false, "Drop an output that stops accepting writes instead of aborting")
+	indelRef      = flag.Int("indelRefine", 0, "Refine the adapter boundary by aligning the full adapter, allowing up to this many indels")
+	statsEvery    = flag.Duration("statsInterval", 0, "Print a snapshot of the counters to stderr at this interval, e.g. 30s (0 disables)")
+	seedFrac      = flag.Float64("min5MatchFrac", 0, "Seed length as a fraction (0-1] of the adapter length; alternative to -min5Match")
+	nWildcard     = flag.Bool("nWildcard", false, "Treat N in the read as matching any adapter base")
+	inQual        = flag.Int("inQualBase", 33, "Quality offset of the input (33 or 64)")
+	outQual       = flag.Int("outQualBase", 33, "Quality offset to write the output with (33 or 64)")
+	insertPct     = flag.Bool("insertPercentiles", false, "Report approximate p25/p50/p75/p90 insert sizes using a streaming estimator")
+	verifyOut     = flag.Bool("verifyOutput", false, "Re-read the output after writing and check every record is valid")
+	seed2         = flag.String("seed2", "", "Second adapter seed that must match -seed2Gap bases after the first seed")
+	seed2Gap      = flag.Int("seed2Gap", 0, "Bases between the end of the first seed and the start of -seed2")
+	traceFrac     = flag.Float64("traceFraction", 0, "Fraction (0-1) of reads to write a per-read processing trace for")
+	traceFile     = flag.String("traceFile", "", "TSV file for per-read traces (used with -traceFraction)")
+	adapterPFM    = flag.String("adapterPFM", "", "Position frequency matrix file describing the adapter")
+	pfmScore      = flag.Float64("pfmMinScore", 0, "Minimum log2-odds score for a -adapterPFM match (<= 0 uses 80% of the maximum)")
+	touchOut      = flag.Bool("touchOutput", false, "Always write a valid gzip output, even when the input is empty (now the default; kept for existing scripts)")
+	fileConc      = flag.Int("fileParallelism", 1, "Number of input files to process at once when several are given")
+	kmerIdx       = flag.Bool("kmerIndex", false, "Locate the adapter seed with a precomputed k-mer index")
+	keepOrig      = flag.Bool("keepOriginal", false, "Also write each kept read untrimmed, its ID suffixed with :orig")
+	trimSpace     = flag.Bool("trimTrailingSpace", false, "Strip trailing spaces from sequence and quality lines before the length check")
+	contamProf    = flag.String("contaminationProfile", "", "Write the per-position cumulative fraction of reads with the adapter started to this TSV")
+	countSide     = flag.Bool("countSidecar", false, "Write the number of output records to <output>.count")
+	noInsert      = flag.Bool("noInsert", false, "Count reads whose adapter starts within the 5' trim as \"no insert\" instead of \"too short\"")
+	preferHit     = flag.String("preferMatch", trimmer.PreferEarliest, "Which adapter hit to trim at when several qualify: earliest, or latest among those with the fewest mismatches")
+	maxProcMs     = flag.Int("maxReadProcMs", 0, "Skip a read, counting it as timed out, if locating its adapter takes longer than this many milliseconds (0 disables)")
+	softTrim      = flag.Bool("softTrim", false, "Keep a read untrimmed instead of dropping it when trimming would leave fewer than -minLen bases")
+	emitCmd       = flag.String("emitCommand", "", "Print the full command, defaults included, that reproduces this run; a value other than - also saves it to that file")
+	funnel        = flag.Bool("funnel", false, "Print how many reads survive each filter stage, in order")
+	crlf          = flag.Bool("crlf", false, "End output lines with CRLF (\\r\\n) instead of LF")
+	opticalDup    = flag.Bool("opticalDup", false, "Estimate the optical duplicate rate from the tile coordinates in Illumina headers")
+	opticalPx     = flag.Int("opticalDupDist", 100, "Pixel distance within which identical reads on a tile count as optical duplicates")
+	annotate      = flag.String("annotateAll", "", "Write every read, trimmed if kept, with a fate=<kept|adapter-missing|too-short|...> header tag to this gzipped FASTQ")
+	qualDist      = flag.String("qualityDist", "", "Write the number of output bases at each Phred score to this TSV")
+	autoMaxErr    = flag.Int("autoMaxError", 0, "Set -maxError from the insert mean errors of this many leading reads (0 disables)")
+	autoErrPct    = flag.Float64("autoMaxErrorPct", 95, "Percentile (0-100] of calibration mean errors to use as -maxError with -autoMaxError")
+	shortOut      = flag.String("tooShortOutput", "", "Write reads dropped as too short, with the adapter already trimmed, to this gzipped FASTQ")
+	parquetOut    = flag.String("parquet", "", "Write header, lengths, adapter position, mean error and fate of every read to this Parquet file")
+	input2        = flag.String("i2", "", "R2 FASTQ whose mates pair with -i, for -merge or paired trimming with -o2")
+	mergePairs    = flag.Bool("merge", false, "Merge overlapping R1/R2 mates (-i/-i2) into one consensus read before trimming")
+	mergeOvl      = flag.Int("mergeMinOverlap", 10, "Minimum overlap for -merge to join two mates")
+	dedupHdrs     = flag.String("dedupHeaders", "", "Check for repeated read IDs and error, warn or drop the repeats")
+	dedupWin      = flag.Int("dedupWindow", 0, "Only compare each read ID with this many preceding ones for -dedupHeaders (0 remembers every ID)")
+	wobblePos     = flag.String("wobblePos", "", "Comma-separated 1-based positions in the adapter seed that may mismatch freely")
+	statsBin      = flag.String("statsBinary", "", "Write the counters and histograms to this file as a versioned gob blob for aggregation")
+	hpMatch       = flag.Bool("hpCompressMatch", false, "Search for the adapter with homopolymer runs in read and adapter collapsed, tolerating run-length errors")
+	diffPrev      = flag.String("diffAgainst", "", "Compare the output with this previous gzipped output and report added/removed read IDs")
+	diffSeq       = flag.Bool("diffSequence", false, "With -diffAgainst, also count reads whose trimmed sequence changed")
+	diffReport    = flag.String("diffReport", "", "With -diffAgainst, write each differing read ID to this file, marked +, - or ~")
+	decompCmd     = flag.String("decompressCmd", "", "Decompress the input by piping it through this command, e.g. \"xz -dc\", instead of gzip")
+	minAdScore    = flag.Int("minAdapterScore", 0, "Accept the adapter wherever it aligns with at least this score (+1 match, -1 mismatch/gap), without a seed match (0 disables)")
+	gzBlockSize   = flag.Int("gzBlockSize", 0, "Gzip output block size in bytes for parallel compression (0 = pgzip default of 1 MiB)")
+	gzBlocks      = flag.Int("gzBlocks", 0, "Gzip output blocks compressed in parallel (0 = one per CPU)")
+	gzipLevel     = flag.Int("gzipLevel", 0, "Gzip output compression level, 1 (fastest) to 9 (smallest), or -2 for Huffman-only (0 = default)")
+	barcodeFile   = flag.String("barcodeAdapters", "", "File mapping header barcodes to adapters, one \"BARCODE ADAPTER\" pair per line")
+	splitByAd     = flag.String("splitByAdapter", "", "Write reads with no adapter found, untrimmed, to this gzipped FASTQ")
+	infoFile      = flag.String("infoFile", "", "Write a cutadapt-compatible --info-file line for every read to this file")
+	minGC         = flag.Float64("minGC", 0, "Drop trimmed reads with a lower GC percentage")
+	maxGC         = flag.Float64("maxGC", 100, "Drop trimmed reads with a higher GC percentage")
+	twoPass       = flag.Bool("twoPass", false, "Survey the input and print recommendations before trimming it")
+	umiDedup      = flag.Bool("umiDedup", false, "Keep only the best-quality read for each UMI and trimmed sequence")
+	constQual     = flag.String("constQual", "", "Write every output quality as this single character, e.g. I (filters still use the real scores)")
+	plainOut      = flag.Bool("z", false, "Write uncompressed FASTQ output instead of gzip")
+	noCompress    = flag.Bool("no-compress", false, "Same as -z")
+	lenPrior      = flag.String("lengthPrior", "", "Expected insert lengths, e.g. \"21,24:2\", used to choose between several adapter positions")
+	insertEndBed  = flag.String("insertEndBed", "", "Write a bedGraph of where kept inserts end on the reference, from pos=CHROM:POS[:STRAND] header fields")
+	maxAdMismatch = flag.Int("maxAdapterMismatch", 0, "Mismatched bases allowed when matching the adapter seed")
+	collapse      = flag.Bool("collapse", false, "Write each distinct trimmed sequence once as FASTA, >seq_NNNNN_count_N, instead of the reads")
+	collapseMax   = flag.Int("collapseMaxUnique", 0, "With -collapse, spill counts to temporary files past this many distinct sequences (0 keeps all in memory)")
+	collapseTmp   = flag.String("collapseTmpDir", "", "Directory for -collapseMaxUnique spill files (default the system temporary directory)")
+	labelFile     = flag.String("labelFile", "", "Write one fate byte per read, in input order, to this file; see the README for the encoding")
+	output2       = flag.String("o2", "", "Output for the R2 mates in -i2; trims -i and -i2 as pairs, keeping a pair only if both mates pass")
+	adapter2      = flag.String("a2", "", "Adapter sequence for the R2 mates with -o2 (default the first -a adapter)")
+	keepSingle    = flag.Bool("keep-singletons", false, "With -o2, write a mate that passes when its partner is dropped to a .singletons file beside -o or -o2")
+	maskCyc       = flag.String("maskCycles", "", "Comma-separated 1-based read cycles, e.g. known dark cycles, that match any adapter base in the seed search")
+	bgzfOut       = flag.Bool("bgzf", false, "Write BGZF output, the blocked gzip htslib tools can seek in")
+	gziOut        = flag.Bool("gzi", false, "With -bgzf, also write a <output>.gzi index of the BGZF blocks")
+	discarded     = flag.String("discarded", "", "Write every rejected read, untrimmed, with a reason:<why> header tag to this gzipped FASTQ")
+	jsonReport    = flag.String("jsonReport", "", "Write the end-of-run counts, trimmed percentage and duration as JSON to this file")
+	quiet         = flag.Bool("quiet", false, "Do not print the text summary")
+	benchThr      = flag.String("benchThreads", "", "Instead of trimming, time the first -benchReads reads at each of these comma-separated thread counts and print a scaling table")
+	benchReads    = flag.Int("benchReads", 100000, "Reads in the -benchThreads sample")
+	truncateTo    = flag.Int("truncateTo", 0, "Cut kept reads longer than this to their first N bases, after adapter trimming (0 disables)")
+	lenHist       = flag.String("lengthHist", "", "Write the number of output reads of each length to this TSV (length, count)")
+	splitByMode   = flag.Bool("splitByMode", false, "Also report the counters separately for inserts up to and longer than the most common insert length")
+	minPartial3   = flag.Int("minPartial3", 0, "When no seed is found, trim a read suffix of at least this many bases that matches the start of the adapter (0 disables)")
+	readRanges    = flag.Int("readRanges", 0, "Parse an uncompressed input file as this many byte ranges in parallel (0 or 1 reads it in one stream)")
+	qualBase      = flag.String("qualBase", "", "Quality offset of the input: 33, 64, or auto to guess it from the first reads; same as -inQualBase")
+	qualCutoff    = flag.Int("qualCutoff", 0, "Before adapter trimming, trim 3' bases while the mean quality of a sliding window is below this Phred score (0 disables)")
+	qualWindow    = flag.Int("qualWindow", trimmer.DefaultQualWindow, "Window size in bases for -qualCutoff")
+	progressEvery = flag.Duration("progressInterval", 5*time.Second, "Print the reads processed so far and reads per second to stderr at this interval (0 disables)")
+	batchSize     = flag.Int("batchSize", trimmer.DefaultBatchSize, "Number of reads handed to a worker at a time")
+	workers       = flag.Int("workers", runtime.NumCPU(), "Number of batches trimmed at once")
+	ordered       = flag.Bool("ordered", false, "Write kept reads in input order rather than as batches finish")
+	maxLineLen    = flag.Int("maxLineLen", trimmer.DefaultMaxLineLen, "Longest FASTQ line to read, in bytes; a longer line stops the run with an error")
+	adapter5      = flag.String("g", "", "5' adapter `sequence` to remove, with anything before it, from the start of reads before the 3' adapter search")
+	adapter5Off   = flag.Int("gMaxOffset", 3, "How many bases further into the read than a complete -g adapter at its start the adapter may end")
+	umi5          = flag.Int("umi5", 0, "Move this many bases from the start of each read into its header as a UMI:... field (0 disables)")
+	umi3          = flag.Int("umi3", 0, "Move this many bases just before the 3' adapter into each read's header as a UMI:... field (0 disables)")
+	failEmpty     = flag.Bool("failOnEmpty", false, "Exit with an error when the input holds no reads")
+	polyTrim      = flag.String("polyTrim", "", "Cut a 3' poly-A or poly-G tail left after adapter trimming: A, G or AG")
+	polyMin       = flag.Int("polyMin", trimmer.DefaultPolyMin, "Shortest homopolymer run -polyTrim cuts")
+	iupac         = flag.Bool("iupac", false, "Let ambiguous IUPAC codes in the adapter, such as N or R, match any read base they stand for")
+	maxN          = flag.Float64("maxN", 0, "Drop trimmed reads with more N bases than this count, or this fraction of their length if below 1 (0 disables)")
+	minBaseQual   = flag.Int("minBaseQual", 0, "Drop trimmed reads with any base below this Phred score (0 disables)")
+	adapterErr    = flag.Float64("adapterErrorRate", 0, "Match the whole adapter overlap instead of the -min5Match seed, allowing this fraction of it to mismatch, e.g. 0.1 (0 disables)")
+	minOverlap    = flag.Int("minOverlap", 0, "Bases of adapter a seed hit must go on matching for in all before the read is trimmed there (0 disables)")
+	maxLen        = flag.Int("maxLen", 0, "Maximum length of read after trimming; longer reads are dropped (0 = no limit)")
+	qcReport      = flag.String("qcReport", "", "Write the base composition and mean error at each position of the kept reads to this TSV")
+	combineIn     = flag.Bool("combineInputs", false, "Trim several -i inputs as one, into the single -o output, instead of each into the -o directory")
 )
 
+// commaList is a string flag that may be given more than once, each value
+// being appended to the earlier ones after a comma.
+type commaList string
+
+func (c *commaList) String() string { return string(*c) }
+
+func (c *commaList) Set(v string) error {
+	if *c != "" {
+		v = string(*c) + "," + v
+	}
+	*c = commaList(v)
+	return nil
+}
+
+// listFlag defines a commaList flag and returns its value as a string.
+func listFlag(name, usage string) *string {
+	var s string
+	flag.Var((*commaList)(&s), name, usage)
+	return &s
+}
+
+// expandGlobs replaces each pattern holding a glob metacharacter with the
+// files it matches, in order; other paths are kept as they are.
+func expandGlobs(paths []string) ([]string, error) {
+	var expanded []string
+	for _, p := range paths {
+		if !strings.ContainsAny(p, "*?[") {
+			expanded = append(expanded, p)
+			continue
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", p)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// parseIntList parses a comma-separated list of integers.
+func parseIntList(s string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 func main() {
 	flag.Parse()
 
-	if *inputFile == "" || *outputFile == "" || *adapter == "" {
+	var pfm *trimmer.PositionMatrix
+	if *adapterPFM != "" {
+		var err error
+		if pfm, err = trimmer.LoadPFM(*adapterPFM); err != nil {
+			log.Fatalf("Error loading adapter PFM: %v", err)
+		}
+		if *adapter == "" {
+			*adapter = pfm.Consensus()
+		}
+		if *pfmScore <= 0 {
+			*pfmScore = 0.8 * pfm.MaxScore()
+		}
+	}
+
+	var barcodeAdapters map[string]string
+	if *barcodeFile != "" {
+		if *adapterPFM != "" {
+			log.Fatalf("-barcodeAdapters cannot be combined with -adapterPFM")
+		}
+		var err error
+		if barcodeAdapters, err = trimmer.LoadBarcodeAdapters(*barcodeFile); err != nil {
+			log.Fatalf("Error loading -barcodeAdapters: %v", err)
+		}
+		if *adapter == "" {
+			// The seed length is checked against -a, so stand in the
+			// shortest listed adapter.
+			for _, a := range barcodeAdapters {
+				if *adapter == "" || len(a) < len(*adapter) {
+					*adapter = a
+				}
+			}
+		}
+	}
+
+	// Positional arguments stand in for -i and -o, so that
+	// "scramTrimmer -a ADAPTER - -" works in a pipeline.
+	args := flag.Args()
+	if len(args) > 2 {
+		log.Fatalf("Expected at most an input and an output file, got %d arguments", len(args))
+	}
+	for i, name := range []string{"i", "o"}[:len(args)] {
+		if flagSet(name) {
+			log.Fatalf("-%s and a positional argument cannot both name the file", name)
+		}
+		flag.Set(name, args[i])
+	}
+	if *inputFile == "" {
+		*inputFile = trimmer.StdioPath
+	} else {
+		inputs, err := expandGlobs(strings.Split(*inputFile, ","))
+		if err != nil {
+			log.Fatalf("Invalid -i: %v", err)
+		}
+		*inputFile = strings.Join(inputs, ",")
+	}
+	if *outputFile == "" {
+		*outputFile = trimmer.StdioPath
+	}
+	toStdout := *outputFile == trimmer.StdioPath
+
+	if *adapter == "" {
 		fmt.Println("Missing required arguments")
 		flag.Usage()
 		return
 	}
 
-	err := ProcessReadsFast(*inputFile, *outputFile, *adapter, *minLen, *trim5, *trim3, *min5Match, *maxError)
+	adapters := strings.Split(*adapter, ",")
+	for i, a := range adapters {
+		a, err := trimmer.NormalizeAdapter(a)
+		if err != nil {
+			log.Fatalf("Invalid -a: %v", err)
+		}
+		adapters[i] = a
+	}
+	*adapter = strings.Join(adapters, ",")
 
+	if flagSet("min5MatchFrac") {
+		if flagSet("min5Match") {
+			log.Fatalf("-min5Match and -min5MatchFrac are mutually exclusive")
+		}
+		seed, err := trimmer.SeedLengthFromFraction(adapters[0], *seedFrac)
+		if err != nil {
+			log.Fatalf("Invalid -min5MatchFrac: %v", err)
+		}
+		*min5Match = seed
+	}
+
+	for _, a := range adapters {
+		if err := trimmer.CheckSeedLength(a, *min5Match); err != nil {
+			log.Fatalf("Invalid -a %q: %v", a, err)
+		}
+	}
+	for barcode, a := range barcodeAdapters {
+		if err := trimmer.CheckSeedLength(a, *min5Match); err != nil {
+			log.Fatalf("Invalid adapter for barcode %s: %v", barcode, err)
+		}
+	}
+	if *maxAdMismatch < 0 || *maxAdMismatch >= *min5Match {
+		log.Fatalf("-maxAdapterMismatch must be between 0 and %d, one less than the seed length, got %d", *min5Match-1, *maxAdMismatch)
+	}
+	if *adapterErr < 0 || *adapterErr >= 1 {
+		log.Fatalf("-adapterErrorRate must be at least 0 and below 1, got %g", *adapterErr)
+	}
+	if *adapterErr > 0 && (*maxAdMismatch > 0 || *minAdScore > 0 || *adapterPFM != "") {
+		log.Fatalf("-adapterErrorRate cannot be combined with -maxAdapterMismatch, -minAdapterScore or -adapterPFM")
+	}
+	if len(adapters) > 1 && barcodeAdapters != nil {
+		log.Fatalf("-barcodeAdapters cannot be combined with several -a adapters")
+	}
+	if len(adapters) > 1 && pfm != nil {
+		log.Fatalf("-adapterPFM cannot be combined with several -a adapters")
+	}
+	trim3s, err := parseIntList(*trim3)
 	if err != nil {
-		log.Fatalf("Error processing reads: %v", err)
+		log.Fatalf("Invalid -trim3: %v", err)
+	}
+	var wobble []int
+	if *wobblePos != "" {
+		if wobble, err = parseIntList(*wobblePos); err != nil {
+			log.Fatalf("Invalid -wobblePos: %v", err)
+		}
+		for _, pos := range wobble {
+			if pos < 1 || pos > *min5Match {
+				log.Fatalf("-wobblePos %d is outside the %d base seed", pos, *min5Match)
+			}
+		}
+	}
+	var masked []int
+	if *maskCyc != "" {
+		if masked, err = parseIntList(*maskCyc); err != nil {
+			log.Fatalf("Invalid -maskCycles: %v", err)
+		}
+		for _, cycle := range masked {
+			if cycle < 1 {
+				log.Fatalf("-maskCycles positions start at 1, got %d", cycle)
+			}
+		}
+		if *hpMatch {
+			log.Fatalf("-maskCycles cannot be combined with -hpCompressMatch")
+		}
+	}
+	var adapterTrim3 []int
+	if len(trim3s) > 1 {
+		if len(trim3s) != len(adapters) {
+			log.Fatalf("-trim3 lists %d lengths for %d adapters", len(trim3s), len(adapters))
+		}
+		adapterTrim3 = trim3s
+	}
+
+	var autoQualReads int
+	switch *qualBase {
+	case "":
+	case "auto":
+		autoQualReads = trimmer.QualBaseSampleReads
+	case "33", "64":
+		base, _ := strconv.Atoi(*qualBase)
+		if flagSet("inQualBase") && base != *inQual {
+			log.Fatalf("-qualBase %s contradicts -inQualBase %d", *qualBase, *inQual)
+		}
+		*inQual = base
+	default:
+		log.Fatalf("-qualBase must be 33, 64 or auto, got %q", *qualBase)
+	}
+	if autoQualReads > 0 && flagSet("inQualBase") {
+		log.Fatalf("-qualBase auto cannot be combined with -inQualBase")
+	}
+	if autoQualReads > 0 && (*output2 != "" || *readRanges > 1) {
+		log.Fatalf("-qualBase auto cannot be combined with -o2 or -readRanges")
+	}
+	for name, base := range map[string]int{"inQualBase": *inQual, "outQualBase": *outQual} {
+		if base != 33 && base != 64 {
+			log.Fatalf("-%s must be 33 or 64, got %d", name, base)
+		}
+	}
+
+	if *preferHit != trimmer.PreferEarliest && *preferHit != trimmer.PreferLatest {
+		log.Fatalf("-preferMatch must be %q or %q, got %q", trimmer.PreferEarliest, trimmer.PreferLatest, *preferHit)
+	}
+
+	switch *dedupHdrs {
+	case "", trimmer.DedupError, trimmer.DedupWarn, trimmer.DedupDrop:
+	default:
+		log.Fatalf("-dedupHeaders must be %q, %q or %q, got %q", trimmer.DedupError, trimmer.DedupWarn, trimmer.DedupDrop, *dedupHdrs)
+	}
+
+	if *hpMatch && (*seed2 != "" || *adapterPFM != "" || *wobblePos != "") {
+		log.Fatalf("-hpCompressMatch cannot be combined with -seed2, -adapterPFM or -wobblePos")
+	}
+
+	if *mergePairs && *output2 != "" {
+		log.Fatalf("-merge joins the mates into one output, so it cannot be combined with -o2")
+	}
+	if (*mergePairs || *output2 != "") != (*input2 != "") {
+		log.Fatalf("-i2 must be given with either -merge or -o2")
+	}
+	if (*adapter2 != "" || *keepSingle) && *output2 == "" {
+		log.Fatalf("-a2 and --keep-singletons only apply to paired trimming with -o2")
+	}
+	if *adapter5 != "" {
+		a, err := trimmer.NormalizeAdapter(*adapter5)
+		if err != nil {
+			log.Fatalf("Invalid -g: %v", err)
+		}
+		*adapter5 = a
+	}
+	if *adapter5Off < 0 {
+		log.Fatalf("-gMaxOffset must not be negative, got %d", *adapter5Off)
+	}
+	if *adapter2 != "" {
+		a, err := trimmer.NormalizeAdapter(*adapter2)
+		if err != nil {
+			log.Fatalf("Invalid -a2: %v", err)
+		}
+		if err := trimmer.CheckSeedLength(a, *min5Match); err != nil {
+			log.Fatalf("Invalid -a2 %q: %v", a, err)
+		}
+		*adapter2 = a
+	}
+	// -minOverlap counts adapter bases, so no adapter it applies to may be
+	// shorter.
+	checkMinOverlap := func(a, what string) {
+		if *minOverlap < 0 || *minOverlap > len(a) {
+			log.Fatalf("-minOverlap must be between 0 and the %s length %d, got %d", what, len(a), *minOverlap)
+		}
+	}
+	for _, a := range adapters {
+		checkMinOverlap(a, "adapter")
+	}
+	for barcode, a := range barcodeAdapters {
+		checkMinOverlap(a, "barcode "+barcode+" adapter")
+	}
+	if *adapter2 != "" {
+		checkMinOverlap(*adapter2, "-a2 adapter")
+	}
+	if *adapter5 != "" {
+		checkMinOverlap(*adapter5, "-g adapter")
+	}
+	if *output2 != "" {
+		for _, path := range []string{*inputFile, *outputFile, *input2, *output2} {
+			if path == trimmer.StdioPath || path == "" || strings.Contains(path, ",") {
+				log.Fatalf("Paired trimming with -o2 needs one named file each for -i, -o, -i2 and -o2")
+			}
+		}
+		for _, name := range []string{
+			"twoPass", "umiDedup", "collapse", "labelFile", "discarded", "infoFile", "splitByAdapter",
+			"tooShortOutput", "annotateAll", "parquet", "traceFraction", "contaminationProfile", "qcReport",
+			"insertEndBed", "barcodeAdapters", "keepOriginal", "verifyOutput", "countSidecar",
+			"diffAgainst", "dedupHeaders", "autoMaxError", "opticalDup", "decompressCmd", "gzi", "ordered",
+		} {
+			if flagSet(name) {
+				log.Fatalf("-%s is not supported with paired trimming (-o2)", name)
+			}
+		}
+	}
+
+	if *autoMaxErr > 0 {
+		if *noQual {
+			log.Fatalf("-autoMaxError cannot be combined with -noQualFilter")
+		}
+		if *autoErrPct <= 0 || *autoErrPct > 100 {
+			log.Fatalf("-autoMaxErrorPct must be in (0, 100], got %g", *autoErrPct)
+		}
+	}
+
+	if *minGC < 0 || *maxGC > 100 || *minGC > *maxGC {
+		log.Fatalf("-minGC and -maxGC must satisfy 0 <= minGC <= maxGC <= 100, got %g and %g", *minGC, *maxGC)
+	}
+
+	var lengthPrior []trimmer.LengthPeak
+	if *lenPrior != "" {
+		var err error
+		if lengthPrior, err = trimmer.ParseLengthPrior(*lenPrior); err != nil {
+			log.Fatalf("Invalid -lengthPrior: %v", err)
+		}
+	}
+
+	var constQualChar byte
+	if *constQual != "" {
+		if len(*constQual) != 1 || (*constQual)[0] < '!' || (*constQual)[0] > '~' {
+			log.Fatalf("-constQual must be a single quality character from ! to ~, got %q", *constQual)
+		}
+		constQualChar = (*constQual)[0]
+	}
+
+	if err := trimmer.CheckGzConcurrency(*gzBlockSize, *gzBlocks); err != nil {
+		log.Fatalf("Invalid -gzBlockSize/-gzBlocks: %v", err)
+	}
+	if err := trimmer.CheckGzipLevel(*gzipLevel); err != nil {
+		log.Fatalf("Invalid -gzipLevel: %v", err)
+	}
+	if (*plainOut || *noCompress) && (*rsyncable || *gzBlockSize != 0 || *gzBlocks != 0 || *gzipLevel != 0) {
+		log.Fatalf("-z writes uncompressed output, so -rsyncable, -gzBlockSize, -gzBlocks and -gzipLevel do not apply")
+	}
+	if *rsyncable && (*gzBlockSize != 0 || *gzBlocks != 0) {
+		log.Fatalf("-gzBlockSize and -gzBlocks do not apply to -rsyncable output")
+	}
+	if *bgzfOut && (*plainOut || *noCompress || *rsyncable || *gzBlockSize != 0 || *gzBlocks != 0) {
+		log.Fatalf("-bgzf cannot be combined with -z, -rsyncable, -gzBlockSize or -gzBlocks")
+	}
+	if *gziOut && !*bgzfOut {
+		log.Fatalf("-gzi indexes BGZF output, so it needs -bgzf")
+	}
+	if *gziOut && toStdout {
+		log.Fatalf("-gzi needs an output file, not stdout")
+	}
+
+	if *collapse && (*verifyOut || *countSide || *keepOrig || *diffPrev != "") {
+		log.Fatalf("-collapse writes FASTA counts, so it cannot be combined with -verifyOutput, -countSidecar, -keepOriginal or -diffAgainst")
+	}
+	if *minPartial3 < 0 || *minPartial3 >= *min5Match {
+		log.Fatalf("-minPartial3 must be between 0 and %d, one less than the seed length, got %d", *min5Match-1, *minPartial3)
+	}
+	if *readRanges > 1 {
+		for _, name := range []string{"merge", "dedupHeaders", "autoMaxError", "opticalDup", "labelFile", "decompressCmd", "ordered"} {
+			if flagSet(name) {
+				log.Fatalf("-readRanges cannot be combined with -%s", name)
+			}
+		}
+	}
+	if *truncateTo < 0 || (*truncateTo > 0 && *truncateTo < *minLen) {
+		log.Fatalf("-truncateTo must be 0 or at least -minLen (%d), got %d", *minLen, *truncateTo)
+	}
+	if *maxLineLen < 1 {
+		log.Fatalf("-maxLineLen must be at least 1, got %d", *maxLineLen)
+	}
+	if *batchSize < 1 {
+		log.Fatalf("-batchSize must be at least 1, got %d", *batchSize)
+	}
+	if *workers < 1 {
+		log.Fatalf("-workers must be at least 1, got %d", *workers)
+	}
+	if *umi5 < 0 || *umi3 < 0 {
+		log.Fatalf("-umi5 and -umi3 must not be negative, got %d and %d", *umi5, *umi3)
+	}
+	switch *polyTrim {
+	case "", "A", "G", "AG", "GA":
+	default:
+		log.Fatalf("-polyTrim must be A, G or AG, got %q", *polyTrim)
+	}
+	if *maxLen < 0 || (*maxLen > 0 && *maxLen < *minLen) {
+		log.Fatalf("-maxLen must be 0 or at least -minLen (%d), got %d", *minLen, *maxLen)
+	}
+	if *minBaseQual < 0 {
+		log.Fatalf("-minBaseQual must not be negative, got %d", *minBaseQual)
+	}
+	if *maxN < 0 || (*maxN > 1 && *maxN != float64(int(*maxN))) {
+		log.Fatalf("-maxN must be a whole number of bases or a fraction below 1, got %g", *maxN)
+	}
+	if *polyMin < 1 {
+		log.Fatalf("-polyMin must be at least 1, got %d", *polyMin)
+	}
+	if *qualCutoff < 0 {
+		log.Fatalf("-qualCutoff must not be negative, got %d", *qualCutoff)
+	}
+	if *qualWindow < 1 {
+		log.Fatalf("-qualWindow must be at least 1, got %d", *qualWindow)
+	}
+	if *collapseMax < 0 {
+		log.Fatalf("-collapseMaxUnique must not be negative, got %d", *collapseMax)
+	}
+
+	if toStdout && (*verifyOut || *countSide || *diffPrev != "") {
+		log.Fatalf("-verifyOutput, -countSidecar and -diffAgainst need an output file, not stdout")
+	}
+	if *inputFile == trimmer.StdioPath && *twoPass {
+		log.Fatalf("-twoPass reads the input twice, so it cannot read stdin")
+	}
+
+	if (*traceFrac > 0) != (*traceFile != "") {
+		log.Fatalf("-traceFraction and -traceFile must be given together")
+	}
+
+	if *emitCmd != "" {
+		// -min5MatchFrac has been folded into -min5Match by now.
+		cmd := trimmer.ReproduceCommand(os.Args[0], flag.CommandLine, "emitCommand", "min5MatchFrac")
+		fmt.Fprintln(trimmer.SummaryWriter(*outputFile), cmd)
+		if *emitCmd != "-" {
+			if err := os.WriteFile(*emitCmd, []byte(cmd+"\n"), 0644); err != nil {
+				log.Fatalf("Error writing -emitCommand file: %v", err)
+			}
+		}
+	}
+
+	opts := trimmer.Options{
+		Adapter:              adapters[0],
+		MoreAdapters:         adapters[1:],
+		AdapterTrim3:         adapterTrim3,
+		MinLen:               *minLen,
+		Trim5:                *trim5,
+		Trim3:                trim3s[0],
+		Min5Match:            *min5Match,
+		MaxError:             *maxError,
+		ReverseInput:         *reverse,
+		HeaderLen:            *headerLen,
+		NoQualFilter:         *noQual,
+		Rsyncable:            *rsyncable,
+		SkipFailedOutputs:    *skipFailed,
+		IndelRefine:          *indelRef,
+		StatsInterval:        *statsEvery,
+		DetectNoInsert:       *noInsert,
+		NWildcard:            *nWildcard,
+		InQualBase:           *inQual,
+		OutQualBase:          *outQual,
+		InsertPercentiles:    *insertPct,
+		VerifyOutput:         *verifyOut,
+		Seed2:                *seed2,
+		Seed2Gap:             *seed2Gap,
+		TraceFraction:        *traceFrac,
+		TraceFile:            *traceFile,
+		PFM:                  pfm,
+		PFMMinScore:          *pfmScore,
+		TouchOutput:          *touchOut,
+		KmerIndex:            *kmerIdx,
+		KeepOriginal:         *keepOrig,
+		TrimTrailingSpace:    *trimSpace,
+		ContaminationProfile: *contamProf,
+		CountSidecar:         *countSide,
+		PreferMatch:          *preferHit,
+		MaxReadProcTime:      time.Duration(*maxProcMs) * time.Millisecond,
+		SoftTrim:             *softTrim,
+		Funnel:               *funnel,
+		CRLF:                 *crlf,
+		OpticalDup:           *opticalDup,
+		OpticalDupDist:       *opticalPx,
+		AnnotateAll:          *annotate,
+		QualityDist:          *qualDist,
+		AutoMaxErrorReads:    *autoMaxErr,
+		AutoMaxErrorPct:      *autoErrPct,
+		TooShortOutput:       *shortOut,
+		Parquet:              *parquetOut,
+		Input2:               *input2,
+		Merge:                *mergePairs,
+		MergeMinOverlap:      *mergeOvl,
+		DedupHeaders:         *dedupHdrs,
+		DedupWindow:          *dedupWin,
+		WobblePos:            wobble,
+		StatsBinary:          *statsBin,
+		HPCompressMatch:      *hpMatch,
+		DiffAgainst:          *diffPrev,
+		DiffSequence:         *diffSeq,
+		DiffReport:           *diffReport,
+		DecompressCmd:        *decompCmd,
+		MinAdapterScore:      *minAdScore,
+		GzBlockSize:          *gzBlockSize,
+		GzBlocks:             *gzBlocks,
+		GzipLevel:            *gzipLevel,
+		BarcodeAdapters:      barcodeAdapters,
+		SplitByAdapter:       *splitByAd,
+		InfoFile:             *infoFile,
+		MinGC:                *minGC,
+		MaxGC:                *maxGC,
+		TwoPass:              *twoPass,
+		UMIDedup:             *umiDedup,
+		ConstQual:            constQualChar,
+		PlainOutput:          *plainOut || *noCompress,
+		LengthPrior:          lengthPrior,
+		InsertEndBed:         *insertEndBed,
+		MaxAdapterMismatch:   *maxAdMismatch,
+		Collapse:             *collapse,
+		CollapseMaxUnique:    *collapseMax,
+		CollapseTmpDir:       *collapseTmp,
+		LabelFile:            *labelFile,
+		Output2:              *output2,
+		Adapter2:             *adapter2,
+		KeepSingletons:       *keepSingle,
+		MaskCycles:           masked,
+		BGZF:                 *bgzfOut,
+		Gzi:                  *gziOut,
+		Discarded:            *discarded,
+		JSONReport:           *jsonReport,
+		Quiet:                *quiet,
+		TruncateTo:           *truncateTo,
+		LengthHist:           *lenHist,
+		SplitByMode:          *splitByMode,
+		MinPartial3:          *minPartial3,
+		ReadRanges:           *readRanges,
+		AutoQualBaseReads:    autoQualReads,
+		QualCutoff:           *qualCutoff,
+		QualWindow:           *qualWindow,
+		ProgressInterval:     *progressEvery,
+		BatchSize:            *batchSize,
+		Workers:              *workers,
+		Ordered:              *ordered,
+		MaxLineLen:           *maxLineLen,
+		Adapter5:             *adapter5,
+		Adapter5MaxOffset:    *adapter5Off,
+		UMI5:                 *umi5,
+		UMI3:                 *umi3,
+		FailOnEmpty:          *failEmpty,
+		PolyTrim:             *polyTrim,
+		PolyMin:              *polyMin,
+		IUPAC:                *iupac,
+		MaxN:                 *maxN,
+		MinBaseQual:          *minBaseQual,
+		AdapterErrorRate:     *adapterErr,
+		MinOverlap:           *minOverlap,
+		MaxLen:               *maxLen,
+		QCReport:             *qcReport,
+	}
+
+	if *benchThr != "" {
+		threads, err := parseIntList(*benchThr)
+		if err != nil {
+			log.Fatalf("Invalid -benchThreads: %v", err)
+		}
+		for _, n := range threads {
+			if n < 1 {
+				log.Fatalf("-benchThreads counts must be at least 1, got %d", n)
+			}
+		}
+		if *benchReads < 1 {
+			log.Fatalf("-benchReads must be at least 1, got %d", *benchReads)
+		}
+		if *inputFile == trimmer.StdioPath || strings.Contains(*inputFile, ",") {
+			log.Fatalf("-benchThreads needs a single named input file")
+		}
+		if opts.Merge || opts.Output2 != "" || opts.DecompressCmd != "" {
+			log.Fatalf("-benchThreads cannot be combined with -merge, -o2 or -decompressCmd")
+		}
+		if err := trimmer.BenchmarkThreads(*inputFile, threads, *benchReads, opts); err != nil {
+			log.Fatalf("Error benchmarking: %v", err)
+		}
+		return
+	}
+
+	inputs := strings.Split(*inputFile, ",")
+	if len(inputs) > 1 && *combineIn {
+		for _, in := range inputs {
+			if in == trimmer.StdioPath {
+				log.Fatalf("-combineInputs needs named input files, not stdin")
+			}
+		}
+		if opts.Merge || opts.Input2 != "" || opts.ReadRanges > 1 || opts.DecompressCmd != "" {
+			log.Fatalf("-combineInputs cannot be combined with -merge, -i2, -readRanges or -decompressCmd")
+		}
+	}
+
+	if len(inputs) > 1 && !*combineIn {
+		if toStdout {
+			log.Fatalf("Multiple input files need an -o output directory")
+		}
+		for name, path := range map[string]string{
+			"traceFile":            opts.TraceFile,
+			"contaminationProfile": opts.ContaminationProfile,
+			"qcReport":             opts.QCReport,
+			"annotateAll":          opts.AnnotateAll,
+			"qualityDist":          opts.QualityDist,
+			"lengthHist":           opts.LengthHist,
+			"tooShortOutput":       opts.TooShortOutput,
+			"splitByAdapter":       opts.SplitByAdapter,
+			"infoFile":             opts.InfoFile,
+			"insertEndBed":         opts.InsertEndBed,
+			"labelFile":            opts.LabelFile,
+			"discarded":            opts.Discarded,
+			"parquet":              opts.Parquet,
+			"i2":                   opts.Input2,
+			"statsBinary":          opts.StatsBinary,
+			"diffAgainst":          opts.DiffAgainst,
+		} {
+			if path != "" {
+				log.Fatalf("-%s cannot be used with multiple input files", name)
+			}
+		}
+		if opts.TwoPass {
+			log.Fatalf("-twoPass cannot be used with multiple input files")
+		}
+		err = trimmer.ProcessFilesParallel(inputs, *outputFile, opts, *fileConc)
+	} else if opts.Output2 != "" {
+		err = trimmer.ProcessReadsPaired(*inputFile, *outputFile, opts)
+	} else if opts.TwoPass {
+		err = trimmer.ProcessReadsTwoPass(*inputFile, *outputFile, opts)
 	} else {
-		fmt.Println("\nTrimming completed")
+		// Ctrl-C stops reading and flushes what has been trimmed so far; a
+		// second one kills the process as usual.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+		err = trimmer.ProcessReadsFastCtx(ctx, *inputFile, *outputFile, opts)
+		stop()
+	}
+
+	if err != nil {
+		log.Fatalf("Error processing reads: %v", err)
+	} else if !opts.Quiet {
+		fmt.Fprintln(trimmer.SummaryWriter(*outputFile), "\nTrimming completed")
 	}
 }
@@ -0,0 +1,512 @@
+package trimmer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// findAdapter returns the index in sequence where the 3' adapter starts, or
+// -1 if it could not be located. When several positions are acceptable the
+// leftmost is used or, if opts.PreferMatch is "latest", the rightmost of
+// those with the fewest mismatches.
+func findAdapter(sequence string, opts *Options) int {
+	return findAdapterBefore(sequence, opts, deadline{})
+}
+
+// findAnyAdapter searches for Adapter and each of MoreAdapters, returning
+// the earliest start found, so the read is cut at the first adapter, and
+// which adapter it was: 0 for Adapter, i for MoreAdapters[i-1]. Ties go to
+// the adapter listed first.
+func findAnyAdapter(sequence string, opts *Options, dl deadline) (int, int) {
+	adapterIndex := findAdapterBefore(sequence, opts, dl)
+	which := 0
+	for i, alt := range opts.alts {
+		if adapterIndex == adapterTimeout {
+			break
+		}
+		index := findAdapterBefore(sequence, alt, dl)
+		if index == adapterTimeout || (index >= 0 && (adapterIndex == -1 || index < adapterIndex)) {
+			adapterIndex, which = index, i+1
+		}
+	}
+	return adapterIndex, which
+}
+
+// adapterTimeout is returned by the adapter search in place of a position
+// when the per-read deadline passes.
+const adapterTimeout = -2
+
+// deadline bounds the time spent searching one read. The zero value never
+// expires.
+type deadline struct {
+	t time.Time
+}
+
+func newDeadline(budget time.Duration) deadline {
+	if budget <= 0 {
+		return deadline{}
+	}
+	return deadline{time.Now().Add(budget)}
+}
+
+func (d deadline) expired() bool {
+	return !d.t.IsZero() && time.Now().After(d.t)
+}
+
+// findAdapterBefore is findAdapter that gives up with adapterTimeout once
+// dl expires.
+func findAdapterBefore(sequence string, opts *Options, dl deadline) int {
+	adapterIndex := nextAdapterHit(sequence, 0, opts, dl)
+	if len(opts.LengthPrior) > 0 {
+		adapterIndex = priorAdapterHit(sequence, adapterIndex, opts, dl)
+	} else if adapterIndex >= 0 && opts.PreferMatch == PreferLatest {
+		adapterIndex = latestBestHit(sequence, adapterIndex, opts, dl)
+	}
+	if adapterIndex < 0 {
+		return adapterIndex
+	}
+	if opts.IndelRefine > 0 {
+		adapterIndex = refineAdapterStart(sequence, opts.Adapter, adapterIndex, opts.IndelRefine, dl)
+	}
+	return adapterIndex
+}
+
+// latestBestHit returns the rightmost of the acceptable adapter positions
+// from first on that have the fewest mismatches, so a later but worse hit
+// never wins over a better one. Only a seed search with mismatches allowed
+// tells hits apart; otherwise every hit counts as a perfect one.
+func latestBestHit(sequence string, first int, opts *Options, dl deadline) int {
+	best, bestMismatches := first, hitMismatches(sequence, first, opts)
+	for next := nextAdapterHit(sequence, first+1, opts, dl); next >= 0; next = nextAdapterHit(sequence, next+1, opts, dl) {
+		if mismatches := hitMismatches(sequence, next, opts); mismatches <= bestMismatches {
+			best, bestMismatches = next, mismatches
+		}
+	}
+	if dl.expired() {
+		return adapterTimeout
+	}
+	return best
+}
+
+// hitMismatches counts the mismatched bases of the adapter hit at pos over
+// what the search compared: the seed, or with AdapterErrorRate the adapter
+// up to the end of the read. Hits found by PFM or alignment score count as
+// perfect.
+func hitMismatches(sequence string, pos int, opts *Options) int {
+	if opts.PFM != nil || opts.MinAdapterScore > 0 {
+		return 0
+	}
+	compared, match := searchSeed(opts)
+	if opts.AdapterErrorRate > 0 {
+		compared = opts.Adapter
+	}
+	mismatches := 0
+	for j := 0; j < len(compared) && pos+j < len(sequence); j++ {
+		if match == nil {
+			if sequence[pos+j] != compared[j] {
+				mismatches++
+			}
+		} else if !match(sequence[pos+j], compared[j]) {
+			mismatches++
+		}
+	}
+	return mismatches
+}
+
+// Values for Options.PreferMatch.
+const (
+	PreferEarliest = "earliest"
+	PreferLatest   = "latest"
+)
+
+// nextAdapterHit returns the leftmost acceptable adapter position at or
+// after from, or -1.
+func nextAdapterHit(sequence string, from int, opts *Options, dl deadline) int {
+	if opts.PFM != nil {
+		return opts.PFM.index(sequence, from, opts.PFMMinScore, dl)
+	}
+	if opts.MinAdapterScore > 0 {
+		return indexAlignScore(sequence, from, opts, dl)
+	}
+
+	seed, match := searchSeed(opts)
+	find := func(from int) int {
+		if opts.AdapterErrorRate > 0 {
+			return indexErrorRate(sequence, opts.Adapter, from, match, maxInt(opts.Min5Match, opts.MinOverlap), opts.AdapterErrorRate, dl)
+		}
+		if opts.MaxAdapterMismatch > 0 {
+			return indexSeedHamming(sequence, seed, from, match, opts.MaxAdapterMismatch, dl)
+		}
+		return indexSeed(sequence, seed, from, match, opts.kmer, dl)
+	}
+	// Stacked seeds: only accept a hit if the second seed follows at the
+	// expected spacing. With MinOverlap, the adapter must also go on
+	// matching past the seed for that many bases in all. A rejected hit
+	// means looking further along the read.
+	accept := func(i int) bool {
+		if opts.Seed2 != "" && !seedMatchesAt(sequence, opts.Seed2, i+len(seed)+opts.Seed2Gap, match) {
+			return false
+		}
+		if opts.MinOverlap > len(seed) && opts.AdapterErrorRate == 0 &&
+			adapterExtent(sequence, opts.Adapter, i, len(seed), match) < opts.MinOverlap {
+			return false
+		}
+		return true
+	}
+	adapterIndex := find(from)
+	for adapterIndex >= 0 && !accept(adapterIndex) {
+		adapterIndex = find(adapterIndex + 1)
+	}
+	return adapterIndex
+}
+
+// searchSeed returns the seed the 3' adapter search looks for and the base
+// matcher it compares with, nil meaning exact.
+func searchSeed(opts *Options) (string, baseMatcher) {
+	seed := opts.Adapter[:opts.Min5Match]
+	var match baseMatcher
+	if opts.NWildcard {
+		match = readNWildcard
+	}
+	if opts.IUPAC {
+		match = iupacMatcher(match)
+	}
+	if opts.wobbleSeed != "" {
+		seed = opts.wobbleSeed
+		match = wobbleMatcher(match)
+	}
+	if len(opts.MaskCycles) > 0 {
+		match = maskedMatcher(match)
+	}
+	return seed, match
+}
+
+// adapterExtent returns how many bases of adapter match the read from pos
+// on: the seedLen bases of the seed hit there, and then as many more as
+// match before the first mismatch or the end of either.
+func adapterExtent(sequence, adapter string, pos, seedLen int, match baseMatcher) int {
+	n := seedLen
+	for ; n < len(adapter) && pos+n < len(sequence); n++ {
+		if match == nil {
+			if sequence[pos+n] != adapter[n] {
+				break
+			}
+		} else if !match(sequence[pos+n], adapter[n]) {
+			break
+		}
+	}
+	return n
+}
+
+// partialAdapterAtEnd returns where the longest read suffix that is a
+// prefix of adapter starts, or -1. Only suffixes of at least minPartial and
+// shorter than the seed are tried, since a longer adapter remnant would
+// contain the whole seed and be found by the seed search.
+func partialAdapterAtEnd(sequence, adapter string, minPartial, seedLen int) int {
+	longest := minInt(seedLen-1, minInt(len(adapter), len(sequence)))
+	for n := longest; n >= minPartial; n-- {
+		if sequence[len(sequence)-n:] == adapter[:n] {
+			return len(sequence) - n
+		}
+	}
+	return -1
+}
+
+// adapter5End returns where the insert starts in a read that begins with
+// the 5' adapter Adapter5, or 0 if it does not. The adapter is found by its
+// last Min5Match bases, which sit just before the insert; they are looked
+// for from the start of the read up to Adapter5MaxOffset bases past where a
+// complete adapter would put them, and the earliest hit is cut after.
+func adapter5End(sequence string, opts *Options) int {
+	adapter := opts.Adapter5
+	seedLen := minInt(opts.Min5Match, len(adapter))
+	if seedLen <= 0 {
+		seedLen = len(adapter)
+	}
+	seed := adapter[len(adapter)-seedLen:]
+	window := minInt(len(sequence), len(adapter)+maxInt(opts.Adapter5MaxOffset, 0))
+
+	var match baseMatcher
+	if opts.NWildcard {
+		match = readNWildcard
+	}
+	if opts.IUPAC {
+		match = iupacMatcher(match)
+	}
+	if len(opts.MaskCycles) > 0 {
+		match = maskedMatcher(match)
+	}
+	i := indexSeedHamming(sequence[:window], seed, 0, match, opts.MaxAdapterMismatch, deadline{})
+	if i < 0 {
+		return 0
+	}
+	return i + seedLen
+}
+
+// indexSeedHamming returns the leftmost position at or after from where
+// seed matches the read with at most maxMismatch mismatched bases, or -1.
+func indexSeedHamming(sequence, seed string, from int, match baseMatcher, maxMismatch int, dl deadline) int {
+	for i := from; i+len(seed) <= len(sequence); i++ {
+		mismatches := 0
+		for j := 0; j < len(seed) && mismatches <= maxMismatch; j++ {
+			if match == nil {
+				if sequence[i+j] != seed[j] {
+					mismatches++
+				}
+			} else if !match(sequence[i+j], seed[j]) {
+				mismatches++
+			}
+		}
+		if mismatches <= maxMismatch {
+			return i
+		}
+		if dl.expired() {
+			return adapterTimeout
+		}
+	}
+	return -1
+}
+
+// indexErrorRate returns the leftmost position at or after from where the
+// adapter, truncated by the end of the read, overlaps the read by at least
+// minOverlap bases with no more mismatches than rate allows for that
+// overlap, or -1. A 20-base overlap at a rate of 0.1 tolerates 2
+// mismatches; one of 5 bases tolerates none.
+func indexErrorRate(sequence, adapter string, from int, match baseMatcher, minOverlap int, rate float64, dl deadline) int {
+	for i := from; i+minOverlap <= len(sequence); i++ {
+		overlap := minInt(len(adapter), len(sequence)-i)
+		allowed := int(rate * float64(overlap))
+		mismatches := 0
+		for j := 0; j < overlap && mismatches <= allowed; j++ {
+			if match == nil {
+				if sequence[i+j] != adapter[j] {
+					mismatches++
+				}
+			} else if !match(sequence[i+j], adapter[j]) {
+				mismatches++
+			}
+		}
+		if mismatches <= allowed {
+			return i
+		}
+		if dl.expired() {
+			return adapterTimeout
+		}
+	}
+	return -1
+}
+
+// indexAlignScore returns the leftmost position at or after from where the
+// adapter aligns with a score of at least opts.MinAdapterScore, or -1. No
+// seed has to match; near the end of the read the adapter is truncated, so
+// a partial adapter qualifies only if it alone reaches the score.
+func indexAlignScore(sequence string, from int, opts *Options, dl deadline) int {
+	for pos := from; pos < len(sequence); pos++ {
+		if adapterAlignScore(sequence, opts.Adapter, pos, opts.IndelRefine) >= opts.MinAdapterScore {
+			return pos
+		}
+		if dl.expired() {
+			return adapterTimeout
+		}
+	}
+	return -1
+}
+
+// baseMatcher reports whether a read base is compatible with an adapter base.
+// A nil baseMatcher means exact matching.
+type baseMatcher func(readBase, adapterBase byte) bool
+
+// readNWildcard treats an N in the read as matching any adapter base.
+func readNWildcard(readBase, adapterBase byte) bool {
+	return readBase == adapterBase || readBase == 'N'
+}
+
+// wobbleBase marks a seed position that matches any read base.
+const wobbleBase = '?'
+
+// wobbleMatcher extends match, nil meaning exact, to accept anything at a
+// wobble position.
+func wobbleMatcher(match baseMatcher) baseMatcher {
+	return func(readBase, adapterBase byte) bool {
+		if adapterBase == wobbleBase {
+			return true
+		}
+		if match == nil {
+			return readBase == adapterBase
+		}
+		return match(readBase, adapterBase)
+	}
+}
+
+// iupacCodes lists the read bases each IUPAC adapter code stands for.
+var iupacCodes = map[byte]string{
+	'A': "A", 'C': "C", 'G': "G", 'T': "T", 'U': "T",
+	'R': "AG", 'Y': "CT", 'S': "CG", 'W': "AT", 'K': "GT", 'M': "AC",
+	'B': "CGT", 'D': "AGT", 'H': "ACT", 'V': "ACG", 'N': "ACGT",
+}
+
+// iupacMatch[adapterBase][readBase] is whether the IUPAC code adapterBase
+// allows readBase.
+var iupacMatch = func() (m [256][256]bool) {
+	for code, bases := range iupacCodes {
+		for i := 0; i < len(bases); i++ {
+			m[code][bases[i]] = true
+		}
+	}
+	return m
+}()
+
+// iupacMatcher extends match, nil meaning exact, to accept any read base
+// an ambiguous adapter base such as N or R stands for.
+func iupacMatcher(match baseMatcher) baseMatcher {
+	return func(readBase, adapterBase byte) bool {
+		if iupacMatch[adapterBase][readBase] {
+			return true
+		}
+		if match == nil {
+			return readBase == adapterBase
+		}
+		return match(readBase, adapterBase)
+	}
+}
+
+// maskedBase stands in for a read base at a -maskCycles position during the
+// adapter search.
+const maskedBase = '.'
+
+// maskCycles returns sequence with the bases sequenced in the given 1-based
+// cycles replaced by maskedBase. A reversed sequence has its cycles counted
+// from the end.
+func maskCycles(sequence string, cycles []int, reversed bool) string {
+	b := []byte(sequence)
+	for _, cycle := range cycles {
+		i := cycle - 1
+		if reversed {
+			i = len(b) - cycle
+		}
+		if i >= 0 && i < len(b) {
+			b[i] = maskedBase
+		}
+	}
+	return string(b)
+}
+
+// maskedMatcher extends match, nil meaning exact, to accept any adapter
+// base at a masked read position.
+func maskedMatcher(match baseMatcher) baseMatcher {
+	return func(readBase, adapterBase byte) bool {
+		if readBase == maskedBase {
+			return true
+		}
+		if match == nil {
+			return readBase == adapterBase
+		}
+		return match(readBase, adapterBase)
+	}
+}
+
+// indexSeed returns the leftmost position at or after from where seed
+// matches the read, or -1.
+func indexSeed(sequence, seed string, from int, match baseMatcher, kmer *kmerIndex, dl deadline) int {
+	if kmer != nil && match == nil {
+		return kmer.index(sequence, from)
+	}
+	if match == nil {
+		i := strings.Index(sequence[from:], seed)
+		if i == -1 {
+			return -1
+		}
+		return from + i
+	}
+	for i := from; i+len(seed) <= len(sequence); i++ {
+		if seedMatchesAt(sequence, seed, i, match) {
+			return i
+		}
+		if dl.expired() {
+			return adapterTimeout
+		}
+	}
+	return -1
+}
+
+// seedMatchesAt reports whether seed matches the read starting at pos. The
+// whole seed must fit within the read.
+func seedMatchesAt(sequence, seed string, pos int, match baseMatcher) bool {
+	if pos < 0 || pos+len(seed) > len(sequence) {
+		return false
+	}
+	if match == nil {
+		return sequence[pos:pos+len(seed)] == seed
+	}
+	for j := 0; j < len(seed); j++ {
+		if !match(sequence[pos+j], seed[j]) {
+			return false
+		}
+	}
+	return true
+}
+
+// iupacBases are the nucleotide codes accepted in an adapter sequence.
+const iupacBases = "ACGTUNRYSWKMBDHV"
+
+// NormalizeAdapter tidies a user-supplied adapter: whitespace anywhere is
+// removed and the bases uppercased. Anything that is not an IUPAC
+// nucleotide code is rejected rather than silently never matching.
+func NormalizeAdapter(adapter string) (string, error) {
+	normalized := strings.ToUpper(strings.Join(strings.Fields(adapter), ""))
+	if normalized == "" {
+		return "", fmt.Errorf("adapter is empty")
+	}
+	for i := 0; i < len(normalized); i++ {
+		if strings.IndexByte(iupacBases, normalized[i]) < 0 {
+			return "", fmt.Errorf("adapter %q has invalid base %q at position %d", adapter, normalized[i], i+1)
+		}
+	}
+	return normalized, nil
+}
+
+// CheckSeedLength reports whether a seed of min5Match bases can be cut from
+// the start of adapter.
+func CheckSeedLength(adapter string, min5Match int) error {
+	switch {
+	case adapter == "":
+		return fmt.Errorf("adapter is empty")
+	case min5Match <= 0:
+		return fmt.Errorf("min5Match (%d) must be positive", min5Match)
+	case min5Match > len(adapter):
+		return fmt.Errorf("min5Match (%d) cannot exceed adapter length (%d)", min5Match, len(adapter))
+	}
+	return nil
+}
+
+// checkSeeds runs CheckSeedLength on every adapter the options search for.
+// Searches by profile or alignment score use no seed and are not checked.
+func (o *Options) checkSeeds() error {
+	if o.PFM != nil || o.MinAdapterScore > 0 {
+		return nil
+	}
+	adapters := append([]string{o.Adapter}, o.MoreAdapters...)
+	for _, a := range o.BarcodeAdapters {
+		adapters = append(adapters, a)
+	}
+	for _, a := range adapters {
+		if err := CheckSeedLength(a, o.Min5Match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SeedLengthFromFraction converts a fraction of the adapter length into a
+// seed length, rounding down but never going below one base.
+func SeedLengthFromFraction(adapter string, frac float64) (int, error) {
+	if frac <= 0 || frac > 1 {
+		return 0, fmt.Errorf("fraction must be in (0, 1], got %g", frac)
+	}
+	seed := int(frac * float64(len(adapter)))
+	if seed < 1 {
+		seed = 1
+	}
+	return seed, nil
+}
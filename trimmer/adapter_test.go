@@ -0,0 +1,501 @@
+package trimmer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedLengthFromFraction(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter string
+		frac    float64
+		want    int
+		wantErr bool
+	}{
+		{name: "Half", adapter: "TGGAATTCTCGG", frac: 0.5, want: 6},
+		{name: "RoundsDown", adapter: "TGGAATTCTCG", frac: 0.5, want: 5},
+		{name: "Whole", adapter: "TGGAATTCTCGG", frac: 1, want: 12},
+		{name: "MinimumOfOne", adapter: "TGGAAT", frac: 0.01, want: 1},
+		{name: "Zero", adapter: "TGGAAT", frac: 0, wantErr: true},
+		{name: "AboveOne", adapter: "TGGAAT", frac: 1.5, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := SeedLengthFromFraction(tc.adapter, tc.frac)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFindAdapterNWildcard(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence string
+		want     int
+	}{
+		{name: "SingleN", sequence: "ACGTACGTACGTACGTACGTTGGNATTCTCGG", want: 20},
+		{name: "SeveralNs", sequence: "ACGTACGTACGTACGTACGTNGGAATNCTCGG", want: 20},
+		{name: "LeftmostWins", sequence: "ACGTNNNNNNNNTGGAATTC", want: 3},
+		{name: "MismatchStillFails", sequence: "ACGTACGTACGTACGTACGTTGGCATTCTCGG", want: -1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			exact := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8}
+			wildcard := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, NWildcard: true}
+			assert.Equal(t, tc.want, findAdapter(tc.sequence, wildcard))
+			if tc.want != -1 {
+				assert.NotEqual(t, tc.want, findAdapter(tc.sequence, exact), "exact matching should not find this position")
+			}
+		})
+	}
+}
+
+func TestFindAdapterMaxMismatch(t *testing.T) {
+	insert := "ACGTACGTACGTACGTACGT"
+	tests := []struct {
+		name        string
+		sequence    string
+		maxMismatch int
+		want        int
+	}{
+		{name: "OneSubstitution", sequence: insert + "TGGCATTCTCGG", maxMismatch: 1, want: 20},
+		{name: "OneSubstitutionExact", sequence: insert + "TGGCATTCTCGG", maxMismatch: 0, want: -1},
+		{name: "TwoSubstitutions", sequence: insert + "TCGAATTGTCGG", maxMismatch: 2, want: 20},
+		{name: "TwoSubstitutionsOverLimit", sequence: insert + "TCGAATTGTCGG", maxMismatch: 1, want: -1},
+		// TGGAATAA inside the insert is within two mismatches of the seed;
+		// the leftmost position within the limit is taken.
+		{name: "LeftmostWithinLimit", sequence: "ACGTACGTTGGAATAAACGT" + "TGGAATTCTCGG", maxMismatch: 2, want: 8},
+		{name: "LeftmostWithinLimitTooFar", sequence: "ACGTACGTTGGAATAAACGT" + "TGGAATTCTCGG", maxMismatch: 1, want: 20},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinLen: 5, MaxAdapterMismatch: tc.maxMismatch}
+			assert.Equal(t, tc.want, findAdapter(tc.sequence, opts))
+		})
+	}
+
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinLen: 5, MaxAdapterMismatch: 2}
+	read := &FastqRead{Header: "@R1", Sequence: insert + "TCGAATTGTCGG", Quality: strings.Repeat("I", 32)}
+	trimmed, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, insert, trimmed.Sequence)
+}
+
+func TestFindAdapterStackedSeeds(t *testing.T) {
+	// Adapter TGGAATTCTCGGGTGCCAAGG: seed 1 is TGGA, seed 2 is CTCG three
+	// bases after it.
+	opts := &Options{Adapter: "TGGAATTCTCGGGTGCCAAGG", Min5Match: 4, Seed2: "CTCG", Seed2Gap: 3}
+
+	tests := []struct {
+		name     string
+		sequence string
+		want     int
+	}{
+		{name: "Genuine", sequence: "ACGTACGTACGTACGTACGTTGGAATTCTCGGGTG", want: 20},
+		{name: "ChanceSeedSkipped", sequence: "ACGTTGGACCGTACGTACGTTGGAATTCTCGGGTG", want: 20},
+		{name: "OnlyChanceSeed", sequence: "ACGTTGGACCGTACGTACGTACGTACGTACGTACG", want: -1},
+		{name: "WrongSpacing", sequence: "ACGTACGTACGTACGTACGTTGGAATCTCGGGTGC", want: -1},
+		{name: "SecondSeedOffEnd", sequence: "ACGTACGTACGTACGTACGTACGTTGGAATTCT", want: -1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, findAdapter(tc.sequence, opts))
+		})
+	}
+
+	single := &Options{Adapter: opts.Adapter, Min5Match: 4}
+	assert.Equal(t, 4, findAdapter("ACGTTGGACCGTACGTACGTTGGAATTCTCGGGTG", single), "a single short seed takes the chance match")
+}
+
+func TestFindAdapterPreferMatch(t *testing.T) {
+	// Two equally good seed hits at 10 and 24.
+	sequence := "ACGTACGTACTGGAATTCACGTACTGGAATTCTCGG"
+
+	tests := []struct {
+		name string
+		opts Options
+		want int
+	}{
+		{name: "DefaultEarliest", opts: Options{}, want: 10},
+		{name: "Earliest", opts: Options{PreferMatch: PreferEarliest}, want: 10},
+		{name: "Latest", opts: Options{PreferMatch: PreferLatest}, want: 24},
+		{name: "LatestWithNWildcard", opts: Options{PreferMatch: PreferLatest, NWildcard: true}, want: 24},
+		{name: "LatestWithKmerIndex", opts: Options{PreferMatch: PreferLatest, KmerIndex: true}, want: 24},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			opts.Adapter = "TGGAATTCTCGG"
+			opts.Min5Match = 8
+			opts.prepare()
+			assert.Equal(t, tc.want, findAdapter(sequence, &opts))
+		})
+	}
+
+	single := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, PreferMatch: PreferLatest}
+	assert.Equal(t, -1, findAdapter("ACGTACGTACGT", single))
+	assert.Equal(t, 4, findAdapter("ACGTTGGAATTC", single), "a hit at the very end is found")
+}
+
+func TestFindAdapterPreferLatestFewestMismatches(t *testing.T) {
+	// A perfect seed hit at 10 and one with a mismatch at 24.
+	worseLater := "ACGTACGTACTGGAATTCACGTACTGGCATTCTCGG"
+	// One mismatch in each, at 10 and 24.
+	equalHits := "ACGTACGTACTGGCATTCACGTACTGGCATTCTCGG"
+
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MaxAdapterMismatch: 1, PreferMatch: PreferLatest}
+	assert.Equal(t, 10, findAdapter(worseLater, opts), "a later hit with more mismatches loses")
+	assert.Equal(t, 24, findAdapter(equalHits, opts), "equally good hits go to the latest")
+
+	opts.PreferMatch = PreferEarliest
+	assert.Equal(t, 10, findAdapter(worseLater, opts))
+	assert.Equal(t, 10, findAdapter(equalHits, opts))
+}
+
+func TestTrimReadTimeout(t *testing.T) {
+	// A wide matrix that never reaches the score threshold forces a scan of
+	// every position of a long read: a synthetic pathological case.
+	pfm := &PositionMatrix{scores: make([][4]float64, 4000)}
+	sequence := strings.Repeat("ACGT", 1<<18)
+	read := &FastqRead{Header: "@slow", Sequence: sequence, Quality: strings.Repeat("I", len(sequence))}
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, PFM: pfm, PFMMinScore: 1, MaxReadProcTime: 5 * time.Millisecond}
+
+	start := time.Now()
+	_, err := TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Less(t, time.Since(start), time.Second, "the search gives up soon after the budget")
+
+	fast := &FastqRead{Header: "@fast", Sequence: "ACGTACGTACGTACGTACGTTGGAATTCTCGG", Quality: strings.Repeat("I", 32)}
+	opts.PFM = nil
+	trimmed, err := TrimRead(fast, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "ACGTACGTACGTACGTACGT", trimmed.Sequence)
+}
+
+func TestTrimReadPerAdapterTrim3(t *testing.T) {
+	opts := &Options{
+		Adapter:      "TGGAATTCTCGG",
+		MoreAdapters: []string{"AGATCGGAAGAG"},
+		AdapterTrim3: []int{2, 4},
+		MinLen:       10,
+		Min5Match:    8,
+		MaxError:     0.1,
+	}
+	opts.prepare()
+
+	tests := []struct {
+		name     string
+		sequence string
+		want     string
+	}{
+		{name: "FirstAdapter", sequence: "ACGTACGTACGTACGTACTGGAATTCTCGG", want: "ACGTACGTACGTACGT"},
+		{name: "SecondAdapter", sequence: "ACGTACGTACGTACGTACAGATCGGAAGAG", want: "ACGTACGTACGTAC"},
+		{name: "EarlierSecondAdapterWins", sequence: "ACGTACGTACGTACGTACAGATCGGAAGAGTGGAATTCTCGG", want: "ACGTACGTACGTAC"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			read := &FastqRead{Header: "@R", Sequence: tc.sequence, Quality: strings.Repeat("I", len(tc.sequence))}
+			trimmed, err := TrimRead(read, opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, trimmed.Sequence)
+		})
+	}
+
+	read := &FastqRead{Header: "@R", Sequence: "ACGTACGTACGTACGTACGT", Quality: strings.Repeat("I", 20)}
+	_, err := TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing, "missing only when no adapter matches")
+}
+
+func TestTrimReadNoAdapterOfSeveral(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", MoreAdapters: []string{"AGATCGGAAGAG"}, MinLen: 10, Min5Match: 8}
+	opts.prepare()
+
+	read := &FastqRead{Header: "@R", Sequence: "ACGTACGTACGTACGTACGTACGT", Quality: strings.Repeat("I", 24)}
+	_, err := TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing, "missing only when no adapter matches")
+}
+
+func TestNormalizeAdapter(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter string
+		want    string
+		wantErr string
+	}{
+		{name: "Clean", adapter: "TGGAATTCTCGG", want: "TGGAATTCTCGG"},
+		{name: "Lowercase", adapter: "tggaattctcgg", want: "TGGAATTCTCGG"},
+		{name: "StraySpaces", adapter: "  TGGAAT TCTCGG\t\n", want: "TGGAATTCTCGG"},
+		{name: "IUPAC", adapter: "acgtNRY", want: "ACGTNRY"},
+		{name: "InvalidBase", adapter: "TGGA-ATTC", wantErr: `adapter "TGGA-ATTC" has invalid base '-' at position 5`},
+		{name: "Digit", adapter: "TGG1", wantErr: `adapter "TGG1" has invalid base '1' at position 4`},
+		{name: "Empty", adapter: " \t", wantErr: "adapter is empty"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NormalizeAdapter(tc.adapter)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFindAdapterWobblePos(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, WobblePos: []int{3, 7}}
+	opts.prepare()
+
+	tests := []struct {
+		name     string
+		sequence string
+		want     int
+	}{
+		{name: "Exact", sequence: "ACGTACGTACTGGAATTCTCGG", want: 10},
+		{name: "WobbleAt3", sequence: "ACGTACGTACTGCAATTCTCGG", want: 10},
+		{name: "WobbleAt3And7", sequence: "ACGTACGTACTGTAATACTCGG", want: 10},
+		{name: "MismatchAt4", sequence: "ACGTACGTACTGGTATTCTCGG", want: -1},
+		{name: "MismatchAt8", sequence: "ACGTACGTACTGGAATTGTCGG", want: -1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, findAdapter(tc.sequence, opts))
+		})
+	}
+
+	exact := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8}
+	assert.Equal(t, -1, findAdapter("ACGTACGTACTGCAATTCTCGG", exact), "without -wobblePos position 3 must match")
+}
+
+func TestCheckSeedLength(t *testing.T) {
+	assert.NoError(t, CheckSeedLength("TGGAAT", 6))
+	assert.EqualError(t, CheckSeedLength("TGGAAT", 12), "min5Match (12) cannot exceed adapter length (6)")
+	assert.EqualError(t, CheckSeedLength("TGGAAT", 0), "min5Match (0) must be positive")
+	assert.EqualError(t, CheckSeedLength("", 8), "adapter is empty")
+
+	read := "@R1\nACGTACGTTGGAAT\n+\nIIIIIIIIIIIIII\n"
+	opts := Options{Adapter: "TGGAAT", MoreAdapters: []string{"ACG"}, Min5Match: 4, MinLen: 1}
+	_, err := processStream(strings.NewReader(read), io.Discard, opts)
+	assert.EqualError(t, err, "min5Match (4) cannot exceed adapter length (3)", "a short extra adapter is caught before the search slices it")
+}
+
+func TestFindAdapterMaskCycles(t *testing.T) {
+	// Cycle 14 is the fourth seed base, read as C instead of A.
+	read := &FastqRead{Header: "@R1", Sequence: "ACGTACGTACTGGCATTCTCGG", Quality: "IIIIIIIIIIIIIIIIIIIIII"}
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinLen: 5}
+	_, err := TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing)
+
+	opts.MaskCycles = []int{2, 14}
+	trimmed, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "ACGTACGTAC", trimmed.Sequence, "masked cycle 2 keeps its base in the output")
+
+	// Reversed input counts cycles from the far end of the stored read.
+	reversed := &FastqRead{Header: "@R1", Sequence: reverseString(read.Sequence), Quality: read.Quality}
+	opts.ReverseInput = true
+	opts.MaskCycles = []int{9}
+	trimmed, err = TrimRead(reversed, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "ACGTACGTAC", trimmed.Sequence)
+}
+
+func TestMaskCycles(t *testing.T) {
+	assert.Equal(t, ".CG.", maskCycles("ACGT", []int{1, 4, 9}, false))
+	assert.Equal(t, "A.GT", maskCycles("ACGT", []int{3}, true))
+}
+
+func TestTrimReadPartialAdapterAtEnd(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinLen: 10, MinPartial3: 3}
+	insert := "ACGTACGTCCACGTAC"
+
+	tests := []struct {
+		name     string
+		sequence string
+		want     string
+	}{
+		{name: "ThreeBases", sequence: insert + "TGG", want: insert},
+		{name: "FourBases", sequence: insert + "TGGA", want: insert},
+		{name: "FiveBases", sequence: insert + "TGGAA", want: insert},
+		{name: "FullSeedStillWins", sequence: insert + "TGGAATTCTC", want: insert},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			read := &FastqRead{Header: "@R", Sequence: tc.sequence, Quality: strings.Repeat("I", len(tc.sequence))}
+			trimmed, err := TrimRead(read, opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, trimmed.Sequence)
+		})
+	}
+
+	twoBases := &FastqRead{Header: "@R", Sequence: insert + "TG", Quality: strings.Repeat("I", len(insert)+2)}
+	_, err := TrimRead(twoBases, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing, "shorter than -minPartial3")
+
+	opts.MinPartial3 = 0
+	_, err = TrimRead(&FastqRead{Header: "@R", Sequence: insert + "TGGAA", Quality: strings.Repeat("I", len(insert)+5)}, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing, "off by default")
+}
+
+func TestPartialAdapterAtEndLongest(t *testing.T) {
+	// Both "A" and "ACA" end the read and start the adapter; the longer wins.
+	assert.Equal(t, 3, partialAdapterAtEnd("GGGACA", "ACACGT", 1, 6))
+	assert.Equal(t, -1, partialAdapterAtEnd("GG", "ACACGT", 1, 6))
+}
+
+func TestTrimReadAdapter5(t *testing.T) {
+	const adapter5 = "GTTCAGAGTTCTACAGTCCGACGATC"
+	const insert = "ACGTTGCAACGTTGCAACGT"
+	opts := &Options{Adapter: "TGGAATTCTCGG", Adapter5: adapter5, Adapter5MaxOffset: 3, Min5Match: 8, MinLen: 10}
+
+	tests := []struct {
+		name     string
+		sequence string
+		want     string
+		err      error
+	}{
+		{name: "Both", sequence: adapter5 + insert + "TGGAATTCTCGG", want: insert},
+		{name: "BothAfterRandomBases", sequence: "NNN" + adapter5 + insert + "TGGAATTCTCGG", want: insert},
+		{name: "PartialFivePrime", sequence: adapter5[10:] + insert + "TGGAATTCTCGG", want: insert},
+		{name: "OnlyThreePrime", sequence: insert + "TGGAATTCTCGG", want: insert},
+		{name: "OnlyFivePrime", sequence: adapter5 + insert, err: ErrAdapterMissing},
+		{name: "TooFarIn", sequence: "NNNNN" + adapter5 + insert + "TGGAATTCTCGG", want: "NNNNN" + adapter5 + insert},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			read := &FastqRead{Header: "@R", Sequence: tc.sequence, Quality: qualityRamp(len(tc.sequence))}
+			trimmed, err := TrimRead(read, opts)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, trimmed.Sequence)
+			start := strings.Index(tc.sequence, tc.want)
+			assert.Equal(t, read.Quality[start:start+len(tc.want)], trimmed.Quality, "qualities follow the bases")
+		})
+	}
+
+	assert.Equal(t, len(adapter5), adapter5End(adapter5+insert, opts), "found without a 3' adapter too")
+	assert.Equal(t, 0, adapter5End(insert, opts))
+}
+
+// qualityRamp is n distinct quality characters, so a misaligned quality
+// string shows.
+func qualityRamp(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('#' + i%40)
+	}
+	return string(b)
+}
+
+func TestTrimReadIUPAC(t *testing.T) {
+	opts := &Options{Adapter: "TGGNATTCTCGR", Min5Match: 12, MinLen: 10, IUPAC: true}
+	const insert = "ACGTACGTACGTACGTACGT"
+
+	for _, adapter := range []string{"TGGAATTCTCGG", "TGGCATTCTCGA", "TGGGATTCTCGG", "TGGTATTCTCGA"} {
+		read := &FastqRead{Header: "@R", Sequence: insert + adapter, Quality: strings.Repeat("I", 32)}
+		trimmed, err := TrimRead(read, opts)
+		assert.NoError(t, err, adapter)
+		assert.Equal(t, insert, trimmed.Sequence, adapter)
+	}
+
+	read := &FastqRead{Header: "@R", Sequence: insert + "TGGAATTCTCGC", Quality: strings.Repeat("I", 32)}
+	_, err := TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing, "R does not stand for C")
+
+	opts.IUPAC = false
+	read.Sequence = insert + "TGGAATTCTCGG"
+	_, err = TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing, "without -iupac the N is literal")
+}
+
+func TestIUPACMatcher(t *testing.T) {
+	match := iupacMatcher(nil)
+	assert.True(t, match('A', 'N'))
+	assert.True(t, match('G', 'R'))
+	assert.False(t, match('C', 'R'))
+	assert.True(t, match('T', 'U'))
+	assert.False(t, match('N', 'A'), "a read N needs -nWildcard")
+	assert.True(t, iupacMatcher(readNWildcard)('N', 'A'))
+}
+
+func TestTrimReadAdapterErrorRate(t *testing.T) {
+	const adapter = "TGGAATTCTCGGGTGCCAAGG"
+	insert := "ACCTGACCTGACCTGACCTG"
+	newRead := func(adapterPart string) *FastqRead {
+		seq := insert + adapterPart
+		return &FastqRead{Header: "@R", Sequence: seq, Quality: strings.Repeat("I", len(seq))}
+	}
+	twoOff := newRead("TGCAATTCTCGGGTGCTAAGG")   // 2 of 21 mismatched, one in the seed
+	threeOff := newRead("TGCAATTCTCGGATGCTAAGG") // 3 of 21
+	shortTail := newRead("TGCAATTCT")            // 1 of 9: too short an overlap for any mismatch at 0.1
+
+	for _, tc := range []struct {
+		rate  float64
+		found []bool // for twoOff, threeOff, shortTail
+	}{
+		{0, []bool{false, false, false}},
+		{0.1, []bool{true, false, false}},
+		{0.2, []bool{true, true, true}},
+	} {
+		t.Run(fmt.Sprint(tc.rate), func(t *testing.T) {
+			opts := &Options{Adapter: adapter, Min5Match: 8, MinLen: 10, AdapterErrorRate: tc.rate}
+			for i, read := range []*FastqRead{twoOff, threeOff, shortTail} {
+				trimmed, err := TrimRead(read, opts)
+				if tc.found[i] {
+					if assert.NoError(t, err, read.Sequence) {
+						assert.Equal(t, insert, trimmed.Sequence)
+					}
+				} else {
+					assert.ErrorIs(t, err, ErrAdapterMissing, read.Sequence)
+				}
+			}
+		})
+	}
+
+	opts := &Options{Adapter: adapter, Min5Match: 8, MinLen: 10, AdapterErrorRate: 0.1}
+	assert.Equal(t, -1, indexErrorRate(insert+"TGGAATT", adapter, 0, nil, 8, 0.1, deadline{}), "shorter than the minimum overlap")
+	trimmed, err := TrimRead(newRead(adapter), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, insert, trimmed.Sequence, "an exact adapter is still found")
+}
+
+func TestTrimReadMinOverlap(t *testing.T) {
+	insert := "TGGCCCAAACCCAAACCCAA" // opens with the first 3 adapter bases by chance
+	seq := insert + "TGGAATTCTC"
+	read := &FastqRead{Header: "@R", Sequence: seq, Quality: strings.Repeat("I", len(seq))}
+
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 3, MinLen: 10}
+	_, err := TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrTooShort, "the 3-base chance match at the start is taken")
+
+	opts.MinOverlap = 10
+	trimmed, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, insert, trimmed.Sequence, "the 10-base adapter is trimmed")
+
+	opts.MinOverlap = 11
+	_, err = TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing, "the read ends 10 bases into the adapter")
+
+	assert.Equal(t, 3, adapterExtent(seq, opts.Adapter, 0, 3, nil))
+	assert.Equal(t, 10, adapterExtent(seq, opts.Adapter, len(insert), 3, nil))
+}
@@ -0,0 +1,84 @@
+package trimmer
+
+// Scores used when aligning the adapter against a read.
+const (
+	alignMatch    = 1
+	alignMismatch = -1
+	alignGap      = -1
+)
+
+// adapterAlignScore globally aligns adapter against the read starting at
+// start, allowing at most maxIndel net insertions/deletions. Bases of the
+// read past the aligned adapter are free, and the adapter is truncated if it
+// runs off the end of the read.
+func adapterAlignScore(sequence, adapter string, start, maxIndel int) int {
+	read := sequence[start:]
+	if len(adapter) > len(read) {
+		adapter = adapter[:len(read)]
+	}
+	if len(read) > len(adapter)+maxIndel {
+		read = read[:len(adapter)+maxIndel]
+	}
+
+	prev := make([]int, len(read)+1)
+	curr := make([]int, len(read)+1)
+	for j := range prev {
+		prev[j] = j * alignGap
+	}
+	for i := 1; i <= len(adapter); i++ {
+		curr[0] = i * alignGap
+		for j := 1; j <= len(read); j++ {
+			diag := prev[j-1] + alignMismatch
+			if adapter[i-1] == read[j-1] {
+				diag = prev[j-1] + alignMatch
+			}
+			curr[j] = maxInt(diag, maxInt(prev[j], curr[j-1])+alignGap)
+		}
+		prev, curr = curr, prev
+	}
+
+	best := prev[0]
+	for j := 1; j <= len(read); j++ {
+		if j-len(adapter) >= -maxIndel && prev[j] > best {
+			best = prev[j]
+		}
+	}
+	return best
+}
+
+// refineAdapterStart moves a seed hit at seedIndex by up to maxIndel bases in
+// either direction to where the full adapter aligns best. This corrects the
+// boundary when a homopolymer indel just upstream of the adapter makes the
+// short seed land a base or two early or late. Ties keep the seed position.
+func refineAdapterStart(sequence, adapter string, seedIndex, maxIndel int, dl deadline) int {
+	best := seedIndex
+	bestScore := adapterAlignScore(sequence, adapter, seedIndex, maxIndel)
+	for d := 1; d <= maxIndel; d++ {
+		if dl.expired() {
+			return adapterTimeout
+		}
+		for _, pos := range []int{seedIndex - d, seedIndex + d} {
+			if pos < 0 || pos >= len(sequence) {
+				continue
+			}
+			if score := adapterAlignScore(sequence, adapter, pos, maxIndel); score > bestScore {
+				best, bestScore = pos, score
+			}
+		}
+	}
+	return best
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,93 @@
+package trimmer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdapterAlignScore(t *testing.T) {
+	assert.Equal(t, 8, adapterAlignScore("TTAAAAGTCG", "AAAAGTCG", 2, 2))
+	assert.Equal(t, 7, adapterAlignScore("TTAAAAAGTCG", "AAAAGTCG", 2, 2), "one extra A costs a gap")
+	assert.Equal(t, 4, adapterAlignScore("TTAAAA", "AAAAGTCG", 2, 2), "adapter truncated at read end")
+}
+
+func TestRefineAdapterStart(t *testing.T) {
+	adapter := "AAAAGTCGTATG"
+
+	tests := []struct {
+		name     string
+		sequence string
+		want     int
+	}{
+		{
+			// The insert ends in an extra A (homopolymer insertion), so the
+			// exact AAAA seed lands one base before the real adapter start.
+			name:     "UpstreamInsertion",
+			sequence: "CGTACGTACGTACGTACGTCA" + adapter,
+			want:     21,
+		},
+		{
+			name:     "UpstreamDoubleInsertion",
+			sequence: "CGTACGTACGTACGTACGTCAA" + adapter,
+			want:     22,
+		},
+		{
+			name:     "ExactAdapterUnchanged",
+			sequence: "CGTACGTACGTACGTACGTC" + adapter,
+			want:     20,
+		},
+		{
+			// A deletion inside the adapter should not move the boundary.
+			name:     "DeletionInAdapter",
+			sequence: "CGTACGTACGTACGTACGTC" + "AAAAGTCGATG",
+			want:     20,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &Options{Adapter: adapter, Min5Match: 4, IndelRefine: 2}
+			assert.Equal(t, tc.want, findAdapter(tc.sequence, opts))
+		})
+	}
+
+	t.Run("DisabledKeepsSeedHit", func(t *testing.T) {
+		opts := &Options{Adapter: adapter, Min5Match: 4}
+		assert.Equal(t, 20, findAdapter("CGTACGTACGTACGTACGTCA"+adapter, opts))
+	})
+}
+
+func TestFindAdapterMinAdapterScore(t *testing.T) {
+	// TGGAATTCTCGG with mismatches at adapter positions 4 and 9: 10 matches,
+	// 2 mismatches, score 8. No 8 base seed matches exactly.
+	sequence := "ACGTACGTACGTACGTACTGGTATTCTGGGACGT"
+
+	tests := []struct {
+		name     string
+		minScore int
+		indels   int
+		want     int
+	}{
+		{name: "AtScore", minScore: 8, want: 18},
+		// One base early the adapter still aligns, with a gap, at score 7;
+		// the leftmost qualifying position wins.
+		{name: "BelowScore", minScore: 7, want: 17},
+		{name: "AboveScore", minScore: 9, want: -1},
+		{name: "PerfectRequired", minScore: 12, want: -1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinAdapterScore: tc.minScore, IndelRefine: tc.indels}
+			assert.Equal(t, tc.want, findAdapter(sequence, opts))
+		})
+	}
+
+	seedOnly := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8}
+	assert.Equal(t, -1, findAdapter(sequence, seedOnly), "the seed matcher misses the mismatched adapter")
+
+	// A partial adapter at the read end only counts if it reaches the score.
+	tail := "ACGTACGTACGTACGTACGTTGGAAT"
+	assert.Equal(t, 20, findAdapter(tail, &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinAdapterScore: 6}))
+	assert.Equal(t, -1, findAdapter(tail, &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinAdapterScore: 7}))
+}
@@ -0,0 +1,53 @@
+package trimmer
+
+import (
+	"io"
+	"strings"
+)
+
+// annotator writes every input read, tagged with its fate, to a single
+// gzipped FASTQ stream. Kept reads are written trimmed, rejected ones as
+// they were read.
+type annotator struct {
+	*fastqSink
+}
+
+func newAnnotator(w io.Writer, opts *Options) *annotator {
+	return &annotator{newFastqSink(w, opts)}
+}
+
+// fateTag turns a TrimRead error, or nil for a kept read, into the tag
+// added to the read's header, e.g. fate=adapter-missing.
+func fateTag(err error) string {
+	if err == nil {
+		return "fate=kept"
+	}
+	return "fate=" + strings.ReplaceAll(err.Error(), " ", "-")
+}
+
+func (a *annotator) write(read *FastqRead, err error) {
+	tagged := *read
+	tagged.Header += " " + fateTag(err)
+	a.fastqSink.write(&tagged)
+}
+
+// discardSink writes the reads TrimRead rejects, as they were read, with a
+// reason tag such as reason:adapter_missing added to the header.
+type discardSink struct {
+	*fastqSink
+}
+
+func newDiscardSink(w io.Writer, opts *Options) *discardSink {
+	return &discardSink{newFastqSink(w, opts)}
+}
+
+// reasonTag turns a TrimRead error into its -discarded header tag.
+func reasonTag(err error) string {
+	return "reason:" + strings.ReplaceAll(err.Error(), " ", "_")
+}
+
+func (d *discardSink) write(read *FastqRead, err error) {
+	tagged := *read
+	tagged.Header += " " + reasonTag(err)
+	d.fastqSink.write(&tagged)
+}
@@ -0,0 +1,42 @@
+package trimmer
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/pgzip"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotateAll(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &Options{Adapter: "ATCACG", MinLen: 18, Trim5: 2, Min5Match: 4, MaxError: 0.1, DetectNoInsert: true}
+	opts.annotator = newAnnotator(&buf, opts)
+
+	reads := []*FastqRead{
+		{Header: "@KEPT", Sequence: "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACG", Quality: "JJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJ"},
+		{Header: "@MISSING x=1", Sequence: "GGGGGGGGGGGGGGGGGGGG", Quality: "JJJJJJJJJJJJJJJJJJJJ"},
+		{Header: "@SHORT", Sequence: "ACGTACGTATCACGGG", Quality: "JJJJJJJJJJJJJJJJ"},
+		{Header: "@LOWQ", Sequence: "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACG", Quality: "#######################################"},
+		{Header: "@NOINSERT", Sequence: "AATCACGGGGGGGGGGGGGG", Quality: "JJJJJJJJJJJJJJJJJJJJ"},
+	}
+	resultsChan := make(chan *FastqRead, len(reads))
+	var wg sync.WaitGroup
+	var stats Stats
+	wg.Add(1)
+	ProcessBatch(reads, opts, resultsChan, &wg, &stats)
+	assert.Len(t, resultsChan, 1, "annotation does not change what is kept")
+	assert.NoError(t, opts.annotator.close())
+
+	gr, err := pgzip.NewReader(&buf)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, "@KEPT fate=kept\nTCGGAAGAGCACACGTCTGAACTCCAGTCAC\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJ\n"+
+		"@MISSING x=1 fate=adapter-missing\nGGGGGGGGGGGGGGGGGGGG\n+\nJJJJJJJJJJJJJJJJJJJJ\n"+
+		"@SHORT fate=too-short\nACGTACGTATCACGGG\n+\nJJJJJJJJJJJJJJJJ\n"+
+		"@LOWQ fate=low-quality\nGATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACG\n+\n#######################################\n"+
+		"@NOINSERT fate=no-insert\nAATCACGGGGGGGGGGGGGG\n+\nJJJJJJJJJJJJJJJJJJJJ\n", string(data))
+}
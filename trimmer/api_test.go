@@ -0,0 +1,43 @@
+package trimmer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"scramTrimmer/trimmer"
+)
+
+func TestTrimReadWithoutPrepare(t *testing.T) {
+	insert := "ACGTACGTACGTACGTACGT"
+	seq := insert + "AGATCGGAAGAG"
+	read := &trimmer.FastqRead{Header: "@R", Sequence: seq, Quality: strings.Repeat("I", len(seq))}
+	opts := &trimmer.Options{
+		Adapter:      "TGGAATTCTCGG",
+		MoreAdapters: []string{"AGATCGGAAGAG"},
+		Min5Match:    8,
+		MinLen:       10,
+		NoQualFilter: true,
+	}
+
+	trimmed, err := trimmer.TrimRead(read, opts)
+	assert.NoError(t, err, "the second adapter is searched for without an explicit Prepare")
+	assert.Equal(t, insert, trimmed.Sequence)
+
+	assert.NoError(t, opts.Prepare())
+	trimmed, err = trimmer.TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, insert, trimmed.Sequence)
+}
+
+func TestTrimReadSeedLongerThanAdapter(t *testing.T) {
+	opts := &trimmer.Options{Adapter: "TGG", Min5Match: 8, MinLen: 10}
+	read := &trimmer.FastqRead{Header: "@R", Sequence: "ACGTACGTACGTTGG", Quality: strings.Repeat("I", 15)}
+
+	assert.NotPanics(t, func() {
+		_, err := trimmer.TrimRead(read, opts)
+		assert.Error(t, err)
+	})
+	assert.Error(t, opts.Prepare())
+}
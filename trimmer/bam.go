@@ -0,0 +1,159 @@
+package trimmer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// bamMagic opens every decompressed BAM stream.
+const bamMagic = "BAM\x01"
+
+// bamBases decodes the 4-bit packed BAM sequence alphabet.
+const bamBases = "=ACMGRSVTWYHKDBN"
+
+// BAM flag bits consulted when extracting reads.
+const (
+	bamFlagReverse       = 0x10
+	bamFlagSecondary     = 0x100
+	bamFlagSupplementary = 0x800
+)
+
+// isBAM reports whether the decompressed input starts with the BAM magic.
+func isBAM(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(bamMagic))
+	return err == nil && string(magic) == bamMagic
+}
+
+// bamReader extracts the read name, SEQ and QUAL of each primary record in
+// a decompressed BAM stream. Qualities are written with qualBase so the rest
+// of the pipeline sees them as it would FASTQ input; a missing QUAL reads as
+// Phred 0. Reverse-strand records are turned back to their sequenced
+// orientation, as samtools fastq does.
+type bamReader struct {
+	r        io.Reader
+	qualBase int
+	header   bool
+}
+
+func newBAMReader(r io.Reader, qualBase int) *bamReader {
+	return &bamReader{r: r, qualBase: qualBase}
+}
+
+// skipHeader consumes the magic, the SAM text and the reference list.
+func (b *bamReader) skipHeader() error {
+	magic := make([]byte, len(bamMagic))
+	if _, err := io.ReadFull(b.r, magic); err != nil {
+		return fmt.Errorf("invalid bam file: %v", err)
+	}
+	if string(magic) != bamMagic {
+		return fmt.Errorf("invalid bam file: bad magic %q", magic)
+	}
+	var lText int32
+	if err := binary.Read(b.r, binary.LittleEndian, &lText); err != nil {
+		return fmt.Errorf("invalid bam file: %v", err)
+	}
+	if _, err := io.CopyN(io.Discard, b.r, int64(lText)); err != nil {
+		return fmt.Errorf("invalid bam file: %v", err)
+	}
+	var nRef int32
+	if err := binary.Read(b.r, binary.LittleEndian, &nRef); err != nil {
+		return fmt.Errorf("invalid bam file: %v", err)
+	}
+	for i := int32(0); i < nRef; i++ {
+		var lName int32
+		if err := binary.Read(b.r, binary.LittleEndian, &lName); err != nil {
+			return fmt.Errorf("invalid bam file: %v", err)
+		}
+		// The name is followed by the int32 reference length.
+		if _, err := io.CopyN(io.Discard, b.r, int64(lName)+4); err != nil {
+			return fmt.Errorf("invalid bam file: %v", err)
+		}
+	}
+	return nil
+}
+
+// next returns the next primary record, or io.EOF after the last one.
+func (b *bamReader) next() (*FastqRead, error) {
+	if !b.header {
+		if err := b.skipHeader(); err != nil {
+			return nil, err
+		}
+		b.header = true
+	}
+	for {
+		var blockSize int32
+		if err := binary.Read(b.r, binary.LittleEndian, &blockSize); err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("invalid bam file: %v", err)
+		}
+		if blockSize < 32 {
+			return nil, fmt.Errorf("invalid bam file: record of %d bytes", blockSize)
+		}
+		rec := make([]byte, blockSize)
+		if _, err := io.ReadFull(b.r, rec); err != nil {
+			return nil, fmt.Errorf("invalid bam file: truncated record: %v", err)
+		}
+		flag := binary.LittleEndian.Uint16(rec[14:])
+		if flag&(bamFlagSecondary|bamFlagSupplementary) != 0 {
+			continue
+		}
+		read, err := b.decode(rec, flag)
+		if err != nil {
+			return nil, err
+		}
+		return read, nil
+	}
+}
+
+// decode unpacks one record body, i.e. everything after block_size.
+func (b *bamReader) decode(rec []byte, flag uint16) (*FastqRead, error) {
+	lReadName := int(rec[8])
+	nCigarOp := int(binary.LittleEndian.Uint16(rec[12:]))
+	lSeq := int(binary.LittleEndian.Uint32(rec[16:]))
+	seqStart := 32 + lReadName + 4*nCigarOp
+	qualStart := seqStart + (lSeq+1)/2
+	if lReadName == 0 || lSeq < 0 || qualStart+lSeq > len(rec) {
+		return nil, fmt.Errorf("invalid bam file: record fields overrun its %d bytes", len(rec))
+	}
+	name := rec[32 : 32+lReadName-1] // drop the NUL terminator
+
+	seq := make([]byte, lSeq)
+	for i := range seq {
+		packed := rec[seqStart+i/2]
+		if i%2 == 0 {
+			packed >>= 4
+		}
+		seq[i] = bamBases[packed&0x0f]
+	}
+	qual := make([]byte, lSeq)
+	for i, q := range rec[qualStart : qualStart+lSeq] {
+		if q == 0xff {
+			q = 0
+		}
+		qual[i] = byte(int(q) + b.qualBase)
+	}
+	if flag&bamFlagReverse != 0 {
+		seq = []byte(reverseComplement(string(seq)))
+		qual = []byte(reverseString(string(qual)))
+	}
+
+	return &FastqRead{
+		Header:   "@" + string(bytes.TrimRight(name, "\x00")),
+		Sequence: string(seq),
+		Quality:  string(qual),
+	}, nil
+}
+
+var complementer = strings.NewReplacer("A", "T", "C", "G", "G", "C", "T", "A")
+
+// reverseComplement returns the reverse complement of a sequence, leaving
+// bases other than A, C, G and T unchanged.
+func reverseComplement(s string) string {
+	return reverseString(complementer.Replace(s))
+}
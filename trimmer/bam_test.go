@@ -0,0 +1,138 @@
+package trimmer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bamRecord encodes one unmapped BAM record, block_size included.
+func bamRecord(name, seq string, qual []byte, flag uint16) []byte {
+	var body bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&body, le, int32(-1))       // refID
+	binary.Write(&body, le, int32(-1))       // pos
+	body.WriteByte(byte(len(name) + 1))      // l_read_name
+	body.WriteByte(0)                        // mapq
+	binary.Write(&body, le, uint16(4680))    // bin
+	binary.Write(&body, le, uint16(0))       // n_cigar_op
+	binary.Write(&body, le, flag)            // flag
+	binary.Write(&body, le, int32(len(seq))) // l_seq
+	binary.Write(&body, le, int32(-1))       // next_refID
+	binary.Write(&body, le, int32(-1))       // next_pos
+	binary.Write(&body, le, int32(0))        // tlen
+	body.WriteString(name + "\x00")
+	packed := make([]byte, (len(seq)+1)/2)
+	for i := 0; i < len(seq); i++ {
+		code := byte(strings.IndexByte(bamBases, seq[i]))
+		if i%2 == 0 {
+			code <<= 4
+		}
+		packed[i/2] |= code
+	}
+	body.Write(packed)
+	body.Write(qual)
+
+	var rec bytes.Buffer
+	binary.Write(&rec, le, int32(body.Len()))
+	rec.Write(body.Bytes())
+	return rec.Bytes()
+}
+
+func phreds(n int, q byte) []byte {
+	return bytes.Repeat([]byte{q}, n)
+}
+
+func TestProcessReadsBAMInput(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.bam")
+	outputFile := filepath.Join(dir, "out.fastq.gz")
+
+	var bam bytes.Buffer
+	bam.WriteString(bamMagic)
+	text := "@HD\tVN:1.6\tSO:unsorted\n"
+	binary.Write(&bam, binary.LittleEndian, int32(len(text)))
+	bam.WriteString(text)
+	binary.Write(&bam, binary.LittleEndian, int32(1)) // one reference
+	binary.Write(&bam, binary.LittleEndian, int32(5))
+	bam.WriteString("chr1\x00")
+	binary.Write(&bam, binary.LittleEndian, int32(1000))
+
+	forward := "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC"
+	bam.Write(bamRecord("fwd", forward, phreds(len(forward), 40), 4))
+	bam.Write(bamRecord("rev", reverseComplement(forward), phreds(len(forward), 40), 4|bamFlagReverse))
+	bam.Write(bamRecord("secondary", forward, phreds(len(forward), 40), 4|bamFlagSecondary))
+
+	f, err := os.Create(inputFile)
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	gw.Write(bam.Bytes())
+	gw.Close()
+	f.Close()
+
+	opts := Options{Adapter: "ATCACG", MinLen: 18, Min5Match: 4, MaxError: 0.1, InQualBase: 33, OutQualBase: 33}
+	stats, err := processReads(inputFile, outputFile, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalReads, "secondary records are skipped")
+	assert.Equal(t, int64(2), stats.TotalTrimmedReads)
+
+	out, err := os.Open(outputFile)
+	assert.NoError(t, err)
+	defer out.Close()
+	gr, err := gzip.NewReader(out)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	records := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		header := scanner.Text()
+		scanner.Scan()
+		records[header] = scanner.Text()
+		scanner.Scan()
+		scanner.Scan()
+		assert.Equal(t, strings.Repeat("I", 33), scanner.Text())
+	}
+	assert.Equal(t, map[string]string{
+		"@fwd": "GATCGGAAGAGCACACGTCTGAACTCCAGTCAC",
+		"@rev": "GATCGGAAGAGCACACGTCTGAACTCCAGTCAC",
+	}, records)
+}
+
+func TestBAMReaderTruncated(t *testing.T) {
+	var bam bytes.Buffer
+	bam.WriteString(bamMagic)
+	binary.Write(&bam, binary.LittleEndian, int32(0))
+	binary.Write(&bam, binary.LittleEndian, int32(0))
+	rec := bamRecord("r", "ACGT", phreds(4, 30), 4)
+	bam.Write(rec[:len(rec)-2])
+
+	_, err := newBAMReader(&bam, 33).next()
+	assert.ErrorContains(t, err, "truncated record")
+}
+
+func TestProcessStreamBAMQualBase(t *testing.T) {
+	var bam bytes.Buffer
+	bam.WriteString(bamMagic)
+	binary.Write(&bam, binary.LittleEndian, int32(0))
+	binary.Write(&bam, binary.LittleEndian, int32(0))
+	bam.Write(bamRecord("r", "ACGTACGTACGTACGTACGTTGGAATTCTCGG", phreds(32, 40), 4))
+
+	// No -inQualBase, and -qualBase auto has nothing to detect in BAM
+	// input: the qualities come out as Phred+33 either way.
+	for _, auto := range []int{0, 10} {
+		opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, AutoQualBaseReads: auto}
+		var out bytes.Buffer
+		_, err := processStream(bytes.NewReader(bam.Bytes()), &out, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, "@r\nACGTACGTACGTACGTACGT\n+\n"+strings.Repeat("I", 20)+"\n", out.String(), "auto=%d", auto)
+	}
+}
@@ -0,0 +1,71 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// headerBarcode returns the sample barcode from an Illumina (CASAVA 1.8+)
+// header, the last colon-separated field of the description as in
+// "@ID 1:N:0:ACGTAC", or "" if the header has no description.
+func headerBarcode(header string) string {
+	i := strings.IndexAny(header, " \t")
+	if i < 0 {
+		return ""
+	}
+	desc := strings.TrimSpace(header[i:])
+	return desc[strings.LastIndexByte(desc, ':')+1:]
+}
+
+func LoadBarcodeAdapters(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseBarcodeAdapters(f)
+}
+
+// parseBarcodeAdapters reads a barcode to adapter mapping with one
+// whitespace-separated pair per line, e.g.
+//
+//	ACGTAC  TGGAATTCTCGGGTGCCAAGG
+//	GGTTCA  AGATCGGAAGAGCACACGTCT
+//
+// Blank lines and '#' comments are ignored.
+func parseBarcodeAdapters(r io.Reader) (map[string]string, error) {
+	adapters := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want a barcode and an adapter, got %d fields", lineNo, len(fields))
+		}
+		barcode := strings.ToUpper(fields[0])
+		if _, dup := adapters[barcode]; dup {
+			return nil, fmt.Errorf("line %d: barcode %s listed twice", lineNo, barcode)
+		}
+		adapter, err := NormalizeAdapter(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		adapters[barcode] = adapter
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(adapters) == 0 {
+		return nil, fmt.Errorf("no barcodes listed")
+	}
+	return adapters, nil
+}
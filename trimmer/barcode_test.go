@@ -0,0 +1,60 @@
+package trimmer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderBarcode(t *testing.T) {
+	assert.Equal(t, "ACGTAC", headerBarcode("@M00123:8:000-A:1:1101:15589:1331 1:N:0:ACGTAC"))
+	assert.Equal(t, "ACGTAC+GGTTCA", headerBarcode("@READ1 2:N:0:ACGTAC+GGTTCA"))
+	assert.Equal(t, "", headerBarcode("@READ1"))
+}
+
+func TestParseBarcodeAdapters(t *testing.T) {
+	got, err := parseBarcodeAdapters(strings.NewReader("# sample sheet\nacgtac TGGAATTCTCGG\n\nGGTTCA\tagatcggaagag # lower case\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"ACGTAC": "TGGAATTCTCGG", "GGTTCA": "AGATCGGAAGAG"}, got)
+
+	_, err = parseBarcodeAdapters(strings.NewReader("ACGTAC TGGAATTCTCGG\nACGTAC AGATCGGAAGAG\n"))
+	assert.Error(t, err, "duplicate barcode")
+	_, err = parseBarcodeAdapters(strings.NewReader("ACGTAC\n"))
+	assert.Error(t, err, "missing adapter")
+	_, err = parseBarcodeAdapters(strings.NewReader("ACGTAC TGGXATTC\n"))
+	assert.Error(t, err, "invalid base")
+	_, err = parseBarcodeAdapters(strings.NewReader("# empty\n"))
+	assert.Error(t, err)
+}
+
+func TestTrimReadAdapterByBarcode(t *testing.T) {
+	opts := &Options{
+		Adapter:   "TGGAATTCTCGG",
+		Min5Match: 8,
+		MinLen:    5,
+		BarcodeAdapters: map[string]string{
+			"ACGTAC": "TGGAATTCTCGG",
+			"GGTTCA": "AGATCGGAAGAG",
+		},
+	}
+	opts.prepare()
+	insert := "CCCCAAAAGGGG"
+	qual := func(seq string) string { return strings.Repeat("I", len(seq)) }
+
+	// Each sample's read carries both adapters; only its own is cut at.
+	seq1 := insert + "TGGAATTCTCGG" + "AGATCGGAAGAG"
+	trimmed, err := TrimRead(&FastqRead{Header: "@R1 1:N:0:ACGTAC", Sequence: seq1, Quality: qual(seq1)}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, insert, trimmed.Sequence)
+
+	seq2 := insert + "AGATCGGAAGAG" + "TGGAATTCTCGG"
+	trimmed, err = TrimRead(&FastqRead{Header: "@R2 1:N:0:GGTTCA", Sequence: seq2, Quality: qual(seq2)}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, insert, trimmed.Sequence)
+
+	_, err = TrimRead(&FastqRead{Header: "@R3 1:N:0:TTTTTT", Sequence: seq1, Quality: qual(seq1)}, opts)
+	assert.ErrorIs(t, err, ErrUnknownBarcode)
+	_, err = TrimRead(&FastqRead{Header: "@R4", Sequence: seq1, Quality: qual(seq1)}, opts)
+	assert.ErrorIs(t, err, ErrUnknownBarcode)
+}
@@ -0,0 +1,111 @@
+package trimmer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+// benchResult is one -benchThreads run over the sample.
+type benchResult struct {
+	Threads int
+	Reads   int64
+	Elapsed time.Duration
+}
+
+func (r benchResult) readsPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Reads) / r.Elapsed.Seconds()
+}
+
+// loadBenchSample reads up to n reads from inputFile and returns them as
+// plain FASTQ, so every benchmark run starts from the same bytes in memory.
+func loadBenchSample(inputFile string, n int, opts *Options) ([]byte, error) {
+	r, err := openMaybeCompressed(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var source readSource
+	if buffered := bufio.NewReader(r); isBAM(buffered) {
+		source = newBAMReader(buffered, opts.qualBase())
+	} else {
+		source = newFastqReader(buffered, opts)
+	}
+	reads, err := readSample(source, n)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	for _, read := range reads {
+		writeRecord(w, read, &Options{})
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// benchThreads trims sample once at each thread count, as GOMAXPROCS,
+// throwing the output away. Side outputs are switched off as for the
+// -twoPass survey.
+func benchThreads(sample []byte, threads []int, opts Options) ([]benchResult, error) {
+	opts = opts.surveyOptions()
+	opts.StatsInterval, opts.ProgressInterval = 0, 0
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	var results []benchResult
+	for _, n := range threads {
+		runtime.GOMAXPROCS(n)
+		start := time.Now()
+		stats, err := processStream(bytes.NewReader(sample), io.Discard, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%d threads: %v", n, err)
+		}
+		results = append(results, benchResult{Threads: n, Reads: stats.TotalReads, Elapsed: time.Since(start)})
+	}
+	return results, nil
+}
+
+// writeBenchTable prints throughput at each thread count, per thread and
+// relative to the first row.
+func writeBenchTable(w io.Writer, results []benchResult) {
+	fmt.Fprintf(w, "%8s %12s %14s %14s %8s %10s\n", "Threads", "Time", "Reads/s", "Reads/s/thread", "Speedup", "Efficiency")
+	for _, r := range results {
+		speedup := 0.0
+		if base := results[0].readsPerSecond(); base > 0 {
+			speedup = r.readsPerSecond() / base
+		}
+		efficiency := speedup * float64(results[0].Threads) / float64(r.Threads)
+		fmt.Fprintf(w, "%8d %12s %14.0f %14.0f %7.2fx %9.1f%%\n",
+			r.Threads, r.Elapsed.Round(time.Microsecond), r.readsPerSecond(), r.readsPerSecond()/float64(r.Threads), speedup, efficiency*100)
+	}
+}
+
+// BenchmarkThreads trims the first sampleReads reads of inputFile at each
+// thread count and prints the scaling table, without writing any output.
+func BenchmarkThreads(inputFile string, threads []int, sampleReads int, opts Options) error {
+	sample, err := loadBenchSample(inputFile, sampleReads, &opts)
+	if err != nil {
+		return err
+	}
+	if len(sample) == 0 {
+		return fmt.Errorf("%s has no reads to benchmark with", inputFile)
+	}
+	results, err := benchThreads(sample, threads, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Trimming %s reads from %s\n", Comma(results[0].Reads), inputFile)
+	writeBenchTable(os.Stdout, results)
+	return nil
+}
@@ -0,0 +1,54 @@
+package trimmer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBenchThreadsTable(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&input, "@R%d\nACGTTGCAACGTTGCAACGTTGCAATCACGTT\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJ\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "in.fastq")
+	assert.NoError(t, os.WriteFile(path, []byte(input.String()), 0644))
+	opts := Options{Adapter: "ATCACG", MinLen: 10, Min5Match: 6, MaxError: 0.1, TraceFile: filepath.Join(t.TempDir(), "trace.tsv"), TraceFraction: 1}
+
+	sample, err := loadBenchSample(path, 200, &opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, strings.Count(string(sample), "\n+\n"))
+
+	results, err := benchThreads(sample, []int{1, 2}, opts)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	for _, r := range results {
+		assert.Equal(t, int64(200), r.Reads)
+	}
+	assert.NoFileExists(t, opts.TraceFile, "side outputs are off while benchmarking")
+
+	var buf bytes.Buffer
+	writeBenchTable(&buf, results)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"Threads", "Time", "Reads/s", "Reads/s/thread", "Speedup", "Efficiency"}, strings.Fields(lines[0]))
+	assert.Equal(t, "1", strings.Fields(lines[1])[0])
+	assert.Equal(t, "1.00x", strings.Fields(lines[1])[4])
+	assert.Equal(t, "2", strings.Fields(lines[2])[0])
+}
+
+func TestWriteBenchTableScaling(t *testing.T) {
+	var buf bytes.Buffer
+	writeBenchTable(&buf, []benchResult{
+		{Threads: 1, Reads: 1000, Elapsed: time.Second},
+		{Threads: 4, Reads: 1000, Elapsed: 500 * time.Millisecond},
+	})
+	fields := strings.Fields(strings.Split(buf.String(), "\n")[2])
+	assert.Equal(t, []string{"4", "500ms", "2000", "500", "2.00x", "50.0%"}, fields)
+}
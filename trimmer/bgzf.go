@@ -0,0 +1,153 @@
+package trimmer
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// bgzfBlockData is the most uncompressed data put in one BGZF block, the
+// same limit htslib uses so a block always fits in 64 KiB compressed.
+const bgzfBlockData = 0xff00
+
+// bgzfEOF is the empty block that ends every BGZF file.
+var bgzfEOF = []byte{
+	0x1f, 0x8b, 0x08, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0x06, 0x00, 0x42, 0x43, 0x02, 0x00,
+	0x1b, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// gziEntry is where a BGZF block starts in the compressed file and in the
+// uncompressed data.
+type gziEntry struct {
+	Compressed, Uncompressed uint64
+}
+
+// gziIndex collects the block starts of a BGZF output for its .gzi index.
+type gziIndex struct {
+	entries []gziEntry
+}
+
+// writeTo writes the index in the htslib .gzi layout: the number of
+// entries, then each entry's compressed and uncompressed offsets, all as
+// little-endian uint64. The first block, always at 0/0, is left out.
+func (g *gziIndex) writeTo(w io.Writer) error {
+	var entries []gziEntry
+	if len(g.entries) > 1 {
+		entries = g.entries[1:]
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(len(entries))); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, entries)
+}
+
+// gziSuffix names the index written next to each BGZF output under -gzi.
+const gziSuffix = ".gzi"
+
+func (g *gziIndex) writeFile(outputFile string) error {
+	f, err := os.Create(outputFile + gziSuffix)
+	if err != nil {
+		return err
+	}
+	if err := g.writeTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// bgzfWriter compresses into BGZF: a series of gzip members of at most 64
+// KiB each, whose sizes are recorded in a BC extra field so readers can
+// seek to any block. Blocks are recorded in index, if it is not nil.
+type bgzfWriter struct {
+	w            io.Writer
+	buf          []byte
+	compressed   bytes.Buffer
+	fw           *flate.Writer
+	index        *gziIndex
+	offset       uint64 // compressed bytes written so far
+	uncompressed uint64 // uncompressed bytes in the blocks written so far
+	closed       bool
+}
+
+func newBGZFWriter(w io.Writer, level int, index *gziIndex) *bgzfWriter {
+	b := &bgzfWriter{w: w, buf: make([]byte, 0, bgzfBlockData), index: index}
+	b.fw, _ = flate.NewWriter(&b.compressed, level) // level is checked by CheckGzipLevel
+	return b
+}
+
+func (b *bgzfWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := minInt(len(p), bgzfBlockData-len(b.buf))
+		b.buf = append(b.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(b.buf) == bgzfBlockData {
+			if err := b.writeBlock(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// writeBlock compresses the buffered data into one block.
+func (b *bgzfWriter) writeBlock() error {
+	b.compressed.Reset()
+	b.fw.Reset(&b.compressed)
+	if _, err := b.fw.Write(b.buf); err != nil {
+		return err
+	}
+	if err := b.fw.Close(); err != nil {
+		return err
+	}
+
+	const headerLen, footerLen = 18, 8
+	blockSize := headerLen + b.compressed.Len() + footerLen
+	if blockSize > 1<<16 {
+		return fmt.Errorf("bgzf block of %d bytes is too large", blockSize)
+	}
+	header := []byte{
+		0x1f, 0x8b, 0x08, 0x04, // magic, deflate, FEXTRA
+		0, 0, 0, 0, 0, 0xff, // mtime, xfl, os
+		6, 0, // extra length
+		'B', 'C', 2, 0, // BC subfield of 2 bytes
+		byte(blockSize - 1), byte((blockSize - 1) >> 8),
+	}
+	footer := make([]byte, footerLen)
+	binary.LittleEndian.PutUint32(footer, crc32.ChecksumIEEE(b.buf))
+	binary.LittleEndian.PutUint32(footer[4:], uint32(len(b.buf)))
+
+	for _, part := range [][]byte{header, b.compressed.Bytes(), footer} {
+		if _, err := b.w.Write(part); err != nil {
+			return err
+		}
+	}
+	if b.index != nil {
+		b.index.entries = append(b.index.entries, gziEntry{b.offset, b.uncompressed})
+	}
+	b.offset += uint64(blockSize)
+	b.uncompressed += uint64(len(b.buf))
+	b.buf = b.buf[:0]
+	return nil
+}
+
+// Close writes any buffered data and the EOF block. Later calls do nothing.
+func (b *bgzfWriter) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if len(b.buf) > 0 {
+		if err := b.writeBlock(); err != nil {
+			return err
+		}
+	}
+	_, err := b.w.Write(bgzfEOF)
+	return err
+}
@@ -0,0 +1,76 @@
+package trimmer
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBGZFGziMatchesBlocks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 3*bgzfBlockData+1234)
+	for i := range data {
+		data[i] = "ACGT"[rng.Intn(4)]
+	}
+
+	var out bytes.Buffer
+	index := &gziIndex{}
+	w := newBGZFWriter(&out, flate.DefaultCompression, index)
+	// Uneven writes must not change where blocks start.
+	for chunk := data; len(chunk) > 0; {
+		n := minInt(len(chunk), 1000+rng.Intn(50000))
+		_, err := w.Write(chunk[:n])
+		assert.NoError(t, err)
+		chunk = chunk[n:]
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, w.Close())
+
+	// Walk the blocks by their BSIZE fields.
+	file := out.Bytes()
+	var blocks []gziEntry
+	var uncompressed uint64
+	for pos := 0; pos < len(file); {
+		block := file[pos:]
+		if !assert.Equal(t, []byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff, 6, 0, 'B', 'C', 2, 0}, block[:16]) {
+			return
+		}
+		size := int(binary.LittleEndian.Uint16(block[16:18])) + 1
+		isize := binary.LittleEndian.Uint32(block[size-4 : size])
+		if isize > 0 {
+			blocks = append(blocks, gziEntry{uint64(pos), uncompressed})
+			inflated, err := io.ReadAll(flate.NewReader(bytes.NewReader(block[18 : size-8])))
+			assert.NoError(t, err)
+			assert.Len(t, inflated, int(isize))
+		} else {
+			assert.Equal(t, bgzfEOF, block[:size], "the last block is the EOF marker")
+			assert.Equal(t, len(file), pos+size)
+		}
+		uncompressed += uint64(isize)
+		pos += size
+	}
+	assert.Len(t, blocks, 4)
+	assert.Equal(t, blocks, index.entries)
+
+	var gzi bytes.Buffer
+	assert.NoError(t, index.writeTo(&gzi))
+	var n uint64
+	assert.NoError(t, binary.Read(&gzi, binary.LittleEndian, &n))
+	entries := make([]gziEntry, n)
+	assert.NoError(t, binary.Read(&gzi, binary.LittleEndian, entries))
+	assert.Equal(t, blocks[1:], entries, "the .gzi leaves out the first block")
+	assert.Zero(t, gzi.Len())
+
+	// BGZF is still ordinary multi-member gzip.
+	gr, err := gzip.NewReader(bytes.NewReader(file))
+	assert.NoError(t, err)
+	got, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+}
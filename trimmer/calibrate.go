@@ -0,0 +1,90 @@
+package trimmer
+
+import (
+	"io"
+	"math"
+	"sort"
+)
+
+// calibrateMaxError picks a maxError threshold from a sample of reads. Each
+// read is trimmed with the quality filter off and the mean error of its
+// insert recorded; the threshold is set just above the given percentile
+// (0-100] of those values, so reads at the percentile itself still pass.
+// ok is false when no read in the sample yields an insert.
+func calibrateMaxError(sample []*FastqRead, opts *Options, percentile float64) (threshold float64, ok bool) {
+	calib := *opts
+	calib.NoQualFilter = true
+	var errs []float64
+	for _, read := range sample {
+		trimmed, err := TrimRead(read, &calib)
+		if err != nil {
+			continue
+		}
+		errs = append(errs, MeanError([]byte(trimmed.Quality), calib.qualBase()))
+	}
+	if len(errs) == 0 {
+		return 0, false
+	}
+	sort.Float64s(errs)
+	rank := int(math.Ceil(percentile/100*float64(len(errs)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return math.Nextafter(errs[rank], math.Inf(1)), true
+}
+
+// QualBaseSampleReads is how many leading reads -qualBase auto inspects.
+const QualBaseSampleReads = 10000
+
+// guessQualBase infers the quality offset of a sample from its lowest
+// quality character: anything below @ can only be Phred+33. ok is false
+// for a sample without any bases.
+func guessQualBase(sample []*FastqRead) (base int, ok bool) {
+	lowest := byte(0xff)
+	for _, read := range sample {
+		for i := 0; i < len(read.Quality); i++ {
+			if read.Quality[i] < lowest {
+				lowest = read.Quality[i]
+			}
+		}
+	}
+	switch {
+	case lowest == 0xff:
+		return 0, false
+	case lowest < 64:
+		return 33, true
+	default:
+		return 64, true
+	}
+}
+
+// replaySource yields already-read reads before carrying on with rest.
+type replaySource struct {
+	reads []*FastqRead
+	rest  readSource
+}
+
+func (r *replaySource) next() (*FastqRead, error) {
+	if len(r.reads) > 0 {
+		read := r.reads[0]
+		r.reads = r.reads[1:]
+		return read, nil
+	}
+	return r.rest.next()
+}
+
+// readSample reads up to n reads from source.
+func readSample(source readSource, n int) ([]*FastqRead, error) {
+	sample := make([]*FastqRead, 0, n)
+	for len(sample) < n {
+		read, err := source.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sample = append(sample, read)
+	}
+	return sample, nil
+}
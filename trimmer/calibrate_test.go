@@ -0,0 +1,108 @@
+package trimmer
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalibrateMaxError(t *testing.T) {
+	insert := "ACGTACGTACGTACGTACGT"
+	var sample []*FastqRead
+	// Ten reads with uniform insert qualities Phred 40, 38, ..., 22.
+	for i := 0; i < 10; i++ {
+		q := string(rune('I' - 2*i))
+		sample = append(sample, &FastqRead{
+			Header:   "@R",
+			Sequence: insert + "TGGAATTCTCGG",
+			Quality:  strings.Repeat(q, len(insert)) + strings.Repeat("#", 12),
+		})
+	}
+	// Without an adapter the read is never an insert and is ignored.
+	sample = append(sample, &FastqRead{Header: "@NOADAPTER", Sequence: insert, Quality: strings.Repeat("#", len(insert))})
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8, MaxError: 0.1}
+
+	threshold, ok := calibrateMaxError(sample, opts, 80)
+	assert.True(t, ok)
+	// The 8th of 10 sorted errors is Phred 26; only Phred 24 and 22 fail.
+	assert.Greater(t, threshold, PhredToError('!'+26, 33))
+	assert.Less(t, threshold, PhredToError('!'+24, 33))
+
+	calibrated := *opts
+	calibrated.MaxError = threshold
+	var kept int
+	for _, read := range sample {
+		if _, err := TrimRead(read, &calibrated); err == nil {
+			kept++
+		}
+	}
+	assert.Equal(t, 8, kept)
+
+	calibrated.MaxError, _ = calibrateMaxError(sample, opts, 100)
+	kept = 0
+	for _, read := range sample {
+		if _, err := TrimRead(read, &calibrated); err == nil {
+			kept++
+		}
+	}
+	assert.Equal(t, 10, kept, "the 100th percentile keeps every insert")
+
+	_, ok = calibrateMaxError(sample[10:], opts, 95)
+	assert.False(t, ok)
+}
+
+func TestReplaySource(t *testing.T) {
+	inner := &fastqReader{scanner: bufio.NewScanner(strings.NewReader("@A\nAC\n+\nII\n@B\nGT\n+\nII\n@C\nTT\n+\nII\n"))}
+	sample, err := readSample(inner, 2)
+	assert.NoError(t, err)
+	assert.Len(t, sample, 2)
+
+	source := &replaySource{reads: sample, rest: inner}
+	var headers []string
+	for {
+		read, err := source.next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		headers = append(headers, read.Header)
+	}
+	assert.Equal(t, []string{"@A", "@B", "@C"}, headers)
+}
+
+func TestGuessQualBase(t *testing.T) {
+	base, ok := guessQualBase([]*FastqRead{{Quality: "hhhh"}, {Quality: "BBhh"}})
+	assert.True(t, ok)
+	assert.Equal(t, 64, base)
+
+	base, ok = guessQualBase([]*FastqRead{{Quality: "hhhh"}, {Quality: "?IIJ"}})
+	assert.True(t, ok)
+	assert.Equal(t, 33, base)
+
+	_, ok = guessQualBase([]*FastqRead{{Quality: ""}})
+	assert.False(t, ok)
+}
+
+func TestQualityFilterPhred64(t *testing.T) {
+	// B is Q33 in Phred+33 but Q2 in Phred+64.
+	input := "@R1\nACGTACGTACGTACGTACGTACTGGAATTCTCGG\n+\nBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB\n" +
+		"@R2\nACGTACGTACGTACGTACGTACTGGAATTCTCGG\n+\nhhhhhhhhhhhhhhhhhhhhhhhhhhhhhhhhhh\n"
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8, MaxError: 0.1, PlainOutput: true}
+
+	stats, err := processStream(strings.NewReader(input), io.Discard, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.LowQuality, "read as Phred+33 both pass")
+
+	opts.InQualBase = 64
+	stats, err = processStream(strings.NewReader(input), io.Discard, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.LowQuality)
+
+	opts.InQualBase, opts.AutoQualBaseReads = 0, 10
+	stats, err = processStream(strings.NewReader(input), io.Discard, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.LowQuality, "auto detects Phred+64")
+}
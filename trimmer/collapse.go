@@ -0,0 +1,186 @@
+package trimmer
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// collapser counts identical trimmed sequences for -collapse. Only the
+// writer goroutine touches it. When maxUnique is set and the map reaches
+// that many sequences, it is written to a temporary file as a sorted run
+// and cleared, so memory stays bounded; the runs are merged at the end.
+type collapser struct {
+	counts    map[string]int64
+	maxUnique int
+	dir       string
+	runs      []string
+	err       error // first spill error, reported by finish
+
+	maxLineLen int // the longest sequence the input can hold, and so a run
+}
+
+func newCollapser(maxUnique int, dir string, maxLineLen int) *collapser {
+	return &collapser{counts: make(map[string]int64), maxUnique: maxUnique, dir: dir, maxLineLen: maxLineLen}
+}
+
+func (c *collapser) add(sequence string) {
+	c.counts[sequence]++
+	if c.maxUnique > 0 && len(c.counts) >= c.maxUnique && c.err == nil {
+		c.err = c.spill()
+	}
+}
+
+// sortedSequences returns the keys of the in-memory map in order.
+func (c *collapser) sortedSequences() []string {
+	seqs := make([]string, 0, len(c.counts))
+	for seq := range c.counts {
+		seqs = append(seqs, seq)
+	}
+	sort.Strings(seqs)
+	return seqs
+}
+
+// spill writes the in-memory counts as a sorted "sequence\tcount" run.
+func (c *collapser) spill() error {
+	f, err := os.CreateTemp(c.dir, "scramTrimmer-collapse-*.tsv")
+	if err != nil {
+		return err
+	}
+	c.runs = append(c.runs, f.Name())
+	bw := bufio.NewWriter(f)
+	for _, seq := range c.sortedSequences() {
+		fmt.Fprintf(bw, "%s\t%d\n", seq, c.counts[seq])
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	c.counts = make(map[string]int64)
+	return f.Close()
+}
+
+// finish writes every distinct sequence, in sequence order, as a FASTA
+// record whose header carries its rank and count, e.g. >seq_00001_count_42,
+// and removes any spilled runs.
+func (c *collapser) finish(w io.Writer, eol string) error {
+	defer c.cleanup()
+	if c.err != nil {
+		return c.err
+	}
+	n := 0
+	emit := func(seq string, count int64) error {
+		n++
+		_, err := fmt.Fprintf(w, ">seq_%05d_count_%d%s%s%s", n, count, eol, seq, eol)
+		return err
+	}
+	if len(c.runs) == 0 {
+		for _, seq := range c.sortedSequences() {
+			if err := emit(seq, c.counts[seq]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(c.counts) > 0 {
+		if err := c.spill(); err != nil {
+			return err
+		}
+	}
+	return mergeRuns(c.runs, c.maxLineLen, emit)
+}
+
+func (c *collapser) cleanup() {
+	for _, run := range c.runs {
+		os.Remove(run)
+	}
+	c.runs = nil
+}
+
+// runReader yields the entries of one sorted run.
+type runReader struct {
+	f       *os.File
+	scanner *bufio.Scanner
+	seq     string
+	count   int64
+}
+
+func (r *runReader) next() (bool, error) {
+	if !r.scanner.Scan() {
+		return false, r.scanner.Err()
+	}
+	seq, countStr, ok := strings.Cut(r.scanner.Text(), "\t")
+	if !ok {
+		return false, fmt.Errorf("malformed collapse run %s", r.f.Name())
+	}
+	count, err := strconv.ParseInt(countStr, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("malformed collapse run %s: %v", r.f.Name(), err)
+	}
+	r.seq, r.count = seq, count
+	return true, nil
+}
+
+// runHeap orders run readers by their current sequence.
+type runHeap []*runReader
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return h[i].seq < h[j].seq }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// mergeRuns merges sorted runs, summing the counts of a sequence found in
+// several, and passes each sequence to emit in order. maxLineLen bounds the
+// sequences; their lines also hold a tab and the count.
+func mergeRuns(paths []string, maxLineLen int, emit func(string, int64) error) error {
+	h := &runHeap{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r := &runReader{f: f, scanner: newLineScanner(f, maxLineLen+32)}
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			*h = append(*h, r)
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		seq := (*h)[0].seq
+		var total int64
+		for h.Len() > 0 && (*h)[0].seq == seq {
+			r := (*h)[0]
+			total += r.count
+			ok, err := r.next()
+			if err != nil {
+				return err
+			}
+			if ok {
+				heap.Fix(h, 0)
+			} else {
+				heap.Pop(h)
+			}
+		}
+		if err := emit(seq, total); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package trimmer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollapserSpill(t *testing.T) {
+	seqs := []string{"CCCC", "AAAA", "GGGG", "AAAA", "TTTT", "CCCC", "AAAA", "ACGT", "GGGG"}
+	want := ">seq_00001_count_3\nAAAA\n" +
+		">seq_00002_count_1\nACGT\n" +
+		">seq_00003_count_2\nCCCC\n" +
+		">seq_00004_count_2\nGGGG\n" +
+		">seq_00005_count_1\nTTTT\n"
+
+	inMemory := newCollapser(0, "", DefaultMaxLineLen)
+	for _, s := range seqs {
+		inMemory.add(s)
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, inMemory.finish(&buf, "\n"))
+	assert.Equal(t, want, buf.String())
+
+	dir := t.TempDir()
+	spilling := newCollapser(2, dir, DefaultMaxLineLen)
+	for _, s := range seqs {
+		spilling.add(s)
+	}
+	assert.NoError(t, spilling.err)
+	assert.Greater(t, len(spilling.runs), 1, "a threshold of 2 should force several spills")
+	assert.LessOrEqual(t, len(spilling.counts), 2)
+
+	buf.Reset()
+	assert.NoError(t, spilling.finish(&buf, "\n"))
+	assert.Equal(t, want, buf.String(), "spilling must not change the counts")
+
+	left, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, left, "spill files are removed")
+}
+
+func TestProcessStreamCollapse(t *testing.T) {
+	read := "@R\nACGTACGTACGTACGTACGTTGGAATTCTCGG\n+\n" + strings.Repeat("I", 32) + "\n"
+	other := "@S\nTTTTACGTACGTACGTACGTTGGAATTCTCGG\n+\n" + strings.Repeat("I", 32) + "\n"
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, Collapse: true}
+
+	var out bytes.Buffer
+	stats, err := processStream(strings.NewReader(read+other+read+read), &out, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), stats.TotalTrimmedReads)
+	assert.Equal(t, ">seq_00001_count_3\nACGTACGTACGTACGTACGT\n>seq_00002_count_1\nTTTTACGTACGTACGTACGT\n", out.String())
+}
+
+func TestCollapserSpillLongSequence(t *testing.T) {
+	long := strings.Repeat("ACGT", 1<<18) // 1 MB, past the runs' old line limit
+	c := newCollapser(1, t.TempDir(), DefaultMaxLineLen)
+	c.add(long)
+	c.add("ACGT")
+	c.add(long)
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.finish(&buf, "\n"))
+	assert.Equal(t, ">seq_00001_count_1\nACGT\n>seq_00002_count_2\n"+long+"\n", buf.String())
+}
+
+func TestProcessReadsCollapseFile(t *testing.T) {
+	// The same insert three times, with different qualities, which
+	// collapsing discards.
+	var in strings.Builder
+	for i, qual := range []string{"I", "5", "#"} {
+		in.WriteString("@R" + strings.Repeat("x", i) + "\nACGTACGTACGTACGTACGTTGGAATTCTCGG\n+\n" + strings.Repeat(qual, 32) + "\n")
+	}
+	dir := t.TempDir()
+	inputFile, outputFile := filepath.Join(dir, "in.fq"), filepath.Join(dir, "out.fa")
+	assert.NoError(t, os.WriteFile(inputFile, []byte(in.String()), 0644))
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, Collapse: true, Quiet: true}
+
+	assert.NoError(t, ProcessReadsFast(inputFile, outputFile, opts))
+	got, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, ">seq_00001_count_3\nACGTACGTACGTACGTACGT\n", string(got))
+}
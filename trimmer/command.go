@@ -0,0 +1,36 @@
+package trimmer
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+)
+
+// shellSafe matches arguments that need no quoting in a POSIX shell.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./,:=+@%-]+$`)
+
+// shellQuote quotes s for a POSIX shell when it contains anything unsafe.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ReproduceCommand rebuilds the invocation of name with every flag in fs
+// set explicitly to its resolved value, defaults included. Flags listed in
+// omit are left out, e.g. ones whose effect is already folded into another.
+func ReproduceCommand(name string, fs *flag.FlagSet, omit ...string) string {
+	skip := make(map[string]bool, len(omit))
+	for _, o := range omit {
+		skip[o] = true
+	}
+	args := []string{shellQuote(name)}
+	fs.VisitAll(func(f *flag.Flag) {
+		if skip[f.Name] {
+			return
+		}
+		args = append(args, shellQuote("-"+f.Name+"="+f.Value.String()))
+	})
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,43 @@
+package trimmer
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "-a=TGGAATTCTCGG", shellQuote("-a=TGGAATTCTCGG"))
+	assert.Equal(t, "''", shellQuote(""))
+	assert.Equal(t, "'-o=my reads.fq.gz'", shellQuote("-o=my reads.fq.gz"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+}
+
+func TestReproduceCommandRoundTrip(t *testing.T) {
+	newFlags := func() (*flag.FlagSet, *string, *int, *bool, *time.Duration, *float64) {
+		fs := flag.NewFlagSet("scramTrimmer", flag.ContinueOnError)
+		return fs,
+			fs.String("a", "", "adapter"),
+			fs.Int("minLen", 18, "min length"),
+			fs.Bool("noQualFilter", false, "no filter"),
+			fs.Duration("statsInterval", 0, "interval"),
+			fs.Float64("min5MatchFrac", 0, "fraction")
+	}
+
+	fs, adapter, minLen, noQual, interval, _ := newFlags()
+	assert.NoError(t, fs.Parse([]string{"-a", "TGGAATTCTCGG", "-noQualFilter", "-min5MatchFrac", "0.5"}))
+	cmd := ReproduceCommand("scramTrimmer", fs, "min5MatchFrac")
+	assert.Equal(t, "scramTrimmer -a=TGGAATTCTCGG -minLen=18 -noQualFilter=true -statsInterval=0s", cmd)
+
+	fs2, adapter2, minLen2, noQual2, interval2, frac2 := newFlags()
+	args := strings.Fields(cmd)
+	assert.NoError(t, fs2.Parse(args[1:]))
+	assert.Equal(t, *adapter, *adapter2)
+	assert.Equal(t, *minLen, *minLen2)
+	assert.Equal(t, *noQual, *noQual2)
+	assert.Equal(t, *interval, *interval2)
+	assert.Zero(t, *frac2, "omitted flags keep their default")
+}
@@ -0,0 +1,233 @@
+package trimmer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+)
+
+// Magic bytes starting each compressed input format.
+const (
+	gzipMagic  = "\x1f\x8b"
+	bzip2Magic = "BZh"
+	zstdMagic  = "\x28\xb5\x2f\xfd"
+)
+
+// compressedFormat names the compression whose magic bytes start head, or
+// returns "" for none.
+func compressedFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte(gzipMagic)):
+		return "gzip"
+	case bytes.HasPrefix(head, []byte(bzip2Magic)):
+		return "bzip2"
+	case bytes.HasPrefix(head, []byte(zstdMagic)):
+		return "zstd"
+	}
+	return ""
+}
+
+// maybeDecompress returns r decompressed if it starts with the gzip, bzip2
+// or zstd magic bytes and as it is otherwise, along with a function
+// releasing the decompressor. A completely empty r gives io.EOF.
+func maybeDecompress(r io.Reader) (io.Reader, func(), error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(zstdMagic))
+	if len(head) == 0 && err == io.EOF {
+		return nil, nil, io.EOF
+	}
+	switch compressedFormat(head) {
+	case "gzip":
+		gr, err := pgzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gr, func() { gr.Close() }, nil
+	case "bzip2":
+		return bzip2.NewReader(br), func() {}, nil
+	case "zstd":
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	}
+	return br, func() {}, nil
+}
+
+// decompressedFile is an input file read through maybeDecompress.
+type decompressedFile struct {
+	io.Reader
+	release func()
+	f       *os.File
+}
+
+func (d *decompressedFile) Close() error {
+	d.release()
+	return d.f.Close()
+}
+
+// openMaybeCompressed opens path, decompressing it if it is gzip, bzip2 or
+// zstd. An empty file reads as no data.
+func openMaybeCompressed(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, release, err := maybeDecompress(f)
+	if err == io.EOF {
+		r, release = strings.NewReader(""), func() {}
+	} else if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &decompressedFile{Reader: r, release: release, f: f}, nil
+}
+
+// nopWriteCloser writes uncompressed output straight through.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// rsyncWindow matches the window used by gzip --rsyncable.
+const rsyncWindow = 4096
+
+// rsyncableWriter approximates gzip --rsyncable by ending the current gzip
+// member and starting a fresh one whenever the rolling sum of the last
+// rsyncWindow input bytes hits a content-defined boundary. Because the
+// boundaries depend only on nearby content, a small change in the input only
+// alters the members around it. The concatenated members form a valid
+// multi-member gzip stream.
+type rsyncableWriter struct {
+	w       io.Writer
+	gw      *gzip.Writer
+	window  [rsyncWindow]byte
+	pos     int
+	sum     uint32
+	members int
+}
+
+func newRsyncableWriter(w io.Writer, level int) *rsyncableWriter {
+	gw, _ := gzip.NewWriterLevel(w, level) // level is checked by CheckGzipLevel
+	return &rsyncableWriter{w: w, gw: gw, members: 1}
+}
+
+func (r *rsyncableWriter) Write(p []byte) (int, error) {
+	written := 0
+	start := 0
+	for i, b := range p {
+		r.sum += uint32(b) - uint32(r.window[r.pos])
+		r.window[r.pos] = b
+		r.pos = (r.pos + 1) % rsyncWindow
+		if r.sum%rsyncWindow != 0 {
+			continue
+		}
+		n, err := r.gw.Write(p[start : i+1])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		start = i + 1
+		if err := r.gw.Close(); err != nil {
+			return written, err
+		}
+		r.gw.Reset(r.w)
+		r.members++
+	}
+	n, err := r.gw.Write(p[start:])
+	return written + n, err
+}
+
+func (r *rsyncableWriter) Close() error {
+	return r.gw.Close()
+}
+
+// Limits for -gzBlockSize. pgzip refuses blocks no larger than its 16 KiB
+// dictionary tail; beyond the upper bound each in-flight block just wastes
+// memory.
+const (
+	minGzBlockSize     = 16<<10 + 1
+	maxGzBlockSize     = 64 << 20
+	defaultGzBlockSize = 1 << 20
+)
+
+// CheckGzConcurrency validates -gzBlockSize and -gzBlocks, where zero keeps
+// the pgzip default.
+func CheckGzConcurrency(blockSize, blocks int) error {
+	if blockSize != 0 && (blockSize < minGzBlockSize || blockSize > maxGzBlockSize) {
+		return fmt.Errorf("block size must be between %d and %d bytes, got %d", minGzBlockSize, maxGzBlockSize, blockSize)
+	}
+	if blocks < 0 {
+		return fmt.Errorf("block count must be positive, got %d", blocks)
+	}
+	return nil
+}
+
+// CheckGzipLevel validates -gzipLevel: 1 (fastest) to 9 (smallest),
+// pgzip.HuffmanOnly, or 0 or pgzip.DefaultCompression for the default.
+func CheckGzipLevel(level int) error {
+	if level < pgzip.HuffmanOnly || level > pgzip.BestCompression {
+		return fmt.Errorf("level must be between %d and %d, got %d", pgzip.HuffmanOnly, pgzip.BestCompression, level)
+	}
+	return nil
+}
+
+// gzipLevel is the compression level for gzip output.
+func (o *Options) gzipLevel() int {
+	if o.GzipLevel == 0 {
+		return pgzip.DefaultCompression
+	}
+	return o.GzipLevel
+}
+
+// newPgzipWriter returns a parallel gzip writer compressing at level up to
+// blocks blocks of blockSize bytes at once. Zero leaves a setting at the
+// pgzip default of 1 MiB blocks, one per CPU.
+func newPgzipWriter(w io.Writer, level, blockSize, blocks int) (*pgzip.Writer, error) {
+	gw, err := pgzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	if blockSize == 0 && blocks == 0 {
+		return gw, nil
+	}
+	if blockSize == 0 {
+		blockSize = defaultGzBlockSize
+	}
+	if blocks == 0 {
+		blocks = runtime.GOMAXPROCS(0)
+	}
+	if err := gw.SetConcurrency(blockSize, blocks); err != nil {
+		return nil, err
+	}
+	return gw, nil
+}
+
+// newOutputWriter wraps out in the compression the options ask for.
+func newOutputWriter(out io.Writer, opts *Options) (io.WriteCloser, error) {
+	if opts.PlainOutput {
+		return nopWriteCloser{out}, nil
+	}
+	if opts.Rsyncable {
+		return newRsyncableWriter(out, opts.gzipLevel()), nil
+	}
+	if opts.BGZF {
+		return newBGZFWriter(out, opts.gzipLevel(), opts.gzi), nil
+	}
+	pw, err := newPgzipWriter(out, opts.gzipLevel(), opts.GzBlockSize, opts.GzBlocks)
+	if err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
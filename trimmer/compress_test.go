@@ -0,0 +1,197 @@
+package trimmer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/pgzip"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRsyncableWriterDecompresses(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var input bytes.Buffer
+	for i := 0; i < 2000; i++ {
+		seq := make([]byte, 50)
+		for j := range seq {
+			seq[j] = "ACGT"[rng.Intn(4)]
+		}
+		input.WriteString("@READ\n" + string(seq) + "\n+\n" + string(bytes.Repeat([]byte("J"), 50)) + "\n")
+	}
+
+	var out bytes.Buffer
+	rw := newRsyncableWriter(&out, gzip.DefaultCompression)
+	// Write in uneven chunks so boundaries fall inside writes.
+	data := input.Bytes()
+	for len(data) > 0 {
+		n := 777
+		if n > len(data) {
+			n = len(data)
+		}
+		_, err := rw.Write(data[:n])
+		assert.NoError(t, err)
+		data = data[n:]
+	}
+	assert.NoError(t, rw.Close())
+	assert.Greater(t, rw.members, 1, "expected content-defined member resets")
+
+	gr, err := gzip.NewReader(&out)
+	assert.NoError(t, err)
+	got, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, input.Bytes(), got)
+}
+
+func TestCheckGzConcurrency(t *testing.T) {
+	assert.NoError(t, CheckGzConcurrency(0, 0))
+	assert.NoError(t, CheckGzConcurrency(256<<10, 4))
+	assert.Error(t, CheckGzConcurrency(16<<10, 4), "pgzip needs blocks larger than its tail")
+	assert.Error(t, CheckGzConcurrency(1<<30, 4))
+	assert.Error(t, CheckGzConcurrency(0, -1))
+}
+
+func TestPgzipWriterConcurrencyRoundTrip(t *testing.T) {
+	input := bytes.Repeat([]byte("@READ\nACGTTGGAATTCTCGG\n+\nJJJJJJJJJJJJJJJJ\n"), 20000)
+	var out bytes.Buffer
+	gw, err := newPgzipWriter(&out, pgzip.DefaultCompression, 64<<10, 2)
+	assert.NoError(t, err)
+	_, err = gw.Write(input)
+	assert.NoError(t, err)
+	assert.NoError(t, gw.Close())
+
+	gr, err := gzip.NewReader(&out)
+	assert.NoError(t, err)
+	got, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, input, got)
+}
+
+func TestProcessStreamGzipLevel(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var input strings.Builder
+	for i := 0; i < 2000; i++ {
+		input.WriteString("@R\n" + randomSequence(rng, 20, "ACGT") + "TGGAATTCTCGG\n+\n" + strings.Repeat("I", 32) + "\n")
+	}
+
+	sizes := map[int]int{}
+	for _, level := range []int{1, 9} {
+		opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, GzipLevel: level}
+		var out bytes.Buffer
+		stats, err := processStream(strings.NewReader(input.String()), &out, opts)
+		assert.NoError(t, err)
+		sizes[level] = out.Len()
+
+		gr, err := gzip.NewReader(&out)
+		assert.NoError(t, err)
+		got, err := io.ReadAll(gr)
+		assert.NoError(t, err)
+		assert.Equal(t, int(stats.TotalTrimmedReads), strings.Count(string(got), "\n+\n"), "level %d", level)
+		assert.Greater(t, stats.TotalTrimmedReads, int64(1900))
+	}
+	assert.Less(t, sizes[9], sizes[1])
+
+	assert.NoError(t, CheckGzipLevel(0))
+	assert.NoError(t, CheckGzipLevel(pgzip.HuffmanOnly))
+	assert.Error(t, CheckGzipLevel(10))
+	assert.Error(t, CheckGzipLevel(-3))
+}
+
+func benchmarkPgzipWrite(b *testing.B, blockSize, blocks int) {
+	rng := rand.New(rand.NewSource(1))
+	var input bytes.Buffer
+	for input.Len() < 8<<20 {
+		input.WriteString("@READ\n" + randomSequence(rng, 50, "ACGT") + "\n+\n" + strings.Repeat("J", 50) + "\n")
+	}
+	b.SetBytes(int64(input.Len()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gw, err := newPgzipWriter(io.Discard, pgzip.DefaultCompression, blockSize, blocks)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := gw.Write(input.Bytes()); err != nil {
+			b.Fatal(err)
+		}
+		if err := gw.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPgzipWriteDefault(b *testing.B)         { benchmarkPgzipWrite(b, 0, 0) }
+func BenchmarkPgzipWriteSmallBlocks(b *testing.B)     { benchmarkPgzipWrite(b, 128<<10, 0) }
+func BenchmarkPgzipWriteLargeBlocks(b *testing.B)     { benchmarkPgzipWrite(b, 4<<20, 0) }
+func BenchmarkPgzipWriteSingleBlock(b *testing.B)     { benchmarkPgzipWrite(b, 0, 1) }
+func BenchmarkPgzipWriteManySmallBlocks(b *testing.B) { benchmarkPgzipWrite(b, 128<<10, 32) }
+
+// bzip2Fixture and zstdFixture are "@READ1\nACGT\n+\nIIII\n" compressed by
+// the bzip2 and zstd command-line tools.
+var (
+	bzip2Fixture = []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xef, 0x1f,
+		0x26, 0x04, 0x00, 0x00, 0x03, 0xde, 0x00, 0x40, 0x10, 0x00, 0x08, 0x20,
+		0x00, 0x6e, 0xa0, 0x14, 0x00, 0x20, 0x00, 0x31, 0x00, 0xd0, 0x01, 0x09,
+		0x93, 0xd4, 0x66, 0x9a, 0x15, 0x00, 0x0b, 0xe3, 0x1b, 0x65, 0x85, 0xc6,
+		0x2d, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0xef, 0x1f, 0x26, 0x04,
+	}
+	zstdFixture = []byte{
+		0x28, 0xb5, 0x2f, 0xfd, 0x24, 0x13, 0x99, 0x00, 0x00, 0x40, 0x52, 0x45,
+		0x41, 0x44, 0x31, 0x0a, 0x41, 0x43, 0x47, 0x54, 0x0a, 0x2b, 0x0a, 0x49,
+		0x49, 0x49, 0x49, 0x0a, 0x28, 0x74, 0x77, 0x56,
+	}
+)
+
+func TestMaybeDecompress(t *testing.T) {
+	const fastq = "@READ1\nACGT\n+\nIIII\n"
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(fastq))
+	assert.NoError(t, gw.Close())
+
+	for name, input := range map[string][]byte{
+		"gzip":  gz.Bytes(),
+		"bzip2": bzip2Fixture,
+		"zstd":  zstdFixture,
+		"plain": []byte(fastq),
+	} {
+		r, release, err := maybeDecompress(bytes.NewReader(input))
+		assert.NoError(t, err, name)
+		got, err := io.ReadAll(r)
+		release()
+		assert.NoError(t, err, name)
+		assert.Equal(t, fastq, string(got), name)
+	}
+
+	_, _, err := maybeDecompress(bytes.NewReader(nil))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestOpenMaybeCompressedZstd(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "reads.fq.zst")
+	assert.NoError(t, os.WriteFile(input, zstdFixture, 0644))
+
+	r, err := openMaybeCompressed(input)
+	assert.NoError(t, err)
+	read, err := newFastqReader(r, &Options{}).next()
+	assert.NoError(t, err)
+	assert.Equal(t, "ACGT", read.Sequence)
+	assert.NoError(t, r.Close())
+
+	empty := filepath.Join(dir, "empty.fq")
+	assert.NoError(t, os.WriteFile(empty, nil, 0644))
+	r, err = openMaybeCompressed(empty)
+	assert.NoError(t, err)
+	_, err = newFastqReader(r, &Options{}).next()
+	assert.Equal(t, io.EOF, err, "an empty file holds no reads")
+	assert.NoError(t, r.Close())
+
+	_, err = openMaybeCompressed(filepath.Join(dir, "missing.fq"))
+	assert.Error(t, err)
+}
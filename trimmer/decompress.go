@@ -0,0 +1,67 @@
+package trimmer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// commandReader streams the stdout of an external decompressor. When the
+// output ends, the command is waited for and a failure is returned from
+// Read in place of io.EOF, so a crashed or failing decompressor cannot pass
+// for a short input.
+type commandReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+	err    error // returned by every Read once the output has ended
+}
+
+// startDecompressor runs command, split on whitespace, with in as its
+// stdin.
+func startDecompressor(command string, in io.Reader) (*commandReader, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty decompressor command")
+	}
+	c := &commandReader{cmd: exec.Command(args[0], args[1:]...)}
+	c.cmd.Stdin = in
+	c.cmd.Stderr = &c.stderr
+	var err error
+	if c.stdout, err = c.cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %q: %v", command, err)
+	}
+	return c, nil
+}
+
+func (c *commandReader) Read(p []byte) (int, error) {
+	if c.done {
+		return 0, c.err
+	}
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		c.done, c.err = true, io.EOF
+		if werr := c.cmd.Wait(); werr != nil {
+			c.err = fmt.Errorf("%s: %v: %s", c.cmd.Path, werr, strings.TrimSpace(c.stderr.String()))
+		}
+		return n, c.err
+	}
+	return n, err
+}
+
+// Close stops the command if its output was not read to the end.
+func (c *commandReader) Close() error {
+	if c.done {
+		return nil
+	}
+	c.done = true
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
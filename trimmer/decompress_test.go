@@ -0,0 +1,51 @@
+package trimmer
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessReadsDecompressCmd(t *testing.T) {
+	if _, err := exec.LookPath("gzip"); err != nil {
+		t.Skip("gzip not available")
+	}
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.fastq.gz")
+	outputFile := filepath.Join(dir, "out.fastq.gz")
+	read := "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC"
+	writeGzipFile(t, inputFile, "@R1\n"+read+"\n+\n"+strings.Repeat("J", len(read))+"\n")
+
+	opts := Options{Adapter: "ATCACG", MinLen: 18, Min5Match: 4, MaxError: 0.1, DecompressCmd: "gzip -dc"}
+	stats, err := processReads(inputFile, outputFile, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalReads)
+	assert.Equal(t, int64(1), stats.TotalTrimmedReads)
+
+	// Plain text is not gzip: the command fails and so must the run.
+	plain := filepath.Join(dir, "plain.fastq")
+	assert.NoError(t, os.WriteFile(plain, []byte("@R1\nACGT\n+\nIIII\n"), 0644))
+	_, err = processReads(plain, outputFile, opts)
+	assert.ErrorContains(t, err, "gzip")
+
+	opts.DecompressCmd = "no-such-decompressor -dc"
+	_, err = processReads(inputFile, outputFile, opts)
+	assert.ErrorContains(t, err, "starting")
+}
+
+func TestCommandReaderClose(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+	c, err := startDecompressor("cat", strings.NewReader(strings.Repeat("x", 1<<20)))
+	assert.NoError(t, err)
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(c, buf)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close(), "closing before the end stops the command")
+}
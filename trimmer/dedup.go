@@ -0,0 +1,74 @@
+package trimmer
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// Values for Options.DedupHeaders.
+const (
+	DedupError = "error"
+	DedupWarn  = "warn"
+	DedupDrop  = "drop"
+)
+
+// dedupSource checks read IDs for repeats as they are read. With a
+// positive window only the most recent window IDs are remembered, bounding
+// memory; otherwise every ID is. A repeat aborts the run, is reported on
+// warn, or is skipped, depending on mode.
+type dedupSource struct {
+	rest   readSource
+	mode   string
+	warn   io.Writer
+	stats  *Stats
+	seen   map[string]int // ID to number of occurrences within the window
+	window []string       // ring of recent IDs when bounded
+	pos    int
+}
+
+func newDedupSource(rest readSource, mode string, window int, warn io.Writer, stats *Stats) *dedupSource {
+	d := &dedupSource{rest: rest, mode: mode, warn: warn, stats: stats, seen: make(map[string]int)}
+	if window > 0 {
+		d.window = make([]string, window)
+	}
+	return d
+}
+
+func (d *dedupSource) next() (*FastqRead, error) {
+	for {
+		read, err := d.rest.next()
+		if err != nil {
+			return nil, err
+		}
+		id := readID(read.Header)
+		dup := d.seen[id] > 0
+		d.remember(id)
+		if !dup {
+			return read, nil
+		}
+		atomic.AddInt64(&d.stats.DuplicateHeaders, 1)
+		switch d.mode {
+		case DedupError:
+			return nil, fmt.Errorf("duplicate read ID %s", id)
+		case DedupWarn:
+			fmt.Fprintf(d.warn, "Warning: duplicate read ID %s\n", id)
+			return read, nil
+		}
+		// DedupDrop: skip the repeat
+	}
+}
+
+func (d *dedupSource) remember(id string) {
+	d.seen[id]++
+	if d.window == nil {
+		return
+	}
+	if old := d.window[d.pos]; old != "" {
+		if d.seen[old]--; d.seen[old] == 0 {
+			delete(d.seen, old)
+		}
+	}
+	d.window[d.pos] = id
+	d.pos = (d.pos + 1) % len(d.window)
+}
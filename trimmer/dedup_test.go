@@ -0,0 +1,58 @@
+package trimmer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupSource(t *testing.T) {
+	input := "@A/1\nAC\n+\nII\n@B\nAC\n+\nII\n@A/1 again\nAC\n+\nII\n@C\nAC\n+\nII\n@B\nAC\n+\nII\n"
+	readAll := func(source readSource) ([]string, error) {
+		var headers []string
+		for {
+			read, err := source.next()
+			if err == io.EOF {
+				return headers, nil
+			}
+			if err != nil {
+				return headers, err
+			}
+			headers = append(headers, read.Header)
+		}
+	}
+	newSource := func(mode string, window int, warn io.Writer, stats *Stats) readSource {
+		inner := &fastqReader{scanner: bufio.NewScanner(strings.NewReader(input))}
+		return newDedupSource(inner, mode, window, warn, stats)
+	}
+
+	var stats Stats
+	headers, err := readAll(newSource(DedupDrop, 0, nil, &stats))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@A/1", "@B", "@C"}, headers)
+	assert.Equal(t, int64(2), stats.DuplicateHeaders)
+
+	stats = Stats{}
+	var warnings bytes.Buffer
+	headers, err = readAll(newSource(DedupWarn, 0, &warnings, &stats))
+	assert.NoError(t, err)
+	assert.Len(t, headers, 5, "warn keeps the repeats")
+	assert.Equal(t, "Warning: duplicate read ID @A\nWarning: duplicate read ID @B\n", warnings.String())
+	assert.Equal(t, int64(2), stats.DuplicateHeaders)
+
+	stats = Stats{}
+	headers, err = readAll(newSource(DedupError, 0, nil, &stats))
+	assert.EqualError(t, err, "duplicate read ID @A")
+	assert.Equal(t, []string{"@A/1", "@B"}, headers)
+
+	// A window of two has forgotten @B by the time it repeats.
+	stats = Stats{}
+	headers, err = readAll(newSource(DedupDrop, 2, nil, &stats))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@A/1", "@B", "@C", "@B"}, headers)
+	assert.Equal(t, int64(1), stats.DuplicateHeaders)
+}
@@ -0,0 +1,97 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// outputDiff counts how an output differs from a previous run's output.
+type outputDiff struct {
+	Added, Removed, Changed int64
+}
+
+func (d *outputDiff) String() string {
+	return fmt.Sprintf("%s added, %s removed, %s changed", Comma(d.Added), Comma(d.Removed), Comma(d.Changed))
+}
+
+// readFastqGz calls fn for every record of a gzipped FASTQ file.
+func readFastqGz(path string, fn func(*FastqRead)) error {
+	r, err := openMaybeCompressed(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	source := newFastqReader(r, &Options{})
+	for {
+		read, err := source.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(read)
+	}
+}
+
+// diffOutputs compares the reads in current against those in previous by
+// read ID and, with compareSequence, by sequence too. The previous output
+// is held in memory while the current one is streamed past it. Each
+// difference is written to report, when non-nil, as a +, - or ~ line
+// followed by the read ID; removals come last, sorted.
+func diffOutputs(previous, current string, compareSequence bool, report io.Writer) (*outputDiff, error) {
+	prev := make(map[string]string)
+	err := readFastqGz(previous, func(read *FastqRead) {
+		prev[readID(read.Header)] = read.Sequence
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", previous, err)
+	}
+
+	var w *bufio.Writer
+	if report != nil {
+		w = bufio.NewWriter(report)
+	}
+	note := func(mark, id string) {
+		if w != nil {
+			fmt.Fprintf(w, "%s\t%s\n", mark, id)
+		}
+	}
+
+	var diff outputDiff
+	err = readFastqGz(current, func(read *FastqRead) {
+		id := readID(read.Header)
+		seq, ok := prev[id]
+		switch {
+		case !ok:
+			diff.Added++
+			note("+", id)
+		case compareSequence && seq != read.Sequence:
+			diff.Changed++
+			note("~", id)
+		}
+		delete(prev, id)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", current, err)
+	}
+
+	removed := make([]string, 0, len(prev))
+	for id := range prev {
+		removed = append(removed, id)
+	}
+	sort.Strings(removed)
+	for _, id := range removed {
+		note("-", id)
+	}
+	diff.Removed = int64(len(removed))
+
+	if w != nil {
+		if err := w.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return &diff, nil
+}
@@ -0,0 +1,44 @@
+package trimmer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeGzipFile(t *testing.T, path, content string) {
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte(content))
+	assert.NoError(t, gw.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestDiffOutputs(t *testing.T) {
+	dir := t.TempDir()
+	previous := filepath.Join(dir, "prev.fastq.gz")
+	current := filepath.Join(dir, "cur.fastq.gz")
+	writeGzipFile(t, previous, "@A len=4\nACGT\n+\nIIII\n@B\nACGT\n+\nIIII\n@C\nACGT\n+\nIIII\n@D\nAC\n+\nII\n")
+	writeGzipFile(t, current, "@A\nACGT\n+\nIIII\n@C\nACG\n+\nIII\n@E\nACGT\n+\nIIII\n")
+
+	var report bytes.Buffer
+	diff, err := diffOutputs(previous, current, false, &report)
+	assert.NoError(t, err)
+	assert.Equal(t, outputDiff{Added: 1, Removed: 2}, *diff)
+	assert.Equal(t, "+\t@E\n-\t@B\n-\t@D\n", report.String())
+
+	report.Reset()
+	diff, err = diffOutputs(previous, current, true, &report)
+	assert.NoError(t, err)
+	assert.Equal(t, outputDiff{Added: 1, Removed: 2, Changed: 1}, *diff)
+	assert.Equal(t, "~\t@C\n+\t@E\n-\t@B\n-\t@D\n", report.String())
+	assert.Equal(t, "1 added, 2 removed, 1 changed", diff.String())
+
+	_, err = diffOutputs(filepath.Join(dir, "missing.fastq.gz"), current, false, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,32 @@
+package trimmer_test
+
+import (
+	"fmt"
+	"strings"
+
+	"scramTrimmer/trimmer"
+)
+
+func ExampleTrimRead() {
+	opts := &trimmer.Options{
+		Adapter:      "TGGAATTCTCGGGTGCCAAGG",
+		MinLen:       18,
+		Min5Match:    8,
+		NoQualFilter: true,
+	}
+	insert := "TGAGGTAGTAGGTTGTATAGTT"
+	seq := insert + "TGGAATTCTCGG"
+	read := &trimmer.FastqRead{
+		Header:   "@read1",
+		Sequence: seq,
+		Quality:  strings.Repeat("I", len(seq)),
+	}
+
+	trimmed, err := trimmer.TrimRead(read, opts)
+	if err != nil {
+		fmt.Println("dropped:", err)
+		return
+	}
+	fmt.Println(trimmed.Sequence)
+	// Output: TGAGGTAGTAGGTTGTATAGTT
+}
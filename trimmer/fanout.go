@@ -0,0 +1,59 @@
+package trimmer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type namedWriter struct {
+	name string
+	w    io.Writer
+}
+
+// fanoutWriter copies every write to each of its destinations. When
+// skipFailed is set a destination that errors (e.g. a FIFO whose reader
+// went away) is dropped with a warning and the remaining ones carry on;
+// otherwise the first failure is returned.
+type fanoutWriter struct {
+	dests      []namedWriter
+	skipFailed bool
+}
+
+func (f *fanoutWriter) Write(p []byte) (int, error) {
+	kept := f.dests[:0]
+	for _, d := range f.dests {
+		if _, err := d.w.Write(p); err != nil {
+			if !f.skipFailed {
+				return 0, fmt.Errorf("writing to %s: %v", d.name, err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: dropping output %s: %v\n", d.name, err)
+			continue
+		}
+		kept = append(kept, d)
+	}
+	f.dests = kept
+	if len(f.dests) == 0 {
+		return 0, fmt.Errorf("all outputs failed")
+	}
+	return len(p), nil
+}
+
+// createOutputs opens every comma-separated destination in outputFile for
+// writing. Files are created or truncated; FIFOs are opened write-only so a
+// departed reader surfaces as a write error rather than a silent stall.
+func createOutputs(outputFile string) ([]*os.File, error) {
+	var files []*os.File
+	for _, path := range strings.Split(outputFile, ",") {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			for _, opened := range files {
+				opened.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
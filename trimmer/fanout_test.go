@@ -0,0 +1,77 @@
+package trimmer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestFanoutWriter(t *testing.T) {
+	t.Run("Error on failure", func(t *testing.T) {
+		var a bytes.Buffer
+		f := &fanoutWriter{dests: []namedWriter{{"a", &a}, {"b", failingWriter{}}}}
+		_, err := f.Write([]byte("data"))
+		assert.EqualError(t, err, "writing to b: broken pipe")
+	})
+
+	t.Run("Skip failed destination", func(t *testing.T) {
+		var a bytes.Buffer
+		f := &fanoutWriter{dests: []namedWriter{{"a", &a}, {"b", failingWriter{}}}, skipFailed: true}
+		n, err := f.Write([]byte("one"))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		_, err = f.Write([]byte("two"))
+		assert.NoError(t, err)
+		assert.Equal(t, "onetwo", a.String())
+		assert.Len(t, f.dests, 1)
+	})
+
+	t.Run("All destinations failed", func(t *testing.T) {
+		f := &fanoutWriter{dests: []namedWriter{{"b", failingWriter{}}}, skipFailed: true}
+		_, err := f.Write([]byte("data"))
+		assert.EqualError(t, err, "all outputs failed")
+	})
+}
+
+func TestProcessReadsFastFanout(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.fastq.gz")
+	outA := filepath.Join(dir, "a.fastq.gz")
+	outB := filepath.Join(dir, "b.fastq.gz")
+
+	f, err := os.Create(inputFile)
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte("@READ1\nGATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC\n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ\n"))
+	gw.Close()
+	f.Close()
+
+	err = ProcessReadsFast(inputFile, outA+","+outB, Options{Adapter: "ATCACG", MinLen: 18, Min5Match: 4, MaxError: 0.1})
+	assert.NoError(t, err)
+
+	readAll := func(path string) string {
+		f, err := os.Open(path)
+		assert.NoError(t, err)
+		defer f.Close()
+		gr, err := gzip.NewReader(f)
+		assert.NoError(t, err)
+		data, err := io.ReadAll(gr)
+		assert.NoError(t, err)
+		return string(data)
+	}
+	a := readAll(outA)
+	assert.Equal(t, "@READ1\nGATCGGAAGAGCACACGTCTGAACTCCAGTCAC\n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJ\n", a)
+	assert.Equal(t, a, readAll(outB))
+}
@@ -0,0 +1,71 @@
+package trimmer
+
+import (
+	"fmt"
+	"io"
+)
+
+// funnelStage is the number of reads still in play after one filter.
+type funnelStage struct {
+	Name      string
+	Survivors int64
+}
+
+// funnelStages lists the filters in the order TrimRead applies them, then
+// -umiDedup, with the cumulative survivors after each. Every rejected read
+// is counted under exactly one reason, so the stages follow from the
+// per-reason counters; filters that are switched off are left out.
+func funnelStages(stats *Stats, opts *Options) []funnelStage {
+	survivors := stats.TotalReads
+	stages := []funnelStage{{"Input", survivors}}
+	drop := func(name string, rejected int64) {
+		survivors -= rejected
+		stages = append(stages, funnelStage{name, survivors})
+	}
+	if len(opts.BarcodeAdapters) > 0 {
+		drop("Barcode known", stats.UnknownBarcode)
+	}
+	if opts.MaxReadProcTime > 0 {
+		drop("Searched in time", stats.Timeout)
+	}
+	drop("Adapter found", stats.AdapterMissing)
+	if opts.DetectNoInsert {
+		drop("Insert present", stats.NoInsert)
+	}
+	drop(fmt.Sprintf("Length >= %d", opts.MinLen), stats.TooShort)
+	if opts.MaxLen > 0 {
+		drop(fmt.Sprintf("Length <= %d", opts.MaxLen), stats.TooLong)
+	}
+	if opts.MaxN > 0 {
+		drop("N bases within -maxN", stats.TooManyN)
+	}
+	if opts.qualFilterEnabled() {
+		drop("Quality passed", stats.LowQuality)
+	}
+	if opts.MinBaseQual > 0 {
+		drop(fmt.Sprintf("Every base >= Q%d", opts.MinBaseQual), stats.LowBaseQual)
+	}
+	if opts.gcFilterEnabled() {
+		drop("GC in range", stats.GCFiltered)
+	}
+	if opts.UMIDedup {
+		drop("UMI copy kept", stats.UMIDuplicates)
+	}
+	if opts.Output2 != "" && !opts.KeepSingletons {
+		drop("Partner kept", stats.Singletons)
+	}
+	return stages
+}
+
+// writeFunnel prints stages as a table of survivors, each as a percentage
+// of the input.
+func writeFunnel(w io.Writer, stages []funnelStage) {
+	fmt.Fprintf(w, "%-18s %15s %8s\n", "Stage", "Reads", "Input%")
+	for _, s := range stages {
+		pct := 0.0
+		if stages[0].Survivors > 0 {
+			pct = float64(s.Survivors) / float64(stages[0].Survivors) * 100
+		}
+		fmt.Fprintf(w, "%-18s %15s %7.2f%%\n", s.Name, Comma(s.Survivors), pct)
+	}
+}
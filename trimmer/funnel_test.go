@@ -0,0 +1,58 @@
+package trimmer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunnelStages(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.fastq.gz")
+	outputFile := filepath.Join(dir, "out.fastq.gz")
+
+	kept := "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC"
+	records := []struct{ seq, qual string }{
+		{kept, strings.Repeat("J", len(kept))},
+		{kept, strings.Repeat("J", len(kept))},
+		{kept, strings.Repeat("#", len(kept))},                 // low quality
+		{"GGGGGGGGGGGGGGGGGGGGGGGGG", strings.Repeat("J", 25)}, // adapter missing
+		{"AATCACGGGGGGGGGGGGGG", strings.Repeat("J", 20)},      // no insert
+		{"ACGTACGTATCACGGGGG", strings.Repeat("J", 18)},        // too short
+	}
+	f, err := os.Create(inputFile)
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	for _, r := range records {
+		gw.Write([]byte("@R\n" + r.seq + "\n+\n" + r.qual + "\n"))
+	}
+	gw.Close()
+	f.Close()
+
+	opts := Options{Adapter: "ATCACG", MinLen: 18, Trim5: 2, Min5Match: 4, MaxError: 0.1, DetectNoInsert: true}
+	stats, err := processReads(inputFile, outputFile, opts)
+	assert.NoError(t, err)
+
+	stages := funnelStages(stats, &opts)
+	assert.Equal(t, []funnelStage{
+		{"Input", 6},
+		{"Adapter found", 5},
+		{"Insert present", 4},
+		{"Length >= 18", 3},
+		{"Quality passed", 2},
+	}, stages)
+	assert.Equal(t, stats.TotalTrimmedReads, stages[len(stages)-1].Survivors)
+
+	opts.DetectNoInsert = false
+	opts.NoQualFilter = true
+	assert.Len(t, funnelStages(stats, &opts), 3, "disabled filters are left out")
+
+	var buf bytes.Buffer
+	writeFunnel(&buf, stages)
+	assert.Contains(t, buf.String(), "Quality passed                   2   33.33%")
+}
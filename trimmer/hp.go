@@ -0,0 +1,58 @@
+package trimmer
+
+// hpCompress collapses every homopolymer run in s to a single base. runs
+// holds the start of each run in s, plus len(s) at the end, so run i spans
+// s[runs[i]:runs[i+1]].
+func hpCompress(s string) (compressed string, runs []int) {
+	b := make([]byte, 0, len(s))
+	runs = make([]int, 0, len(s)+1)
+	for i := 0; i < len(s); i++ {
+		if i == 0 || s[i] != s[i-1] {
+			b = append(b, s[i])
+			runs = append(runs, i)
+		}
+	}
+	runs = append(runs, len(s))
+	return string(b), runs
+}
+
+// hpOptions derives the options used to search homopolymer-compressed
+// reads: every adapter is compressed too, and the seed shortened if the
+// compressed adapter no longer holds it.
+func (o *Options) hpOptions() *Options {
+	hp := *o
+	hp.HPCompressMatch, hp.BarcodeAdapters = false, nil
+	hp.Adapter, _ = hpCompress(o.Adapter)
+	hp.MoreAdapters = nil
+	for _, a := range o.MoreAdapters {
+		c, _ := hpCompress(a)
+		hp.MoreAdapters = append(hp.MoreAdapters, c)
+	}
+	shortest := len(hp.Adapter)
+	for _, a := range hp.MoreAdapters {
+		shortest = minInt(shortest, len(a))
+	}
+	hp.Min5Match = minInt(hp.Min5Match, shortest)
+	hp.WobblePos = nil
+	hp.prepare()
+	return &hp
+}
+
+// findAdapterHP runs the adapter search on the homopolymer-compressed read
+// and maps the hit back to the original read. When the adapter's first run
+// continues a run already in the read, only as many bases as that run has
+// in the adapter are taken, leaving the rest to the insert.
+func findAdapterHP(sequence string, opts *Options, dl deadline) (int, int) {
+	compressed, runs := hpCompress(sequence)
+	index, which := findAnyAdapter(compressed, opts.hp, dl)
+	if index < 0 {
+		return index, which
+	}
+	adapter := opts.adapterSeq(which)
+	firstRun := 1
+	for firstRun < len(adapter) && adapter[firstRun] == adapter[0] {
+		firstRun++
+	}
+	start, end := runs[index], runs[index+1]
+	return maxInt(start, end-firstRun), which
+}
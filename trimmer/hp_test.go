@@ -0,0 +1,53 @@
+package trimmer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHPCompress(t *testing.T) {
+	compressed, runs := hpCompress("AAACGGT")
+	assert.Equal(t, "ACGT", compressed)
+	assert.Equal(t, []int{0, 3, 4, 6, 7}, runs)
+
+	compressed, runs = hpCompress("")
+	assert.Equal(t, "", compressed)
+	assert.Equal(t, []int{0}, runs)
+}
+
+func TestFindAdapterHPCompressMatch(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, HPCompressMatch: true}
+	opts.prepare()
+	exact := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8}
+
+	tests := []struct {
+		name     string
+		sequence string
+		want     int
+	}{
+		{name: "ExactAdapter", sequence: "ACGTACGTACTGGAATTCTCGG", want: 10},
+		{name: "LongerRuns", sequence: "ACGTACGTACTGGGAATTTCTCGG", want: 10},
+		{name: "ShorterRuns", sequence: "ACGTACGTACTGAATCTCGG", want: 10},
+		// The insert's last T and the adapter's first T form one run; only
+		// one T goes to the adapter.
+		{name: "RunSpansJunction", sequence: "ACGTACGTATTGGAATTCTCGG", want: 10},
+		{name: "LongInsertRun", sequence: "ACGTACGAAAATGGAATTCTCGG", want: 11},
+		{name: "NoAdapter", sequence: "ACGTACGTACGTACGTACGT", want: -1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			index, which := findAdapterHP(tc.sequence, opts, deadline{})
+			assert.Equal(t, tc.want, index)
+			assert.Equal(t, 0, which)
+		})
+	}
+
+	assert.Equal(t, -1, findAdapter("ACGTACGTACTGGGAATTTCTCGG", exact), "run-length errors break the plain seed match")
+
+	read := &FastqRead{Header: "@R", Sequence: "ACGTACGTACGTACGTACTGGGAATTTCTCGG", Quality: "IIIIIIIIIIIIIIIIIIIIIIIIIIIIIIII"}
+	opts.MinLen = 10
+	trimmed, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "ACGTACGTACGTACGTAC", trimmed.Sequence, "the original, uncompressed insert is kept")
+}
@@ -0,0 +1,77 @@
+package trimmer
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// infoWriter writes a cutadapt --info-file line per read from multiple
+// workers to a single file.
+type infoWriter struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func newInfoWriter(w io.Writer) *infoWriter {
+	return &infoWriter{w: bufio.NewWriter(w)}
+}
+
+func (i *infoWriter) write(read *FastqRead, tr *trimTrace, opts *Options) {
+	line := infoLine(read, tr, opts)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.w.WriteString(line + "\n")
+}
+
+func (i *infoWriter) flush() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.w.Flush()
+}
+
+// infoLine formats the cutadapt --info-file columns for a read, as it was
+// searched for the adapter:
+//
+//	name  errors  start  end  left  match  right  adapter  qual.left  qual.match  qual.right
+//
+// with start and end 0-based and end exclusive; the match is cut short
+// where the adapter runs off the read. Adapters are named by number, as
+// cutadapt does for unnamed ones. A read with no adapter found gets just
+//
+//	name  -1  sequence  quality
+func infoLine(read *FastqRead, tr *trimTrace, opts *Options) string {
+	name := strings.TrimPrefix(read.Header, "@")
+	sequence, quality := read.Sequence, read.Quality
+	if opts.ReverseInput {
+		sequence, quality = reverseString(sequence), reverseString(quality)
+	}
+	if tr.AdapterIndex < 0 {
+		return strings.Join([]string{name, "-1", sequence, quality}, "\t")
+	}
+	start := tr.AdapterIndex
+	end := minInt(start+len(tr.Adapter), len(sequence))
+	return strings.Join([]string{
+		name,
+		strconv.Itoa(matchErrors(sequence[start:end], tr.Adapter)),
+		strconv.Itoa(start),
+		strconv.Itoa(end),
+		sequence[:start], sequence[start:end], sequence[end:],
+		strconv.Itoa(tr.AdapterNum),
+		quality[:start], quality[start:end], quality[end:],
+	}, "\t")
+}
+
+// matchErrors counts the positions where match differs from the start of
+// adapter. N in the adapter matches anything, as in cutadapt.
+func matchErrors(match, adapter string) int {
+	errors := 0
+	for i := 0; i < len(match); i++ {
+		if match[i] != adapter[i] && adapter[i] != 'N' {
+			errors++
+		}
+	}
+	return errors
+}
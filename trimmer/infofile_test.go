@@ -0,0 +1,56 @@
+package trimmer
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfoFileColumns(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8}
+	opts.info = newInfoWriter(&buf)
+
+	reads := []*FastqRead{
+		// Adapter with one mismatch after the seed, then a tail past it.
+		{Header: "@KEPT 1:N:0:ACGT", Sequence: "ACGTACGTACGTACGTACGTTGGAATTCTAGGCC", Quality: "ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`ab"},
+		// Adapter running off the 3' end of a read that is then too short.
+		{Header: "@SHORT", Sequence: "ACGTACGTTGGAATTCTC", Quality: "IIIIIIIIJJJJJJJJJJ"},
+		{Header: "@MISSING", Sequence: "ACGTACGTACGT", Quality: "IIIIIIIIIIII"},
+	}
+	resultsChan := make(chan *FastqRead, len(reads))
+	var wg sync.WaitGroup
+	var stats Stats
+	wg.Add(1)
+	ProcessBatch(reads, opts, resultsChan, &wg, &stats)
+	assert.NoError(t, opts.info.flush())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{
+		"KEPT 1:N:0:ACGT", "1", "20", "32",
+		"ACGTACGTACGTACGTACGT", "TGGAATTCTAGG", "CC",
+		"1",
+		"ABCDEFGHIJKLMNOPQRST", "UVWXYZ[\\]^_`", "ab",
+	}, strings.Split(lines[0], "\t"))
+	assert.Equal(t, []string{
+		"SHORT", "0", "8", "18",
+		"ACGTACGT", "TGGAATTCTC", "",
+		"1",
+		"IIIIIIII", "JJJJJJJJJJ", "",
+	}, strings.Split(lines[1], "\t"), "filtered reads are still listed")
+	assert.Equal(t, []string{"MISSING", "-1", "ACGTACGTACGT", "IIIIIIIIIIII"}, strings.Split(lines[2], "\t"))
+}
+
+func TestInfoLineNamesSecondAdapter(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", MoreAdapters: []string{"AGATCGGAAGAG"}, MinLen: 1, Min5Match: 8}
+	opts.prepare()
+	read := &FastqRead{Header: "@R1", Sequence: "ACGTAGATCGGAAGAG", Quality: "IIIIIIIIIIIIIIII"}
+	tr := newTrimTrace(read)
+	_, err := trimReadTrace(read, opts, tr)
+	assert.NoError(t, err)
+	assert.Equal(t, "R1\t0\t4\t16\tACGT\tAGATCGGAAGAG\t\t2\tIIII\tIIIIIIIIIIII\t", infoLine(read, tr, opts))
+}
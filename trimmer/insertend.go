@@ -0,0 +1,119 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// posTag introduces a read's reference position in its header description,
+// e.g. "@READ1 pos=chr2:10500:-".
+const posTag = "pos="
+
+// headerPosition parses a "pos=CHROM:POS[:STRAND]" field from the header
+// description: the 1-based reference position of the read's first base and
+// the strand it runs along, '+' unless given.
+func headerPosition(header string) (chrom string, pos int, strand byte, ok bool) {
+	i := strings.IndexAny(header, " \t")
+	if i < 0 {
+		return "", 0, 0, false
+	}
+	for _, field := range strings.Fields(header[i:]) {
+		if !strings.HasPrefix(field, posTag) {
+			continue
+		}
+		parts := strings.Split(field[len(posTag):], ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return "", 0, 0, false
+		}
+		pos, err := strconv.Atoi(parts[1])
+		if err != nil || pos < 1 {
+			return "", 0, 0, false
+		}
+		strand = '+'
+		if len(parts) == 3 {
+			if parts[2] != "+" && parts[2] != "-" {
+				return "", 0, 0, false
+			}
+			strand = parts[2][0]
+		}
+		return parts[0], pos, strand, true
+	}
+	return "", 0, 0, false
+}
+
+type refPos struct {
+	chrom string
+	pos   int // 1-based
+}
+
+// insertEndCounter tallies the reference position of the last insert base
+// of every kept read with a position in its header. Workers add to it
+// under its lock.
+type insertEndCounter struct {
+	mu     sync.Mutex
+	counts map[refPos]int64
+}
+
+func newInsertEndCounter() *insertEndCounter {
+	return &insertEndCounter{counts: make(map[refPos]int64)}
+}
+
+// add records a kept read whose insert ends before read position end.
+// Reads without a usable position are ignored.
+func (c *insertEndCounter) add(header string, end int) {
+	chrom, pos, strand, ok := headerPosition(header)
+	if !ok || end < 1 {
+		return
+	}
+	last := pos + end - 1
+	if strand == '-' {
+		last = pos - end + 1
+	}
+	if last < 1 {
+		return
+	}
+	c.mu.Lock()
+	c.counts[refPos{chrom, last}]++
+	c.mu.Unlock()
+}
+
+// writeBedGraph writes one bedGraph line per insert-end position, with
+// 0-based half-open coordinates, sorted by chromosome and position.
+func (c *insertEndCounter) writeBedGraph(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]refPos, 0, len(c.counts))
+	for k := range c.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].chrom != keys[j].chrom {
+			return keys[i].chrom < keys[j].chrom
+		}
+		return keys[i].pos < keys[j].pos
+	})
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, `track type=bedGraph name="insert ends"`)
+	for _, k := range keys {
+		fmt.Fprintf(bw, "%s\t%d\t%d\t%d\n", k.chrom, k.pos-1, k.pos, c.counts[k])
+	}
+	return bw.Flush()
+}
+
+func (c *insertEndCounter) writeBedGraphFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := c.writeBedGraph(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
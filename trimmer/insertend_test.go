@@ -0,0 +1,59 @@
+package trimmer
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderPosition(t *testing.T) {
+	chrom, pos, strand, ok := headerPosition("@R1 pos=chr2:10500:-")
+	assert.True(t, ok)
+	assert.Equal(t, "chr2", chrom)
+	assert.Equal(t, 10500, pos)
+	assert.Equal(t, byte('-'), strand)
+
+	_, _, strand, ok = headerPosition("@R1 1:N:0:ACGT pos=chrM:7")
+	assert.True(t, ok)
+	assert.Equal(t, byte('+'), strand)
+
+	for _, h := range []string{"@R1", "@R1 1:N:0:ACGT", "@R1 pos=chr1", "@R1 pos=chr1:0", "@R1 pos=chr1:5:x", "@R1 pos=:5"} {
+		_, _, _, ok := headerPosition(h)
+		assert.False(t, ok, h)
+	}
+}
+
+func TestInsertEndBedGraph(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 5, Min5Match: 8, Trim5: 2}
+	adapter := "TGGAATTCTCGG"
+	qual := func(seq string) string { return string(bytes.Repeat([]byte("I"), len(seq))) }
+	read := func(header, seq string) *FastqRead {
+		return &FastqRead{Header: header, Sequence: seq, Quality: qual(seq)}
+	}
+	reads := []*FastqRead{
+		// 10 read bases before the adapter: the insert ends at 100+10-1.
+		read("@A pos=chr1:100", "ACGTACGTAC"+adapter),
+		read("@B pos=chr1:100:+", "ACGTACGTAC"+adapter),
+		// Reverse strand: the insert ends 9 bases below the first base.
+		read("@C pos=chr1:500:-", "ACGTACGTAC"+adapter),
+		read("@D pos=chr10:20", "ACGTACGTACGT"+adapter),
+		read("@E", "ACGTACGTAC"+adapter),              // no position
+		read("@F pos=chr1:100", "ACGTACGTACGTACGTAC"), // no adapter, dropped
+	}
+
+	var stats Stats
+	stats.InsertEnds = newInsertEndCounter()
+	resultsChan := make(chan *FastqRead, len(reads))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ProcessBatch(reads, opts, resultsChan, &wg, &stats)
+
+	var buf bytes.Buffer
+	assert.NoError(t, stats.InsertEnds.writeBedGraph(&buf))
+	assert.Equal(t, "track type=bedGraph name=\"insert ends\"\n"+
+		"chr1\t108\t109\t2\n"+
+		"chr1\t490\t491\t1\n"+
+		"chr10\t30\t31\t1\n", buf.String())
+}
@@ -0,0 +1,73 @@
+package trimmer
+
+// kmerIndex finds the adapter seed by comparing 2-bit packed k-mers of the
+// read against the seed's packed prefix, built once per run. Only a rolling
+// integer compare is done per read base; the rest of a long seed is checked
+// on a hit. It returns exactly the positions strings.Index would.
+type kmerIndex struct {
+	seed string
+	k    int
+	key  uint64
+	mask uint64
+}
+
+// baseCodes maps A, C, G and T to their 2-bit codes and everything else to
+// 0xff, avoiding a switch per base in the hot loop.
+var baseCodes = func() (t [256]byte) {
+	for i := range t {
+		t[i] = 0xff
+	}
+	t['A'], t['C'], t['G'], t['T'] = 0, 1, 2, 3
+	return t
+}()
+
+// newKmerIndex returns nil if the seed contains bases other than ACGT, which
+// cannot be packed.
+func newKmerIndex(seed string) *kmerIndex {
+	k := len(seed)
+	if k > 32 {
+		k = 32
+	}
+	if k == 0 {
+		return nil
+	}
+	idx := &kmerIndex{seed: seed, k: k}
+	for i := 0; i < k; i++ {
+		code := baseIndex(seed[i])
+		if code == -1 {
+			return nil
+		}
+		idx.key = idx.key<<2 | uint64(code)
+	}
+	if k < 32 {
+		idx.mask = 1<<(2*uint(k)) - 1
+	} else {
+		idx.mask = ^uint64(0)
+	}
+	return idx
+}
+
+// index returns the leftmost position at or after from where the seed
+// occurs in sequence, or -1.
+func (idx *kmerIndex) index(sequence string, from int) int {
+	var window uint64
+	valid := 0 // number of consecutive ACGT bases in the window
+	for i := from; i < len(sequence); i++ {
+		code := baseCodes[sequence[i]]
+		if code == 0xff {
+			valid = 0
+			window = 0
+			continue
+		}
+		window = (window<<2 | uint64(code)) & idx.mask
+		valid++
+		if valid < idx.k || window != idx.key {
+			continue
+		}
+		start := i - idx.k + 1
+		if start+len(idx.seed) <= len(sequence) && sequence[start+idx.k:start+len(idx.seed)] == idx.seed[idx.k:] {
+			return start
+		}
+	}
+	return -1
+}
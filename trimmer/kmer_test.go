@@ -0,0 +1,74 @@
+package trimmer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomSequence(rng *rand.Rand, n int, alphabet string) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+func TestKmerIndexMatchesExact(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, seed := range []string{"TGGA", "TGGAATTC", "TGGAATTCTCGGGTGCCAAGGAACTCCAGTCACGG"} {
+		idx := newKmerIndex(seed)
+		assert.NotNil(t, idx)
+		for i := 0; i < 2000; i++ {
+			seq := randomSequence(rng, 60, "ACGTN")
+			if i%2 == 0 {
+				pos := rng.Intn(60)
+				seq = (seq[:pos] + seed + seq[pos:])[:60]
+			}
+			for _, from := range []int{0, 5} {
+				want := strings.Index(seq[from:], seed)
+				if want != -1 {
+					want += from
+				}
+				assert.Equal(t, want, idx.index(seq, from), "seed %s in %s from %d", seed, seq, from)
+			}
+		}
+	}
+}
+
+func TestKmerIndexUnpackableSeed(t *testing.T) {
+	assert.Nil(t, newKmerIndex("TGGNAT"))
+	assert.Nil(t, newKmerIndex(""))
+
+	// findAdapter falls back to the exact matcher.
+	opts := &Options{Adapter: "TGGNATTC", Min5Match: 6, KmerIndex: true}
+	opts.prepare()
+	assert.Equal(t, 3, findAdapter("ACGTGGNATTC", opts))
+}
+
+func TestFindAdapterKmerIndex(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, KmerIndex: true}
+	opts.prepare()
+	assert.NotNil(t, opts.kmer)
+	assert.Equal(t, 20, findAdapter("ACGTACGTACGTACGTACGTTGGAATTCTCGG", opts))
+	assert.Equal(t, -1, findAdapter("ACGTACGTACGTACGTACGTTGGAATTGTCGG", opts))
+}
+
+func benchmarkSeedSearch(b *testing.B, kmer bool) {
+	rng := rand.New(rand.NewSource(1))
+	reads := make([]string, 1000)
+	for i := range reads {
+		reads[i] = randomSequence(rng, 20+rng.Intn(10), "ACGT") + "TGGAATTCTCGGGTGCCAAGG"
+	}
+	opts := &Options{Adapter: "TGGAATTCTCGGGTGCCAAGG", Min5Match: 12, KmerIndex: kmer}
+	opts.prepare()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		findAdapter(reads[i%len(reads)], opts)
+	}
+}
+
+func BenchmarkSeedSearchExact(b *testing.B) { benchmarkSeedSearch(b, false) }
+func BenchmarkSeedSearchKmer(b *testing.B)  { benchmarkSeedSearch(b, true) }
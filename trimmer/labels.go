@@ -0,0 +1,86 @@
+package trimmer
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Fate labels written by -labelFile, one byte per read.
+const (
+	labelKept           byte = 0
+	labelAdapterMissing byte = 1
+	labelTooShort       byte = 2
+	labelLowQuality     byte = 3
+	labelNoInsert       byte = 4
+	labelTimeout        byte = 5
+	labelUnknownBarcode byte = 6
+	labelGCFiltered     byte = 7
+	labelTooManyN       byte = 8
+	labelLowBaseQual    byte = 9
+	labelTooLong        byte = 10
+
+	fateCount = int(labelTooLong) + 1 // the fates TrimRead decides
+
+	// labelUMIDuplicate replaces labelKept on the copies -umiDedup drops,
+	// once the run is over.
+	labelUMIDuplicate byte = 11
+)
+
+// fateLabels maps each TrimRead error to its label.
+var fateLabels = map[error]byte{
+	ErrAdapterMissing: labelAdapterMissing,
+	ErrTooShort:       labelTooShort,
+	ErrLowQuality:     labelLowQuality,
+	ErrNoInsert:       labelNoInsert,
+	ErrTimeout:        labelTimeout,
+	ErrUnknownBarcode: labelUnknownBarcode,
+	ErrGCFiltered:     labelGCFiltered,
+	ErrTooManyN:       labelTooManyN,
+	ErrLowBaseQual:    labelLowBaseQual,
+	ErrTooLong:        labelTooLong,
+}
+
+// fateLabel turns a TrimRead error, or nil for a kept read, into its label.
+func fateLabel(err error) byte {
+	if err == nil {
+		return labelKept
+	}
+	for reason, label := range fateLabels {
+		if errors.Is(err, reason) {
+			return label
+		}
+	}
+	return labelKept
+}
+
+// labelWriter stores each read's label at its position in the input, so
+// batches finishing out of order still produce a file aligned with the
+// reads.
+type labelWriter struct {
+	w   io.WriterAt
+	mu  sync.Mutex
+	err error
+}
+
+func newLabelWriter(w io.WriterAt) *labelWriter {
+	return &labelWriter{w: w}
+}
+
+// write stores labels for the reads starting at input position first.
+func (l *labelWriter) write(first int64, labels []byte) {
+	_, err := l.w.WriteAt(labels, first)
+	if err != nil {
+		l.mu.Lock()
+		if l.err == nil {
+			l.err = err
+		}
+		l.mu.Unlock()
+	}
+}
+
+func (l *labelWriter) error() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
@@ -0,0 +1,53 @@
+package trimmer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelFileMixed(t *testing.T) {
+	kinds := []struct {
+		sequence string
+		label    byte
+	}{
+		{"ACGTTGCAACGTTGCAATCACGTT", labelKept},
+		{"ACGTTGCAACGTTGCAACGTTGCA", labelAdapterMissing},
+		{"ACGTTGATCACGTTGCAACGTTGC", labelTooShort},
+		{"ATCACGTTGCAACGTTGCAACGTT", labelNoInsert},
+	}
+	// Enough reads for several batches, which may finish out of order.
+	const n = 25003
+	var input strings.Builder
+	want := make([]byte, n)
+	for i := 0; i < n; i++ {
+		kind := kinds[i*7%len(kinds)]
+		fmt.Fprintf(&input, "@R%d\n%s\n+\n%s\n", i, kind.sequence, strings.Repeat("J", len(kind.sequence)))
+		want[i] = kind.label
+	}
+
+	labelPath := filepath.Join(t.TempDir(), "labels.bin")
+	opts := Options{Adapter: "ATCACG", MinLen: 10, Min5Match: 6, MaxError: 0.1, DetectNoInsert: true, PlainOutput: true, LabelFile: labelPath}
+	var out bytes.Buffer
+	stats, err := processStream(strings.NewReader(input.String()), &out, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(n), stats.TotalReads)
+
+	got, err := os.ReadFile(labelPath)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestFateLabel(t *testing.T) {
+	assert.Equal(t, labelKept, fateLabel(nil))
+	for reason, label := range fateLabels {
+		assert.Equal(t, label, fateLabel(reason), reason.Error())
+		assert.Equal(t, label, fateLabel(fmt.Errorf("read 7: %w", reason)), "wrapped %v", reason)
+	}
+	assert.Len(t, fateLabels, 10)
+}
@@ -0,0 +1,92 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// lengthHist counts output reads by length. Only the writer goroutine
+// touches it, so it needs no locking and sees exactly the reads written.
+type lengthHist struct {
+	counts []int64
+}
+
+func (h *lengthHist) add(length int) {
+	for len(h.counts) <= length {
+		h.counts = append(h.counts, 0)
+	}
+	h.counts[length]++
+}
+
+// merge adds the counts of other into h.
+func (h *lengthHist) merge(other *lengthHist) {
+	for length, n := range other.counts {
+		if n > 0 {
+			h.add(length)
+			h.counts[length] += n - 1
+		}
+	}
+}
+
+func (h *lengthHist) total() int64 {
+	var n int64
+	for _, c := range h.counts {
+		n += c
+	}
+	return n
+}
+
+// writeTSV writes a length/count row for every length from the shortest
+// read to the longest.
+func (h *lengthHist) writeTSV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "length\tcount")
+	first := -1
+	for length, n := range h.counts {
+		if n > 0 && first < 0 {
+			first = length
+		}
+		if first >= 0 {
+			fmt.Fprintf(bw, "%d\t%d\n", length, n)
+		}
+	}
+	return bw.Flush()
+}
+
+func (h *lengthHist) writeTSVFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := h.writeTSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// histBarWidth is the bar length of the most common length in the summary.
+const histBarWidth = 40
+
+// writeCompact prints one bar per length seen, scaled to the most common.
+func (h *lengthHist) writeCompact(w io.Writer) {
+	var top int64
+	for _, n := range h.counts {
+		if n > top {
+			top = n
+		}
+	}
+	for length, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		bar := int(n * histBarWidth / top)
+		if bar == 0 {
+			bar = 1
+		}
+		fmt.Fprintf(w, "%4d %-*s %s\n", length, histBarWidth, strings.Repeat("#", bar), Comma(n))
+	}
+}
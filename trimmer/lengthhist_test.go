@@ -0,0 +1,49 @@
+package trimmer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLengthHistTotals(t *testing.T) {
+	var input strings.Builder
+	inserts := []string{"ACGTACGTACGTACGTACGTA", "ACGTACGTACGTACGTACGTACGT", "ACGT", "ACGTACGTACGTACGTACGTA"}
+	for i := 0; i < 30; i++ {
+		insert := inserts[i%len(inserts)]
+		seq := insert + "TGGAATTCTCGGGTGCC"
+		fmt.Fprintf(&input, "@R%d\n%s\n+\n%s\n", i, seq, strings.Repeat("I", len(seq)))
+	}
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8, MaxError: 0.1, PlainOutput: true}
+
+	stats, err := processStream(strings.NewReader(input.String()), &bytes.Buffer{}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.TotalTrimmedReads, stats.Lengths.total())
+	assert.Equal(t, int64(15), stats.Lengths.counts[21])
+	assert.Equal(t, int64(8), stats.Lengths.counts[24])
+
+	var tsv bytes.Buffer
+	assert.NoError(t, stats.Lengths.writeTSV(&tsv))
+	assert.Equal(t, "length\tcount\n21\t15\n22\t0\n23\t0\n24\t8\n", tsv.String())
+
+	var compact bytes.Buffer
+	stats.Lengths.writeCompact(&compact)
+	lines := strings.Split(strings.TrimSuffix(compact.String(), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"21", strings.Repeat("#", histBarWidth), "15"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"24", strings.Repeat("#", 21), "8"}, strings.Fields(lines[1]))
+}
+
+func TestLengthHistMerge(t *testing.T) {
+	a, b := &lengthHist{}, &lengthHist{}
+	a.add(21)
+	b.add(21)
+	b.add(24)
+	b.add(24)
+	a.merge(b)
+	assert.Equal(t, []int64{21: 2, 24: 2}, a.counts)
+	assert.Equal(t, int64(4), a.total())
+}
@@ -0,0 +1,69 @@
+package trimmer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// LengthPeak is one expected insert length in a -lengthPrior, weighted by
+// a Gaussian of standard deviation SD around Length.
+type LengthPeak struct {
+	Length float64
+	SD     float64
+}
+
+// defaultPeakSD is the spread of a -lengthPrior peak given without one.
+const defaultPeakSD = 1.5
+
+// ParseLengthPrior parses a comma-separated list of expected insert
+// lengths, each optionally with a standard deviation, e.g. "21,24:2".
+func ParseLengthPrior(spec string) ([]LengthPeak, error) {
+	var peaks []LengthPeak
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		lengthStr, sdStr, hasSD := strings.Cut(field, ":")
+		length, err := strconv.ParseFloat(lengthStr, 64)
+		if err != nil || length <= 0 {
+			return nil, fmt.Errorf("invalid length %q", lengthStr)
+		}
+		peak := LengthPeak{Length: length, SD: defaultPeakSD}
+		if hasSD {
+			if peak.SD, err = strconv.ParseFloat(sdStr, 64); err != nil || peak.SD <= 0 {
+				return nil, fmt.Errorf("invalid standard deviation %q for length %g", sdStr, length)
+			}
+		}
+		peaks = append(peaks, peak)
+	}
+	return peaks, nil
+}
+
+// lengthPriorWeight is how expected an insert of the given length is: the
+// height, from 0 to 1, of the nearest peak at that length.
+func lengthPriorWeight(peaks []LengthPeak, length int) float64 {
+	best := 0.0
+	for _, p := range peaks {
+		z := (float64(length) - p.Length) / p.SD
+		best = math.Max(best, math.Exp(-z*z/2))
+	}
+	return best
+}
+
+// priorAdapterHit picks, among every acceptable adapter position starting
+// with first, the one leaving the insert the length prior favours most.
+// Equally favoured positions go to the earliest, or the latest under
+// PreferMatch "latest".
+func priorAdapterHit(sequence string, first int, opts *Options, dl deadline) int {
+	best, bestWeight := first, -1.0
+	for hit := first; hit >= 0; hit = nextAdapterHit(sequence, hit+1, opts, dl) {
+		weight := lengthPriorWeight(opts.LengthPrior, hit-opts.Trim5)
+		if weight > bestWeight || (weight == bestWeight && opts.PreferMatch == PreferLatest) {
+			best, bestWeight = hit, weight
+		}
+	}
+	if dl.expired() {
+		return adapterTimeout
+	}
+	return best
+}
@@ -0,0 +1,56 @@
+package trimmer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLengthPrior(t *testing.T) {
+	peaks, err := ParseLengthPrior("21, 24:2")
+	assert.NoError(t, err)
+	assert.Equal(t, []LengthPeak{{21, defaultPeakSD}, {24, 2}}, peaks)
+
+	for _, bad := range []string{"", "x", "21:", "21:0", "-3", "21,,24"} {
+		_, err := ParseLengthPrior(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestLengthPriorWeight(t *testing.T) {
+	peaks := []LengthPeak{{21, 1}, {24, 1}}
+	assert.InDelta(t, 1.0, lengthPriorWeight(peaks, 21), 1e-9)
+	assert.InDelta(t, 1.0, lengthPriorWeight(peaks, 24), 1e-9)
+	assert.Greater(t, lengthPriorWeight(peaks, 22), lengthPriorWeight(peaks, 18))
+}
+
+func TestLengthPriorShiftsAdapterHit(t *testing.T) {
+	// The seed TGGAATTC occurs at 15 and again at 21; only the second is
+	// followed by the rest of the adapter, but seed matching accepts both.
+	seq := "ACGTACGTACGTACG" + "TGGAAT" + "TGGAATTCTCGG"
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 6, MinLen: 1}
+	opts.prepare()
+	assert.Equal(t, 15, findAdapter(seq, opts), "without a prior the first hit wins")
+
+	opts.LengthPrior = []LengthPeak{{21, 1.5}}
+	assert.Equal(t, 21, findAdapter(seq, opts), "the prior favours the 21 nt insert")
+
+	opts.LengthPrior = []LengthPeak{{16, 1.5}, {24, 1.5}}
+	assert.Equal(t, 15, findAdapter(seq, opts), "15 is nearer a peak than 21")
+
+	// Measured from the 5' trim: after removing 6 bases, 15 is the 9 nt insert.
+	opts.Trim5 = 6
+	opts.LengthPrior = []LengthPeak{{9, 1}}
+	assert.Equal(t, 15, findAdapter(seq, opts))
+}
+
+func TestLengthPriorScoreMatcher(t *testing.T) {
+	// With -minAdapterScore both partial matches qualify; the prior picks.
+	seq := "ACGTACGTACGTACGTACGTAC" + "TGGAATTCTAGG" + "TTTT" + "TGGAATTCTCGG"
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinAdapterScore: 8, MinLen: 1}
+	opts.prepare()
+	assert.Equal(t, 22, findAdapter(seq, opts))
+
+	opts.LengthPrior = []LengthPeak{{38, 1.5}}
+	assert.Equal(t, 38, findAdapter(seq, opts))
+}
@@ -0,0 +1,125 @@
+package trimmer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// readID is the part of a header that names the fragment: the first word,
+// without a trailing /1 or /2 mate suffix.
+func readID(header string) string {
+	id := header
+	if i := strings.IndexAny(id, " \t"); i >= 0 {
+		id = id[:i]
+	}
+	if strings.HasSuffix(id, "/1") || strings.HasSuffix(id, "/2") {
+		id = id[:len(id)-2]
+	}
+	return id
+}
+
+// mergeMates overlaps r1 with the reverse complement of r2 and, if they
+// agree over at least minOverlap bases with a mismatch fraction of at most
+// maxMismatch, returns the single consensus read. At each overlapping
+// position the base with the higher quality is kept along with its
+// quality. Only overlaps where r2 starts at or after r1 are considered.
+func mergeMates(r1, r2 *FastqRead, minOverlap int, maxMismatch float64) (*FastqRead, bool) {
+	seq2 := reverseComplement(r2.Sequence)
+	qual2 := reverseString(r2.Quality)
+
+	// Try the longest overlap first.
+	for offset := 0; offset+minOverlap <= len(r1.Sequence); offset++ {
+		overlap := minInt(len(r1.Sequence)-offset, len(seq2))
+		if overlap < minOverlap {
+			break
+		}
+		allowed := int(maxMismatch * float64(overlap))
+		mismatches := 0
+		for i := 0; i < overlap && mismatches <= allowed; i++ {
+			if r1.Sequence[offset+i] != seq2[i] {
+				mismatches++
+			}
+		}
+		if mismatches > allowed {
+			continue
+		}
+
+		seq := []byte(r1.Sequence[:offset])
+		qual := []byte(r1.Quality[:offset])
+		for i := 0; i < overlap; i++ {
+			b1, q1 := r1.Sequence[offset+i], r1.Quality[offset+i]
+			b2, q2 := seq2[i], qual2[i]
+			if q2 > q1 {
+				b1, q1 = b2, q2
+			}
+			seq = append(seq, b1)
+			qual = append(qual, q1)
+		}
+		// One mate may run past the other's end.
+		seq = append(seq, r1.Sequence[offset+overlap:]...)
+		qual = append(qual, r1.Quality[offset+overlap:]...)
+		seq = append(seq, seq2[overlap:]...)
+		qual = append(qual, qual2[overlap:]...)
+		return &FastqRead{Header: r1.Header, Sequence: string(seq), Quality: string(qual)}, true
+	}
+	return nil, false
+}
+
+// mergeMaxMismatch is the mismatch fraction tolerated in a -merge overlap.
+const mergeMaxMismatch = 0.1
+
+// mergingSource reads mates in step from r1 and r2 and yields the merged
+// read, or R1 alone when the mates do not overlap.
+type mergingSource struct {
+	r1, r2      readSource
+	minOverlap  int
+	maxMismatch float64
+	stats       *Stats
+}
+
+// nextMates reads the next read from each of r1 and r2, checking they are
+// mates. It returns io.EOF once both run out together.
+func nextMates(r1, r2 readSource) (*FastqRead, *FastqRead, error) {
+	read1, err1 := r1.next()
+	read2, err2 := r2.next()
+	if err1 != nil || err2 != nil {
+		switch {
+		case err1 != nil && err1 != io.EOF:
+			return nil, nil, err1
+		case err2 != nil && err2 != io.EOF:
+			return nil, nil, err2
+		case err1 != err2:
+			return nil, nil, fmt.Errorf("paired inputs have different numbers of reads")
+		}
+		return nil, nil, io.EOF
+	}
+	if readID(read1.Header) != readID(read2.Header) {
+		return nil, nil, fmt.Errorf("mates out of step: %s and %s", read1.Header, read2.Header)
+	}
+	return read1, read2, nil
+}
+
+func (m *mergingSource) next() (*FastqRead, error) {
+	read1, read2, err := nextMates(m.r1, m.r2)
+	if err != nil {
+		return nil, err
+	}
+	if merged, ok := mergeMates(read1, read2, m.minOverlap, m.maxMismatch); ok {
+		atomic.AddInt64(&m.stats.Merged, 1)
+		return merged, nil
+	}
+	atomic.AddInt64(&m.stats.Unmerged, 1)
+	return read1, nil
+}
+
+// openMateSource opens a FASTQ file, compressed or not, such as the R2 mates
+// for -merge. The returned function closes it.
+func openMateSource(path string, opts *Options) (readSource, func(), error) {
+	r, err := openMaybeCompressed(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newFastqReader(r, opts), func() { r.Close() }, nil
+}
@@ -0,0 +1,75 @@
+package trimmer
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeMates(t *testing.T) {
+	fragment := "ACGTTGCAAGGCTTACCGATGGCATTACGGATCCATGCAAGTCCGATTAGCCATGGTACA" // 60 bases
+	r1 := &FastqRead{Header: "@P1/1", Sequence: fragment[:40], Quality: strings.Repeat("I", 40)}
+	r2 := &FastqRead{Header: "@P1/2", Sequence: reverseComplement(fragment[20:]), Quality: strings.Repeat("I", 40)}
+
+	merged, ok := mergeMates(r1, r2, 10, 0.1)
+	assert.True(t, ok)
+	assert.Equal(t, fragment, merged.Sequence)
+	assert.Equal(t, strings.Repeat("I", 60), merged.Quality)
+	assert.Equal(t, "@P1/1", merged.Header)
+
+	// A low-quality error in R1 inside the overlap is outvoted by R2.
+	bad := []byte(r1.Sequence)
+	bad[30] = 'A'
+	badQual := []byte(r1.Quality)
+	badQual[30] = '#'
+	r1Err := &FastqRead{Header: r1.Header, Sequence: string(bad), Quality: string(badQual)}
+	merged, ok = mergeMates(r1Err, r2, 10, 0.1)
+	assert.True(t, ok)
+	assert.Equal(t, fragment, merged.Sequence)
+	assert.Equal(t, byte('I'), merged.Quality[30])
+
+	// Mates from different fragments do not merge.
+	other := &FastqRead{Header: "@P1/2", Sequence: strings.Repeat("GA", 20), Quality: strings.Repeat("I", 40)}
+	_, ok = mergeMates(r1, other, 10, 0.1)
+	assert.False(t, ok)
+
+	// Too short an overlap is not trusted.
+	_, ok = mergeMates(r1, &FastqRead{Sequence: reverseComplement(fragment[35:]), Quality: strings.Repeat("I", 25)}, 10, 0.1)
+	assert.False(t, ok)
+}
+
+func TestMergingSource(t *testing.T) {
+	fastq := func(s string) readSource {
+		return &fastqReader{scanner: bufio.NewScanner(strings.NewReader(s))}
+	}
+	fragment := "ACGTTGCAAGGCTTACCGATGGCATTACGGATCCATGCAAGTCCGATTAGCCATGGTACA"
+	q40 := strings.Repeat("I", 40)
+	r1 := "@A/1\n" + fragment[:40] + "\n+\n" + q40 + "\n" +
+		"@B/1\n" + fragment[:40] + "\n+\n" + q40 + "\n"
+	r2 := "@A/2\n" + reverseComplement(fragment[20:]) + "\n+\n" + q40 + "\n" +
+		"@B/2\n" + strings.Repeat("GA", 20) + "\n+\n" + q40 + "\n"
+
+	var stats Stats
+	source := &mergingSource{r1: fastq(r1), r2: fastq(r2), minOverlap: 10, maxMismatch: 0.1, stats: &stats}
+	read, err := source.next()
+	assert.NoError(t, err)
+	assert.Equal(t, fragment, read.Sequence)
+	read, err = source.next()
+	assert.NoError(t, err)
+	assert.Equal(t, fragment[:40], read.Sequence, "unmerged pairs continue as R1")
+	_, err = source.next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int64(1), stats.Merged)
+	assert.Equal(t, int64(1), stats.Unmerged)
+
+	source = &mergingSource{r1: fastq(r1), r2: fastq(r2[strings.Index(r2, "@B"):]), stats: &stats}
+	_, err = source.next()
+	assert.EqualError(t, err, "mates out of step: @A/1 and @B/2")
+
+	source = &mergingSource{r1: fastq(r1), r2: fastq(""), stats: &stats}
+	_, err = source.next()
+	assert.EqualError(t, err, "paired inputs have different numbers of reads")
+}
@@ -0,0 +1,117 @@
+package trimmer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// fateNames labels the -splitByMode rows, indexed by fate label.
+var fateNames = [fateCount]string{
+	labelKept:           "Kept",
+	labelAdapterMissing: "Adapter missing",
+	labelTooShort:       "Too short",
+	labelLowQuality:     "Low quality",
+	labelNoInsert:       "No insert",
+	labelTimeout:        "Timeout",
+	labelUnknownBarcode: "Unknown barcode",
+	labelGCFiltered:     "GC filtered",
+	labelTooManyN:       "Too many N",
+	labelLowBaseQual:    "Low base quality",
+	labelTooLong:        "Too long",
+}
+
+// insertFates counts reads by insert length and fate, so the counters can
+// be split at the insert-size mode once the run is over. Only reads with
+// an adapter have an insert length. Workers fill an insertFatesBatch and
+// merge it once per batch.
+type insertFates struct {
+	mu     sync.Mutex
+	counts [][fateCount]int64
+}
+
+type insertFatesBatch struct {
+	counts [][fateCount]int64
+}
+
+func (b *insertFatesBatch) add(length int, label byte) {
+	for len(b.counts) <= length {
+		b.counts = append(b.counts, [fateCount]int64{})
+	}
+	b.counts[length][label]++
+}
+
+func (f *insertFates) merge(b *insertFatesBatch) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.counts) < len(b.counts) {
+		f.counts = append(f.counts, [fateCount]int64{})
+	}
+	for length, fates := range b.counts {
+		for label, n := range fates {
+			f.counts[length][label] += n
+		}
+	}
+}
+
+// mode is the most common insert length, the shortest on a tie, or -1 if
+// no read had an insert.
+func (f *insertFates) mode() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mode, best := -1, int64(0)
+	for length, fates := range f.counts {
+		var n int64
+		for _, c := range fates {
+			n += c
+		}
+		if n > best {
+			mode, best = length, n
+		}
+	}
+	return mode
+}
+
+// split sums the counts for inserts up to and including mode, and for
+// those longer.
+func (f *insertFates) split(mode int) (atOrBelow, above [fateCount]int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for length, fates := range f.counts {
+		side := &above
+		if length <= mode {
+			side = &atOrBelow
+		}
+		for label, n := range fates {
+			side[label] += n
+		}
+	}
+	return atOrBelow, above
+}
+
+// writeModeSplit prints the insert-size mode and a block of counters for
+// each side of it. Fates seen on neither side are left out.
+func writeModeSplit(w io.Writer, f *insertFates) {
+	mode := f.mode()
+	if mode < 0 {
+		fmt.Fprintln(w, "Insert-size mode: no reads with an adapter")
+		return
+	}
+	atOrBelow, above := f.split(mode)
+	fmt.Fprintf(w, "Insert-size mode: %d nt\n", mode)
+	for _, block := range []struct {
+		title  string
+		counts [fateCount]int64
+	}{
+		{fmt.Sprintf("Inserts of %d nt or shorter", mode), atOrBelow},
+		{fmt.Sprintf("Inserts longer than %d nt", mode), above},
+	} {
+		fmt.Fprintf(w, "%s:\n", block.title)
+		for label, name := range fateNames {
+			if atOrBelow[label] == 0 && above[label] == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "  %s: %s\n", name, Comma(block.counts[label]))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package trimmer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitByModeBimodal(t *testing.T) {
+	var input strings.Builder
+	addReads := func(n, insertLen int, qual byte) {
+		for i := 0; i < n; i++ {
+			seq := strings.Repeat("ACGT", 8)[:insertLen] + "TGGAATTCTCGGGTGCC"
+			fmt.Fprintf(&input, "@R%d_%d\n%s\n+\n%s\n", insertLen, i, seq, strings.Repeat(string(qual), len(seq)))
+		}
+	}
+	addReads(10, 21, 'I')
+	addReads(6, 24, 'I')
+	addReads(3, 10, 'I')
+	addReads(2, 24, '#')
+	input.WriteString("@NOADAPTER\nACGTACGTACGTACGTACGTACGT\n+\nIIIIIIIIIIIIIIIIIIIIIIII\n")
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8, MaxError: 0.1, PlainOutput: true, SplitByMode: true}
+
+	stats, err := processStream(strings.NewReader(input.String()), &bytes.Buffer{}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 21, stats.InsertFates.mode())
+	atOrBelow, above := stats.InsertFates.split(21)
+	assert.Equal(t, int64(10), atOrBelow[labelKept])
+	assert.Equal(t, int64(3), atOrBelow[labelTooShort])
+	assert.Equal(t, int64(6), above[labelKept])
+	assert.Equal(t, int64(2), above[labelLowQuality])
+	assert.Equal(t, int64(0), atOrBelow[labelAdapterMissing]+above[labelAdapterMissing], "reads without an adapter have no insert")
+
+	var buf bytes.Buffer
+	writeModeSplit(&buf, stats.InsertFates)
+	assert.Equal(t, "Insert-size mode: 21 nt\n"+
+		"Inserts of 21 nt or shorter:\n  Kept: 10\n  Too short: 3\n  Low quality: 0\n"+
+		"Inserts longer than 21 nt:\n  Kept: 6\n  Too short: 0\n  Low quality: 2\n", buf.String())
+}
+
+func TestSplitByModeNoInserts(t *testing.T) {
+	var buf bytes.Buffer
+	writeModeSplit(&buf, &insertFates{})
+	assert.Equal(t, "Insert-size mode: no reads with an adapter\n", buf.String())
+}
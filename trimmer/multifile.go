@@ -0,0 +1,144 @@
+package trimmer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// combinedInput reads several input files one after another as a single
+// plain input. Each file is opened only once the one before it is used up,
+// and its compression is detected on its own. A file whose last line lacks
+// a newline gets one, so its last record does not run into the next file's
+// first.
+type combinedInput struct {
+	paths []string
+	cur   io.ReadCloser
+	last  byte // last byte read from cur, 0 if none
+}
+
+func newCombinedInput(paths []string) *combinedInput {
+	return &combinedInput{paths: paths}
+}
+
+func (c *combinedInput) Read(p []byte) (int, error) {
+	for {
+		if c.cur == nil {
+			if len(c.paths) == 0 {
+				return 0, io.EOF
+			}
+			r, err := openMaybeCompressed(c.paths[0])
+			if err != nil {
+				return 0, err
+			}
+			c.cur, c.paths, c.last = r, c.paths[1:], 0
+		}
+		n, err := c.cur.Read(p)
+		if n > 0 {
+			c.last = p[n-1]
+			return n, nil
+		}
+		if err == nil {
+			continue
+		}
+		if err != io.EOF {
+			return 0, err
+		}
+		c.cur.Close()
+		c.cur = nil
+		if c.last != 0 && c.last != '\n' && len(p) > 0 {
+			p[0] = '\n'
+			return 1, nil
+		}
+	}
+}
+
+func (c *combinedInput) Close() error {
+	if c.cur == nil {
+		return nil
+	}
+	return c.cur.Close()
+}
+
+// perInputOutputName names the output for inputFile inside outputDir, e.g.
+// lane1.fastq.gz becomes <outputDir>/lane1.trimmed.fastq.gz, or
+// lane1.trimmed.fastq when the output is plain.
+func perInputOutputName(outputDir, inputFile string, plain bool) string {
+	base := filepath.Base(inputFile)
+	for _, ext := range []string{".fastq.gz", ".fq.gz", ".fastq", ".fq", ".gz"} {
+		if strings.HasSuffix(base, ext) {
+			base = strings.TrimSuffix(base, ext)
+			break
+		}
+	}
+	if plain {
+		return filepath.Join(outputDir, base+".trimmed.fastq")
+	}
+	return filepath.Join(outputDir, base+".trimmed.fastq.gz")
+}
+
+// ProcessFilesParallel trims each input into its own output in outputDir,
+// running up to parallelism files at once, each with its own pipeline.
+// Per-file summaries are printed as files finish, followed by the totals.
+func ProcessFilesParallel(inputFiles []string, outputDir string, opts Options, parallelism int) error {
+	startTime := time.Now()
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	seen := make(map[string]string)
+	for _, in := range inputFiles {
+		out := perInputOutputName(outputDir, in, opts.PlainOutput)
+		if prev, ok := seen[out]; ok {
+			return fmt.Errorf("inputs %s and %s would both be written to %s", prev, in, out)
+		}
+		seen[out] = in
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		total    Stats
+		firstErr error
+	)
+	sem := make(chan struct{}, parallelism)
+
+	for _, in := range inputFiles {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(in string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fileStart := time.Now()
+			stats, err := processReads(in, perInputOutputName(outputDir, in, opts.PlainOutput), opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %v", in, err)
+				}
+				return
+			}
+			if !opts.Quiet {
+				fmt.Printf("\n== %s ==", in)
+				printSummary(os.Stdout, stats, &opts, time.Since(fileStart))
+			}
+			total.add(stats)
+		}(in)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if !opts.Quiet {
+		fmt.Printf("\n== All %d files ==", len(inputFiles))
+	}
+	return reportRun(os.Stdout, &total, &opts, time.Since(startTime))
+}
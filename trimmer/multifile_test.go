@@ -0,0 +1,95 @@
+package trimmer
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerInputOutputName(t *testing.T) {
+	assert.Equal(t, filepath.Join("out", "lane1.trimmed.fastq.gz"), perInputOutputName("out", "/data/lane1.fastq.gz", false))
+	assert.Equal(t, filepath.Join("out", "lane2.trimmed.fastq.gz"), perInputOutputName("out", "lane2.fq.gz", false))
+	assert.Equal(t, filepath.Join("out", "reads.trimmed.fastq.gz"), perInputOutputName("out", "reads", false))
+	assert.Equal(t, filepath.Join("out", "lane1.trimmed.fastq"), perInputOutputName("out", "lane1.fastq", true))
+}
+
+func TestProcessFilesParallel(t *testing.T) {
+	dir := t.TempDir()
+	outDir := filepath.Join(dir, "out")
+	assert.NoError(t, os.Mkdir(outDir, 0755))
+
+	const files = 5
+	var inputs []string
+	for i := 0; i < files; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("lane%d.fastq.gz", i))
+		f, err := os.Create(path)
+		assert.NoError(t, err)
+		gw := gzip.NewWriter(f)
+		// i+1 trimmable reads plus one without the adapter.
+		for j := 0; j <= i; j++ {
+			fmt.Fprintf(gw, "@L%d_R%d\nGATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJ\n", i, j)
+		}
+		fmt.Fprintf(gw, "@L%d_MISSING\nGGGGGGGGGGGGGGGGGGGGGGGGGGGGGG\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJ\n", i)
+		gw.Close()
+		f.Close()
+		inputs = append(inputs, path)
+	}
+
+	opts := Options{Adapter: "ATCACG", MinLen: 18, Min5Match: 4, MaxError: 0.1}
+	assert.NoError(t, ProcessFilesParallel(inputs, outDir, opts, 3))
+
+	for i, in := range inputs {
+		f, err := os.Open(perInputOutputName(outDir, in, false))
+		assert.NoError(t, err)
+		gr, err := gzip.NewReader(f)
+		assert.NoError(t, err)
+		data, err := io.ReadAll(gr)
+		assert.NoError(t, err)
+		f.Close()
+		assert.Equal(t, i+1, strings.Count(string(data), fmt.Sprintf("@L%d_", i)), "file %d", i)
+	}
+}
+
+func TestProcessFilesParallelNameClash(t *testing.T) {
+	err := ProcessFilesParallel([]string{"a/x.fastq.gz", "b/x.fq.gz"}, t.TempDir(), Options{}, 2)
+	assert.ErrorContains(t, err, "would both be written to")
+}
+
+func TestProcessReadsCombinedInputs(t *testing.T) {
+	dir := t.TempDir()
+	gzPath := filepath.Join(dir, "lane1.fastq.gz")
+	f, err := os.Create(gzPath)
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	fmt.Fprint(gw, "@L1_R1\nACGTACGTACGTACGTTGGAATTCTCGG\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJJJJ\n"+
+		"@L1_MISSING\nGGGGGGGGGGGGGGGGGGGG\n+\nJJJJJJJJJJJJJJJJJJJJ\n")
+	gw.Close()
+	f.Close()
+	// Plain, and without a newline after its last record.
+	plainPath := filepath.Join(dir, "lane2.fastq")
+	assert.NoError(t, os.WriteFile(plainPath, []byte("@L2_R1\nTTTTACGTACGTACGTTGGAATTCTCGG\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJJJJ"), 0644))
+	emptyPath := filepath.Join(dir, "lane3.fastq")
+	assert.NoError(t, os.WriteFile(emptyPath, nil, 0644))
+
+	outPath := filepath.Join(dir, "out.fastq")
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true}
+	stats, err := processReads(strings.Join([]string{gzPath, emptyPath, plainPath}, ","), outPath, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalReads)
+	assert.Equal(t, int64(2), stats.TotalTrimmedReads)
+	assert.Equal(t, int64(1), stats.AdapterMissing)
+
+	out, err := os.ReadFile(outPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "@L1_R1\nACGTACGTACGTACGT\n+\nJJJJJJJJJJJJJJJJ\n"+
+		"@L2_R1\nTTTTACGTACGTACGT\n+\nJJJJJJJJJJJJJJJJ\n", string(out))
+
+	_, err = processReads(gzPath+","+filepath.Join(dir, "missing.fastq"), outPath, opts)
+	assert.Error(t, err)
+}
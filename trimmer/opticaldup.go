@@ -0,0 +1,113 @@
+package trimmer
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tileCoord is where a cluster sat on the flow cell.
+type tileCoord struct {
+	x, y int
+}
+
+// parseTileCoords extracts the lane/tile and x/y position from an Illumina
+// read header. Both the current instrument:run:flowcell:lane:tile:x:y form
+// and the older machine:lane:tile:x:y#index/1 form end in lane:tile:x:y.
+func parseTileCoords(header string) (tile string, c tileCoord, ok bool) {
+	id := strings.TrimPrefix(header, "@")
+	if i := strings.IndexAny(id, " \t"); i >= 0 {
+		id = id[:i]
+	}
+	if i := strings.IndexAny(id, "#/"); i >= 0 {
+		id = id[:i]
+	}
+	fields := strings.Split(id, ":")
+	if len(fields) < 5 {
+		return "", c, false
+	}
+	fields = fields[len(fields)-4:]
+	x, errX := strconv.Atoi(fields[2])
+	y, errY := strconv.Atoi(fields[3])
+	if errX != nil || errY != nil {
+		return "", c, false
+	}
+	return fields[0] + ":" + fields[1], tileCoord{x, y}, true
+}
+
+// opticalDupCounter groups reads by tile and sequence and, at the end,
+// counts how many sit within maxDist pixels (in both x and y) of another
+// read with the same sequence. It is fed from the reader loop only, so it
+// needs no locking.
+type opticalDupCounter struct {
+	maxDist int
+	groups  map[string][]tileCoord
+	parsed  int64
+}
+
+func newOpticalDupCounter(maxDist int) *opticalDupCounter {
+	return &opticalDupCounter{maxDist: maxDist, groups: make(map[string][]tileCoord)}
+}
+
+func (o *opticalDupCounter) add(read *FastqRead) {
+	tile, c, ok := parseTileCoords(read.Header)
+	if !ok {
+		return
+	}
+	o.parsed++
+	key := tile + "\x00" + read.Sequence
+	o.groups[key] = append(o.groups[key], c)
+}
+
+// duplicates returns the number of reads that are optical duplicates: each
+// group of nearby identical reads counts all but one of its members.
+func (o *opticalDupCounter) duplicates() int64 {
+	var dups int64
+	for _, coords := range o.groups {
+		if len(coords) > 1 {
+			dups += int64(len(coords) - o.clusters(coords))
+		}
+	}
+	return dups
+}
+
+// clusters counts the connected groups among coords, joining any two
+// within maxDist of each other.
+func (o *opticalDupCounter) clusters(coords []tileCoord) int {
+	sort.Slice(coords, func(i, j int) bool { return coords[i].x < coords[j].x })
+	parent := make([]int, len(coords))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	n := len(coords)
+	for i := range coords {
+		for j := i + 1; j < len(coords) && coords[j].x-coords[i].x <= o.maxDist; j++ {
+			dy := coords[j].y - coords[i].y
+			if dy < 0 {
+				dy = -dy
+			}
+			if dy <= o.maxDist {
+				if a, b := find(i), find(j); a != b {
+					parent[a] = b
+					n--
+				}
+			}
+		}
+	}
+	return n
+}
+
+// rate is the optical duplicate fraction of the reads with coordinates.
+func (o *opticalDupCounter) rate() float64 {
+	if o.parsed == 0 {
+		return 0
+	}
+	return float64(o.duplicates()) / float64(o.parsed)
+}
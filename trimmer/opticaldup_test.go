@@ -0,0 +1,52 @@
+package trimmer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTileCoords(t *testing.T) {
+	tests := []struct {
+		header string
+		tile   string
+		coord  tileCoord
+		ok     bool
+	}{
+		{"@A00123:8:HXXXXDSXX:2:1101:10000:2000 1:N:0:ACGT", "2:1101", tileCoord{10000, 2000}, true},
+		{"@HWUSI-EAS100R:6:73:941:1973#0/1", "6:73", tileCoord{941, 1973}, true},
+		{"@READ1", "", tileCoord{}, false},
+		{"@A:B:C:1:1101:x:2000", "", tileCoord{}, false},
+	}
+	for _, tc := range tests {
+		tile, coord, ok := parseTileCoords(tc.header)
+		assert.Equal(t, tc.ok, ok, tc.header)
+		if tc.ok {
+			assert.Equal(t, tc.tile, tile, tc.header)
+			assert.Equal(t, tc.coord, coord, tc.header)
+		}
+	}
+}
+
+func TestOpticalDupCounter(t *testing.T) {
+	o := newOpticalDupCounter(100)
+	reads := []*FastqRead{
+		// Three identical reads chained within 100 px: two duplicates.
+		{Header: "@M:1:FC:1:1101:1000:1000", Sequence: "ACGT"},
+		{Header: "@M:1:FC:1:1101:1080:1050", Sequence: "ACGT"},
+		{Header: "@M:1:FC:1:1101:1150:1120", Sequence: "ACGT"},
+		// Same sequence but far away, or on another tile: not duplicates.
+		{Header: "@M:1:FC:1:1101:5000:5000", Sequence: "ACGT"},
+		{Header: "@M:1:FC:1:1102:1000:1000", Sequence: "ACGT"},
+		// Nearby but a different sequence.
+		{Header: "@M:1:FC:1:1101:1010:1010", Sequence: "TTTT"},
+		// No coordinates: ignored.
+		{Header: "@READ", Sequence: "ACGT"},
+	}
+	for _, r := range reads {
+		o.add(r)
+	}
+	assert.Equal(t, int64(6), o.parsed)
+	assert.Equal(t, int64(2), o.duplicates())
+	assert.InDelta(t, 2.0/6, o.rate(), 1e-9)
+}
@@ -0,0 +1,70 @@
+package trimmer
+
+import "sync"
+
+// readBatch is a batch of reads and its place among the batches of a run.
+type readBatch struct {
+	seq   int64
+	reads []*FastqRead
+}
+
+// reorderBuffer passes the kept reads of each batch on to out in batch
+// order, however the workers finish. A batch that finishes early is held
+// until every batch before it is out; a worker that gets more than window
+// batches ahead of the earliest unfinished one waits, so the buffer holds
+// at most window batches of kept reads.
+type reorderBuffer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	next    int64
+	window  int64
+	pending map[int64][]*FastqRead
+	out     chan<- *FastqRead
+}
+
+func newReorderBuffer(out chan<- *FastqRead, window int) *reorderBuffer {
+	r := &reorderBuffer{window: int64(window), pending: make(map[int64][]*FastqRead), out: out}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+// add hands over the kept reads of batch seq, sending it and any batches
+// held behind it to out once it is next.
+func (r *reorderBuffer) add(seq int64, reads []*FastqRead) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for seq >= r.next+r.window {
+		r.cond.Wait()
+	}
+	r.pending[seq] = reads
+	for {
+		ready, ok := r.pending[r.next]
+		if !ok {
+			break
+		}
+		for _, read := range ready {
+			r.out <- read
+		}
+		delete(r.pending, r.next)
+		r.next++
+		r.cond.Broadcast()
+	}
+}
+
+// trimBatch trims batch as ProcessBatch does, collecting its kept reads
+// to pass on through r rather than sending them as they are trimmed.
+func (r *reorderBuffer) trimBatch(batch readBatch, opts *Options, wg *sync.WaitGroup, stats *Stats) {
+	defer wg.Done()
+
+	kept := make(chan *FastqRead, len(batch.reads))
+	var batchWG sync.WaitGroup
+	batchWG.Add(1)
+	ProcessBatch(batch.reads, opts, kept, &batchWG, stats)
+	close(kept)
+
+	reads := make([]*FastqRead, 0, len(kept))
+	for read := range kept {
+		reads = append(reads, read)
+	}
+	r.add(batch.seq, reads)
+}
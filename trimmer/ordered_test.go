@@ -0,0 +1,52 @@
+package trimmer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessStreamOrdered(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 20000; i++ {
+		// Inserts of varying length, some too short, so batches differ
+		// in what they keep.
+		insert := strings.Repeat("ACGT", 2+i%6)
+		seq := insert + "TGGAATTCTCGG"
+		fmt.Fprintf(&input, "@R%d\n%s\n+\n%s\n", i, seq, strings.Repeat("I", len(seq)))
+	}
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, BatchSize: 7, Workers: 4, Ordered: true}
+
+	var first, second bytes.Buffer
+	_, err := processStream(strings.NewReader(input.String()), &first, opts)
+	assert.NoError(t, err)
+	_, err = processStream(strings.NewReader(input.String()), &second, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, first.String(), second.String())
+
+	opts.Workers = 1
+	var sequential bytes.Buffer
+	_, err = processStream(strings.NewReader(input.String()), &sequential, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, sequential.String(), first.String(), "the output is in input order")
+	assert.True(t, strings.HasPrefix(first.String(), "@R1\n"), "@R0 is too short")
+}
+
+func TestReorderBuffer(t *testing.T) {
+	out := make(chan *FastqRead, 10)
+	r := newReorderBuffer(out, 4)
+	r.add(2, []*FastqRead{{Header: "@C"}})
+	r.add(1, nil)
+	assert.Len(t, out, 0, "held until batch 0 is in")
+	r.add(0, []*FastqRead{{Header: "@A"}, {Header: "@B"}})
+	close(out)
+
+	var headers []string
+	for read := range out {
+		headers = append(headers, read.Header)
+	}
+	assert.Equal(t, []string{"@A", "@B", "@C"}, headers)
+}
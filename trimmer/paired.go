@@ -0,0 +1,311 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FastqReadPair is one fragment's mates, R1 from the input and R2 from
+// Input2. After trimming a nil mate is one that was dropped.
+type FastqReadPair struct {
+	R1, R2 *FastqRead
+}
+
+// pairedSource reads mates in step from r1 and r2.
+type pairedSource struct {
+	r1, r2 readSource
+}
+
+func (p *pairedSource) next() (*FastqReadPair, error) {
+	read1, read2, err := nextMates(p.r1, p.r2)
+	if err != nil {
+		return nil, err
+	}
+	return &FastqReadPair{R1: read1, R2: read2}, nil
+}
+
+// mateOptions returns the options R2 mates are trimmed with: opts with
+// Adapter2, if given, in place of the R1 adapters.
+func mateOptions(opts Options) Options {
+	if opts.Adapter2 != "" {
+		opts.Adapter = opts.Adapter2
+		opts.MoreAdapters = nil
+		opts.AdapterTrim3 = nil
+	}
+	return opts
+}
+
+// processPairedBatch trims R1 of each pair with opts and R2 with opts2.
+// Pairs with both mates kept are passed on; when only one mate is kept the
+// pair is passed on with the other nil under KeepSingletons, and dropped
+// otherwise.
+func processPairedBatch(
+	batch []*FastqReadPair,
+	opts, opts2 *Options,
+	resultsChan chan<- *FastqReadPair,
+	wg *sync.WaitGroup,
+	stats *Stats,
+) {
+	defer wg.Done()
+
+	for _, pair := range batch {
+		trimmed1, err := TrimRead(pair.R1, opts)
+		if err != nil {
+			stats.countDropped(err)
+			trimmed1 = nil
+		}
+		trimmed2, err := TrimRead(pair.R2, opts2)
+		if err != nil {
+			stats.countDropped(err)
+			trimmed2 = nil
+		}
+		for _, mate := range []*FastqRead{trimmed1, trimmed2} {
+			if mate != nil && mate.polyTrimmed {
+				atomic.AddInt64(&stats.PolyTrimmed, 1)
+			}
+		}
+
+		switch {
+		case trimmed1 != nil && trimmed2 != nil:
+			resultsChan <- &FastqReadPair{R1: trimmed1, R2: trimmed2}
+		case trimmed1 != nil || trimmed2 != nil:
+			atomic.AddInt64(&stats.Singletons, 1)
+			if opts.KeepSingletons {
+				resultsChan <- &FastqReadPair{R1: trimmed1, R2: trimmed2}
+			}
+		}
+	}
+}
+
+// writePairs writes each pair with both mates kept, R1 to writer1 and R2
+// to writer2. It is the only writer, so kept pairs reach both files in the
+// same order. A lone mate goes to single1 or single2 instead, so the pair
+// files stay in step line by line.
+func writePairs(
+	writer1, writer2, single1, single2 *bufio.Writer,
+	opts *Options,
+	resultsChan <-chan *FastqReadPair,
+	doneChan chan<- struct{},
+	stats *Stats,
+) {
+	write := func(writer *bufio.Writer, read *FastqRead) {
+		if read == nil {
+			return
+		}
+		writeRecord(writer, read, opts)
+		atomic.AddInt64(&stats.TotalTrimmedReads, 1)
+		if stats.Lengths != nil {
+			stats.Lengths.add(len(read.Sequence))
+		}
+		if stats.InsertSizes != nil {
+			stats.InsertSizes.Add(len(read.Sequence))
+		}
+		if stats.QualityDist != nil {
+			stats.QualityDist.add(read.Quality, opts.qualBase())
+		}
+	}
+	for pair := range resultsChan {
+		switch {
+		case pair.R1 != nil && pair.R2 != nil:
+			write(writer1, pair.R1)
+			write(writer2, pair.R2)
+		case pair.R1 != nil:
+			write(single1, pair.R1)
+		default:
+			write(single2, pair.R2)
+		}
+	}
+	for _, w := range []*bufio.Writer{writer1, writer2, single1, single2} {
+		if w != nil {
+			w.Flush()
+		}
+	}
+	close(doneChan)
+}
+
+// ProcessReadsPaired trims the R1 mates in inputFile and the R2 mates in
+// opts.Input2 together, writing them to outputFile and opts.Output2, and
+// prints the run summary.
+func ProcessReadsPaired(inputFile, outputFile string, opts Options) error {
+	startTime := time.Now()
+
+	stats, err := processPairedReads(inputFile, outputFile, opts)
+	if err != nil {
+		return err
+	}
+
+	return reportRun(os.Stdout, stats, &opts, time.Since(startTime))
+}
+
+// singletonPath names the file a paired output's lone mates go to under
+// KeepSingletons: path with ".singletons" before its FASTQ and compression
+// extensions, e.g. out_R1.singletons.fastq.gz.
+func singletonPath(path string) string {
+	stem := path
+	for _, ext := range []string{".gz", ".zst", ".fastq", ".fq"} {
+		stem = strings.TrimSuffix(stem, ext)
+	}
+	return stem + ".singletons" + path[len(stem):]
+}
+
+// processPairedReads opens the paired files and runs processPairedStreams.
+func processPairedReads(inputFile, outputFile string, opts Options) (*Stats, error) {
+	var ins []io.Reader
+	for _, path := range []string{inputFile, opts.Input2} {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		ins = append(ins, f)
+	}
+	paths := []string{outputFile, opts.Output2}
+	if opts.KeepSingletons {
+		paths = append(paths, singletonPath(outputFile), singletonPath(opts.Output2))
+	}
+	outs := make([]io.Writer, 4)
+	for i, path := range paths {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		outs[i] = f
+	}
+	return processPairedStreams(ins[0], ins[1], outs[0], outs[1], outs[2], outs[3], opts)
+}
+
+// processPairedStreams trims the mates read in step from in1 and in2,
+// compressed or not, and writes the kept pairs to out1 and out2. Under
+// KeepSingletons the lone mates go to single1 and single2, which are
+// otherwise nil. Every mate counts as a read in the returned counters.
+func processPairedStreams(in1, in2 io.Reader, out1, out2, single1, single2 io.Writer, opts Options) (*Stats, error) {
+	opts2 := mateOptions(opts)
+	for _, o := range []*Options{&opts, &opts2} {
+		if err := o.Prepare(); err != nil {
+			return nil, err
+		}
+	}
+
+	var sources []readSource
+	for _, in := range []io.Reader{in1, in2} {
+		r, release, err := maybeDecompress(in)
+		if err == io.EOF {
+			r, release = strings.NewReader(""), func() {}
+		} else if err != nil {
+			return nil, err
+		}
+		defer release()
+		sources = append(sources, newFastqReader(r, &opts))
+	}
+	source := &pairedSource{r1: sources[0], r2: sources[1]}
+
+	writers := make([]*bufio.Writer, 4)
+	var gws []io.WriteCloser
+	for i, out := range []io.Writer{out1, out2, single1, single2} {
+		if out == nil {
+			continue
+		}
+		gw, err := newOutputWriter(out, &opts)
+		if err != nil {
+			return nil, err
+		}
+		defer gw.Close()
+		gws = append(gws, gw)
+		writers[i] = bufio.NewWriter(gw)
+	}
+
+	var wg sync.WaitGroup
+	stats := Stats{Lengths: &lengthHist{}}
+	if opts.InsertPercentiles {
+		stats.InsertSizes = newInsertSizeEstimator()
+	}
+	if opts.QualityDist != "" {
+		stats.QualityDist = &qualityDist{}
+	}
+
+	resultsChan := make(chan *FastqReadPair, 1000)
+	doneChan := make(chan struct{})
+	go writePairs(writers[0], writers[1], writers[2], writers[3], &opts, resultsChan, doneChan, &stats)
+
+	if opts.StatsInterval > 0 {
+		stopReporter := startStatsReporter(os.Stderr, &stats, opts.StatsInterval)
+		defer stopReporter()
+	}
+	if opts.ProgressInterval > 0 {
+		stopProgress := startProgressReporter(os.Stderr, &stats, opts.ProgressInterval)
+		defer stopProgress()
+	}
+
+	batchSize := maxInt(opts.batchSize()/2, 1) // pairs, so the same number of reads as processStream
+	jobs := make(chan []*FastqReadPair, opts.workers())
+	for i := 0; i < opts.workers(); i++ {
+		go func() {
+			for batch := range jobs {
+				processPairedBatch(batch, &opts, &opts2, resultsChan, &wg, &stats)
+			}
+		}()
+	}
+
+	pairs := make([]*FastqReadPair, 0, batchSize)
+	var readErr error
+	for {
+		pair, err := source.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		atomic.AddInt64(&stats.TotalReads, 2)
+		pairs = append(pairs, pair)
+
+		if len(pairs) == batchSize {
+			wg.Add(1)
+			jobs <- pairs
+			pairs = make([]*FastqReadPair, 0, batchSize)
+		}
+	}
+	if len(pairs) > 0 && readErr == nil {
+		wg.Add(1)
+		jobs <- pairs
+	}
+	close(jobs)
+
+	wg.Wait()
+	close(resultsChan)
+	<-doneChan
+	if readErr != nil {
+		return nil, readErr
+	}
+	for _, gw := range gws {
+		if err := gw.Close(); err != nil {
+			return nil, fmt.Errorf("error writing output: %v", err)
+		}
+	}
+
+	if stats.QualityDist != nil {
+		if err := stats.QualityDist.writeTSVFile(opts.QualityDist); err != nil {
+			return nil, fmt.Errorf("error writing quality distribution: %v", err)
+		}
+	}
+	if opts.LengthHist != "" {
+		if err := stats.Lengths.writeTSVFile(opts.LengthHist); err != nil {
+			return nil, fmt.Errorf("error writing length histogram: %v", err)
+		}
+	}
+	if opts.StatsBinary != "" {
+		if err := writeStatsBlobFile(opts.StatsBinary, &stats); err != nil {
+			return nil, fmt.Errorf("error writing binary stats: %v", err)
+		}
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,60 @@
+package trimmer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessPairedStreamsSingletons(t *testing.T) {
+	r1 := "@P1/1\nACGTTGCAACGTTGCAATCACGTT\n+\nJJJJJJJJJJJJJJJJJJJJJJJJ\n" +
+		"@P2/1\nACGTTGCAACGTTGCAATCACGTT\n+\nJJJJJJJJJJJJJJJJJJJJJJJJ\n" +
+		"@P3/1\nACGTATCACGTTGCAACGTTGCAA\n+\nJJJJJJJJJJJJJJJJJJJJJJJJ\n"
+	// R2 mates are searched for their own adapter, GGCCTT.
+	r2 := "@P1/2\nTTGCAACGTTGCAACGGCCTTACG\n+\nJJJJJJJJJJJJJJJJJJJJJJJJ\n" +
+		"@P2/2\nTTGGCCTTACGTTGCAACGTTGCA\n+\nJJJJJJJJJJJJJJJJJJJJJJJJ\n" +
+		"@P3/2\nTTGCAGGCCTTACGTTGCAACGTT\n+\nJJJJJJJJJJJJJJJJJJJJJJJJ\n"
+	opts := Options{Adapter: "ATCACG", Adapter2: "GGCCTT", MinLen: 10, Min5Match: 6, MaxError: 0.1, PlainOutput: true}
+
+	var out1, out2 bytes.Buffer
+	stats, err := processPairedStreams(strings.NewReader(r1), strings.NewReader(r2), &out1, &out2, nil, nil, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "@P1/1\nACGTTGCAACGTTGCA\n+\nJJJJJJJJJJJJJJJJ\n", out1.String())
+	assert.Equal(t, "@P1/2\nTTGCAACGTTGCAAC\n+\nJJJJJJJJJJJJJJJ\n", out2.String())
+	assert.Equal(t, int64(6), stats.TotalReads)
+	assert.Equal(t, int64(2), stats.TotalTrimmedReads)
+	assert.Equal(t, int64(3), stats.TooShort, "P2 R2 and both P3 mates")
+	assert.Equal(t, int64(1), stats.Singletons)
+
+	// Kept singletons go to their own files, so the pair outputs stay in
+	// step.
+	opts.KeepSingletons = true
+	out1.Reset()
+	out2.Reset()
+	var single1, single2 bytes.Buffer
+	stats, err = processPairedStreams(strings.NewReader(r1), strings.NewReader(r2), &out1, &out2, &single1, &single2, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "@P1/1\nACGTTGCAACGTTGCA\n+\nJJJJJJJJJJJJJJJJ\n", out1.String())
+	assert.Equal(t, "@P1/2\nTTGCAACGTTGCAAC\n+\nJJJJJJJJJJJJJJJ\n", out2.String())
+	assert.Equal(t, "@P2/1\nACGTTGCAACGTTGCA\n+\nJJJJJJJJJJJJJJJJ\n", single1.String())
+	assert.Empty(t, single2.String())
+	assert.Equal(t, int64(3), stats.TotalTrimmedReads)
+	assert.Equal(t, int64(1), stats.Singletons)
+}
+
+func TestSingletonPath(t *testing.T) {
+	assert.Equal(t, "out_R1.singletons.fastq.gz", singletonPath("out_R1.fastq.gz"))
+	assert.Equal(t, "dir.v2/S1.L001.singletons.fq", singletonPath("dir.v2/S1.L001.fq"))
+	assert.Equal(t, "out.singletons.zst", singletonPath("out.zst"))
+	assert.Equal(t, "out.singletons", singletonPath("out"))
+}
+
+func TestProcessPairedStreamsOutOfStep(t *testing.T) {
+	r1 := "@P1/1\nACGT\n+\nJJJJ\n"
+	r2 := "@P9/2\nACGT\n+\nJJJJ\n"
+	opts := Options{Adapter: "ATCACG", MinLen: 1, Min5Match: 6, PlainOutput: true}
+	_, err := processPairedStreams(strings.NewReader(r1), strings.NewReader(r2), &bytes.Buffer{}, &bytes.Buffer{}, nil, nil, opts)
+	assert.EqualError(t, err, "mates out of step: @P1/1 and @P9/2")
+}
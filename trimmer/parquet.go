@@ -0,0 +1,78 @@
+package trimmer
+
+import (
+	"io"
+	"math"
+	"sync"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetRow is one read's outcome in the -parquet report.
+type parquetRow struct {
+	Header        string   `parquet:"name=header, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ReadLength    int32    `parquet:"name=read_length, type=INT32"`
+	AdapterIndex  int32    `parquet:"name=adapter_index, type=INT32"`
+	TrimmedLength int32    `parquet:"name=trimmed_length, type=INT32"`
+	MeanError     *float64 `parquet:"name=mean_error, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Fate          string   `parquet:"name=fate, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// newParquetRow builds the report row for a read from its trace and the
+// TrimRead outcome. The mean error is null when the quality filter was not
+// reached.
+func newParquetRow(read *FastqRead, tr *trimTrace, trimmed *FastqRead, err error) parquetRow {
+	row := parquetRow{
+		Header:       read.Header,
+		ReadLength:   int32(len(read.Sequence)),
+		AdapterIndex: int32(tr.AdapterIndex),
+		Fate:         "kept",
+	}
+	if err != nil {
+		row.Fate = err.Error()
+	} else {
+		row.TrimmedLength = int32(len(trimmed.Sequence))
+	}
+	if !math.IsNaN(tr.MeanError) {
+		meanErr := tr.MeanError
+		row.MeanError = &meanErr
+	}
+	return row
+}
+
+// parquetReport writes per-read rows to a Parquet file. Workers hand over
+// a whole batch of rows at a time to keep lock traffic low; the first write
+// error is kept and returned by close.
+type parquetReport struct {
+	mu  sync.Mutex
+	pw  *writer.ParquetWriter
+	err error
+}
+
+func newParquetReport(w io.Writer) (*parquetReport, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(parquetRow), 1)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetReport{pw: pw}, nil
+}
+
+func (p *parquetReport) writeRows(rows []parquetRow) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, row := range rows {
+		if p.err != nil {
+			return
+		}
+		p.err = p.pw.Write(row)
+	}
+}
+
+func (p *parquetReport) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	return p.pw.WriteStop()
+}
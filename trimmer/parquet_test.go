@@ -0,0 +1,67 @@
+package trimmer
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestParquetReport(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.fastq.gz")
+	outputFile := filepath.Join(dir, "out.fastq.gz")
+	parquetFile := filepath.Join(dir, "reads.parquet")
+
+	kept := "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC"
+	f, err := os.Create(inputFile)
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte("@KEPT\n" + kept + "\n+\n" + strings.Repeat("J", len(kept)) + "\n"))
+	gw.Write([]byte("@MISSING\nGGGGGGGGGGGGGGGGGGGGGGGGG\n+\n" + strings.Repeat("J", 25) + "\n"))
+	gw.Write([]byte("@SHORT\nACGTACGTATCACGGGGG\n+\n" + strings.Repeat("J", 18) + "\n"))
+	gw.Close()
+	f.Close()
+
+	opts := Options{Adapter: "ATCACG", MinLen: 18, Min5Match: 4, MaxError: 0.1, Parquet: parquetFile}
+	_, err = processReads(inputFile, outputFile, opts)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(parquetFile)
+	assert.NoError(t, err)
+	pf, err := buffer.NewBufferFile(data)
+	assert.NoError(t, err)
+	pr, err := reader.NewParquetReader(pf, new(parquetRow), 1)
+	assert.NoError(t, err)
+	defer pr.ReadStop()
+	assert.Equal(t, int64(3), pr.GetNumRows())
+
+	rows := make([]parquetRow, pr.GetNumRows())
+	assert.NoError(t, pr.Read(&rows))
+	byHeader := map[string]parquetRow{}
+	for _, row := range rows {
+		byHeader[row.Header] = row
+	}
+
+	k := byHeader["@KEPT"]
+	assert.Equal(t, "kept", k.Fate)
+	assert.Equal(t, int32(50), k.ReadLength)
+	assert.Equal(t, int32(33), k.AdapterIndex)
+	assert.Equal(t, int32(33), k.TrimmedLength)
+	if assert.NotNil(t, k.MeanError) {
+		assert.InDelta(t, PhredToError('J', 33), *k.MeanError, 1e-12)
+	}
+
+	m := byHeader["@MISSING"]
+	assert.Equal(t, "adapter missing", m.Fate)
+	assert.Equal(t, int32(-1), m.AdapterIndex)
+	assert.Nil(t, m.MeanError, "the quality filter was never reached")
+
+	assert.Equal(t, "too short", byHeader["@SHORT"].Fate)
+	assert.Equal(t, int32(8), byHeader["@SHORT"].AdapterIndex)
+}
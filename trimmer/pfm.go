@@ -0,0 +1,157 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PositionMatrix is an adapter described as per-position base frequencies,
+// stored as log2-odds scores against a uniform background.
+type PositionMatrix struct {
+	scores [][4]float64 // indexed by position then A, C, G, T
+}
+
+func baseIndex(b byte) int {
+	switch b {
+	case 'A':
+		return 0
+	case 'C':
+		return 1
+	case 'G':
+		return 2
+	case 'T':
+		return 3
+	}
+	return -1
+}
+
+// LoadPFM reads a position frequency matrix file.
+func LoadPFM(path string) (*PositionMatrix, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parsePFM(f)
+}
+
+// parsePFM parses a position frequency matrix with one row per base, e.g.
+//
+//	A 90 2 1 97
+//	C  5 1 95 1
+//	G  3 96 2 1
+//	T  2 1 2 1
+//
+// Each column is an adapter position; values may be counts or frequencies.
+// Brackets, colons and '#' comments are ignored.
+func parsePFM(r io.Reader) (*PositionMatrix, error) {
+	var rows [4][]float64
+	seen := [4]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.NewReplacer("[", " ", "]", " ", ":", " ").Replace(line)
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		b := baseIndex(strings.ToUpper(fields[0])[0])
+		if len(fields[0]) != 1 || b == -1 {
+			return nil, fmt.Errorf("invalid PFM row label %q", fields[0])
+		}
+		if seen[b] {
+			return nil, fmt.Errorf("duplicate PFM row for %s", fields[0])
+		}
+		seen[b] = true
+		for _, v := range fields[1:] {
+			x, err := strconv.ParseFloat(v, 64)
+			if err != nil || x < 0 {
+				return nil, fmt.Errorf("invalid PFM value %q", v)
+			}
+			rows[b] = append(rows[b], x)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for b, ok := range seen {
+		if !ok {
+			return nil, fmt.Errorf("PFM is missing the %c row", "ACGT"[b])
+		}
+		if len(rows[b]) != len(rows[0]) || len(rows[b]) == 0 {
+			return nil, fmt.Errorf("PFM rows must have the same, non-zero number of columns")
+		}
+	}
+
+	m := &PositionMatrix{scores: make([][4]float64, len(rows[0]))}
+	for pos := range m.scores {
+		total := 0.0
+		for b := 0; b < 4; b++ {
+			total += rows[b][pos]
+		}
+		for b := 0; b < 4; b++ {
+			// Pseudocount of one observation spread evenly over the bases.
+			p := (rows[b][pos] + 0.25) / (total + 1)
+			m.scores[pos][b] = math.Log2(p / 0.25)
+		}
+	}
+	return m, nil
+}
+
+// Consensus returns the highest-scoring base at each position.
+func (m *PositionMatrix) Consensus() string {
+	b := make([]byte, len(m.scores))
+	for pos, s := range m.scores {
+		best := 0
+		for i := 1; i < 4; i++ {
+			if s[i] > s[best] {
+				best = i
+			}
+		}
+		b[pos] = "ACGT"[best]
+	}
+	return string(b)
+}
+
+// MaxScore is the score of the consensus sequence.
+func (m *PositionMatrix) MaxScore() float64 {
+	total := 0.0
+	for _, s := range m.scores {
+		total += math.Max(math.Max(s[0], s[1]), math.Max(s[2], s[3]))
+	}
+	return total
+}
+
+// scoreAt is the log-odds score of the matrix placed at pos. Bases other
+// than ACGT (e.g. N) contribute nothing.
+func (m *PositionMatrix) scoreAt(sequence string, pos int) float64 {
+	total := 0.0
+	for i, s := range m.scores {
+		if b := baseIndex(sequence[pos+i]); b != -1 {
+			total += s[b]
+		}
+	}
+	return total
+}
+
+// index returns the leftmost position at or after from where the whole
+// matrix fits in the read with a score of at least minScore, or -1.
+func (m *PositionMatrix) index(sequence string, from int, minScore float64, dl deadline) int {
+	for pos := from; pos+len(m.scores) <= len(sequence); pos++ {
+		if m.scoreAt(sequence, pos) >= minScore {
+			return pos
+		}
+		if dl.expired() {
+			return adapterTimeout
+		}
+	}
+	return -1
+}
@@ -0,0 +1,43 @@
+package trimmer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPFM = `# TGGA with a C/T wobble at position 3
+A  0  0  0 100
+C  0  0 50   0
+G  0 100 0   0
+T 100 0 50   0
+`
+
+func TestParsePFM(t *testing.T) {
+	m, err := parsePFM(strings.NewReader(testPFM))
+	assert.NoError(t, err)
+	assert.Len(t, m.scores, 4)
+	assert.Equal(t, "TGCA", m.Consensus())
+	assert.InDelta(t, 1.99, m.scores[0][3], 0.01)
+	assert.InDelta(t, m.scores[2][1], m.scores[2][3], 1e-9, "wobble bases score equally")
+
+	_, err = parsePFM(strings.NewReader("A 1 2\nC 1 2\nG 1\nT 1 2\n"))
+	assert.Error(t, err)
+	_, err = parsePFM(strings.NewReader("A 1\nC 1\nG 1\n"))
+	assert.ErrorContains(t, err, "missing the T row")
+}
+
+func TestFindAdapterPFM(t *testing.T) {
+	m, err := parsePFM(strings.NewReader(testPFM))
+	assert.NoError(t, err)
+	opts := &Options{PFM: m, PFMMinScore: 0.8 * m.MaxScore()}
+
+	assert.Equal(t, 20, findAdapter("ACACACACACACACACACACTGCA", opts))
+	assert.Equal(t, 20, findAdapter("ACACACACACACACACACACTGTA", opts), "either wobble base scores")
+	assert.Equal(t, 20, findAdapter("ACACACACACACACACACACTGNA", opts), "an N only loses that position's score")
+	assert.Equal(t, -1, findAdapter("ACACACACACACACACACACTGAA", opts), "a disfavoured base drops below the threshold")
+
+	opts.PFMMinScore = -1
+	assert.Equal(t, 20, findAdapter("ACACACACACACACACACACTGAA", opts), "a lower threshold tolerates it")
+}
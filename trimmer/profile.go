@@ -0,0 +1,88 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// adapterProfile accumulates where the adapter starts across all reads so
+// a cumulative contamination curve can be written at the end of the run.
+// Workers fill an adapterProfileBatch and merge it once per batch.
+type adapterProfile struct {
+	mu     sync.Mutex
+	starts []int64 // reads whose adapter starts at each position
+	reads  int64
+	maxLen int
+}
+
+type adapterProfileBatch struct {
+	starts []int64
+	reads  int64
+	maxLen int
+}
+
+// add records one read; adapterIndex is -1 when no adapter was found.
+func (b *adapterProfileBatch) add(adapterIndex, readLen int) {
+	b.reads++
+	if readLen > b.maxLen {
+		b.maxLen = readLen
+	}
+	if adapterIndex < 0 {
+		return
+	}
+	for len(b.starts) <= adapterIndex {
+		b.starts = append(b.starts, 0)
+	}
+	b.starts[adapterIndex]++
+}
+
+func (p *adapterProfile) merge(b *adapterProfileBatch) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.starts) < len(b.starts) {
+		p.starts = append(p.starts, 0)
+	}
+	for i, n := range b.starts {
+		p.starts[i] += n
+	}
+	p.reads += b.reads
+	if b.maxLen > p.maxLen {
+		p.maxLen = b.maxLen
+	}
+}
+
+// writeCurve writes, for every read position, the fraction of all reads
+// whose adapter starts at or before that position.
+func (p *adapterProfile) writeCurve(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "position\tcumulativeFraction")
+	var cumulative int64
+	for pos := 0; pos < p.maxLen; pos++ {
+		if pos < len(p.starts) {
+			cumulative += p.starts[pos]
+		}
+		fraction := 0.0
+		if p.reads > 0 {
+			fraction = float64(cumulative) / float64(p.reads)
+		}
+		fmt.Fprintf(bw, "%d\t%.6f\n", pos, fraction)
+	}
+	return bw.Flush()
+}
+
+func (p *adapterProfile) writeCurveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.writeCurve(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,41 @@
+package trimmer
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdapterProfileCurve(t *testing.T) {
+	opts := &Options{Adapter: "ATCACG", MinLen: 1, Min5Match: 4}
+	stats := &Stats{AdapterProfile: &adapterProfile{}}
+	reads := []*FastqRead{
+		{Header: "@A", Sequence: "AAATCACG", Quality: "JJJJJJJJ"},     // adapter at 2
+		{Header: "@B", Sequence: "AAAAATCACG", Quality: "JJJJJJJJJJ"}, // adapter at 4
+		{Header: "@C", Sequence: "AAAAATCACG", Quality: "JJJJJJJJJJ"}, // adapter at 4
+		{Header: "@D", Sequence: "GGGGGG", Quality: "JJJJJJ"},         // no adapter
+	}
+
+	// Split across two batches to exercise merging.
+	resultsChan := make(chan *FastqRead, len(reads))
+	var wg sync.WaitGroup
+	wg.Add(2)
+	ProcessBatch(reads[:2], opts, resultsChan, &wg, stats)
+	ProcessBatch(reads[2:], opts, resultsChan, &wg, stats)
+
+	var buf bytes.Buffer
+	assert.NoError(t, stats.AdapterProfile.writeCurve(&buf))
+	assert.Equal(t, "position\tcumulativeFraction\n"+
+		"0\t0.000000\n"+
+		"1\t0.000000\n"+
+		"2\t0.250000\n"+
+		"3\t0.250000\n"+
+		"4\t0.750000\n"+
+		"5\t0.750000\n"+
+		"6\t0.750000\n"+
+		"7\t0.750000\n"+
+		"8\t0.750000\n"+
+		"9\t0.750000\n", buf.String())
+}
@@ -0,0 +1,105 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// qcBases are the base columns of the -qcReport table; anything else in a
+// read is counted as N.
+const qcBases = "ACGTN"
+
+// qcCounts holds the per-position tallies of the kept reads, grown to the
+// longest read seen.
+type qcCounts struct {
+	bases    [][len(qcBases)]int64 // reads with each base at each position
+	errorSum []float64             // summed error probability at each position
+}
+
+// qcProfile accumulates the base composition and mean error at each
+// position of the kept reads. Workers fill a qcProfileBatch and merge it
+// once per batch.
+type qcProfile struct {
+	mu sync.Mutex
+	qcCounts
+}
+
+type qcProfileBatch struct {
+	qcCounts
+}
+
+// add records one kept read, its qualities encoded with offset base.
+func (b *qcProfileBatch) add(sequence, quality string, base int) {
+	b.grow(len(sequence))
+	for i := 0; i < len(sequence); i++ {
+		b.bases[i][qcBaseIndex(sequence[i])]++
+		b.errorSum[i] += PhredToError(quality[i], base)
+	}
+}
+
+func qcBaseIndex(b byte) int {
+	switch b {
+	case 'A', 'a':
+		return 0
+	case 'C', 'c':
+		return 1
+	case 'G', 'g':
+		return 2
+	case 'T', 't':
+		return 3
+	}
+	return 4
+}
+
+func (c *qcCounts) grow(n int) {
+	for len(c.bases) < n {
+		c.bases = append(c.bases, [len(qcBases)]int64{})
+		c.errorSum = append(c.errorSum, 0)
+	}
+}
+
+func (p *qcProfile) merge(b *qcProfileBatch) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.grow(len(b.bases))
+	for i := range b.bases {
+		for j, n := range b.bases[i] {
+			p.bases[i][j] += n
+		}
+		p.errorSum[i] += b.errorSum[i]
+	}
+}
+
+// writeTSV writes one row per read position, counting from 0, with the
+// reads holding each base there and their mean error probability.
+func (p *qcProfile) writeTSV(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "position\tA\tC\tG\tT\tN\tmeanError")
+	for pos, counts := range p.bases {
+		var reads int64
+		fmt.Fprintf(bw, "%d", pos)
+		for _, n := range counts {
+			fmt.Fprintf(bw, "\t%d", n)
+			reads += n
+		}
+		fmt.Fprintf(bw, "\t%.6f\n", p.errorSum[pos]/float64(reads))
+	}
+	return bw.Flush()
+}
+
+func (p *qcProfile) writeTSVFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.writeTSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
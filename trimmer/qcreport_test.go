@@ -0,0 +1,50 @@
+package trimmer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQCProfileCounts(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 2, Min5Match: 8, NoQualFilter: true}
+	stats := &Stats{QC: &qcProfile{}}
+	reads := []*FastqRead{
+		{Header: "@A", Sequence: "ACGTGGAATTCTCGG", Quality: "+++++5555555555"}, // ACG, Q10
+		{Header: "@B", Sequence: "ANTGGAATTCTCGG", Quality: "55555555555555"},   // AN, Q20
+		{Header: "@C", Sequence: "GGGGGG", Quality: "IIIIII"},                   // no adapter, dropped
+		{Header: "@D", Sequence: "TCTTGGAATTCTCGG", Quality: "5+5555555555555"}, // TCT, Q20 Q10 Q20
+	}
+
+	// Split across two batches to exercise merging.
+	resultsChan := make(chan *FastqRead, len(reads))
+	var wg sync.WaitGroup
+	wg.Add(2)
+	ProcessBatch(reads[:2], opts, resultsChan, &wg, stats)
+	ProcessBatch(reads[2:], opts, resultsChan, &wg, stats)
+
+	var buf bytes.Buffer
+	assert.NoError(t, stats.QC.writeTSV(&buf))
+	assert.Equal(t, "position\tA\tC\tG\tT\tN\tmeanError\n"+
+		"0\t2\t0\t0\t1\t0\t0.040000\n"+ // (0.1 + 0.01 + 0.01) / 3
+		"1\t0\t2\t0\t0\t1\t0.070000\n"+ // (0.1 + 0.01 + 0.1) / 3
+		"2\t0\t0\t1\t1\t0\t0.055000\n", buf.String()) // (0.1 + 0.01) / 2
+}
+
+func TestProcessStreamQCReport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "qc.tsv")
+	input := "@A\nACGTTGGAATTCTCGG\n+\n" + strings.Repeat("I", 16) + "\n"
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 2, Min5Match: 8, PlainOutput: true, QCReport: path}
+
+	_, err := processStream(strings.NewReader(input), &bytes.Buffer{}, opts)
+	assert.NoError(t, err)
+	got, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, strings.Count(string(got), "\n"), "a header and a row for each of the 4 bases")
+}
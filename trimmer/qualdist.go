@@ -0,0 +1,88 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxPhred is the highest score printable in Phred+33.
+const maxPhred = '~' - 33
+
+// qualityDist counts output bases per Phred score. Only the writer
+// goroutine touches it, so it needs no locking.
+type qualityDist struct {
+	counts [maxPhred + 1]int64
+}
+
+// add counts the bases of quality, encoded with offset base. Scores out of
+// range are clamped.
+func (q *qualityDist) add(quality string, base int) {
+	for i := 0; i < len(quality); i++ {
+		phred := int(quality[i]) - base
+		if phred < 0 {
+			phred = 0
+		} else if phred > maxPhred {
+			phred = maxPhred
+		}
+		q.counts[phred]++
+	}
+}
+
+// writeTSV writes a phred/count row for every score from 0 up to the
+// highest one seen.
+func (q *qualityDist) writeTSV(w io.Writer) error {
+	top := -1
+	for phred, n := range q.counts {
+		if n > 0 {
+			top = phred
+		}
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "phred\tcount")
+	for phred := 0; phred <= top; phred++ {
+		fmt.Fprintf(bw, "%d\t%d\n", phred, q.counts[phred])
+	}
+	return bw.Flush()
+}
+
+func (q *qualityDist) writeTSVFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := q.writeTSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// mean is the average Phred score of the counted bases, or 0 if none.
+func (q *qualityDist) mean() float64 {
+	var n, sum int64
+	for phred, c := range q.counts {
+		n += c
+		sum += int64(phred) * c
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(sum) / float64(n)
+}
+
+// fractionAtLeast is the fraction of counted bases scoring phred or more.
+func (q *qualityDist) fractionAtLeast(phred int) float64 {
+	var n, above int64
+	for p, c := range q.counts {
+		n += c
+		if p >= phred {
+			above += c
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(above) / float64(n)
+}
@@ -0,0 +1,49 @@
+package trimmer
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualityDist(t *testing.T) {
+	var q qualityDist
+	q.add("!!+I", 33)  // 0, 0, 10, 40
+	q.add("JhJ", 64)   // 10, 40, 10
+	q.add("\x20~", 33) // below range clamps to 0, 93
+	assert.Equal(t, int64(3), q.counts[0])
+	assert.Equal(t, int64(3), q.counts[10])
+	assert.Equal(t, int64(2), q.counts[40])
+	assert.Equal(t, int64(1), q.counts[maxPhred])
+
+	var buf bytes.Buffer
+	assert.NoError(t, q.writeTSV(&buf))
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Equal(t, "phred\tcount", string(lines[0]))
+	assert.Len(t, lines, maxPhred+2, "rows run up to the highest score seen")
+	assert.Equal(t, "10\t3", string(lines[11]))
+}
+
+func TestWriteResultsQualityDist(t *testing.T) {
+	var buf bytes.Buffer
+	resultsChan := make(chan *FastqRead, 2)
+	doneChan := make(chan struct{})
+	stats := Stats{QualityDist: &qualityDist{}}
+	resultsChan <- &FastqRead{Header: "@R1", Sequence: "ACGT", Quality: "IIII"}
+	resultsChan <- &FastqRead{Header: "@R2", Sequence: "ACG", Quality: "5+5"}
+	close(resultsChan)
+	// No offset given: the qualities are read as the default Phred+33.
+	go writeResults(bufio.NewWriter(&buf), &Options{}, resultsChan, doneChan, &stats)
+	<-doneChan
+
+	var total int64
+	for _, n := range stats.QualityDist.counts {
+		total += n
+	}
+	assert.Equal(t, int64(7), total, "one count per output base")
+	assert.Equal(t, int64(4), stats.QualityDist.counts[40])
+	assert.Equal(t, int64(2), stats.QualityDist.counts[20])
+	assert.Equal(t, int64(1), stats.QualityDist.counts[10])
+}
@@ -0,0 +1,133 @@
+package trimmer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// p2Quantile estimates a single quantile of a stream in constant memory
+// using the P² algorithm (Jain & Chlamtac, 1985). Five markers track the
+// minimum, maximum, the target quantile and the two midpoints between them;
+// their heights are adjusted with piecewise-parabolic interpolation.
+type p2Quantile struct {
+	p   float64
+	n   int
+	q   [5]float64 // marker heights
+	pos [5]float64 // actual marker positions (1-based)
+	des [5]float64 // desired marker positions
+	inc [5]float64 // desired position increments per observation
+}
+
+func newP2Quantile(p float64) *p2Quantile {
+	return &p2Quantile{
+		p:   p,
+		inc: [5]float64{0, p / 2, p, (1 + p) / 2, 1},
+	}
+}
+
+func (e *p2Quantile) Add(x float64) {
+	if e.n < 5 {
+		e.q[e.n] = x
+		e.n++
+		if e.n == 5 {
+			sort.Float64s(e.q[:])
+			e.pos = [5]float64{1, 2, 3, 4, 5}
+			e.des = [5]float64{1, 1 + 2*e.p, 1 + 4*e.p, 3 + 2*e.p, 5}
+		}
+		return
+	}
+
+	var k int
+	switch {
+	case x < e.q[0]:
+		e.q[0] = x
+		k = 0
+	case x >= e.q[4]:
+		e.q[4] = x
+		k = 3
+	default:
+		for k = 0; k < 3 && x >= e.q[k+1]; k++ {
+		}
+	}
+
+	for i := k + 1; i < 5; i++ {
+		e.pos[i]++
+	}
+	for i := range e.des {
+		e.des[i] += e.inc[i]
+	}
+
+	for i := 1; i <= 3; i++ {
+		d := e.des[i] - e.pos[i]
+		if (d >= 1 && e.pos[i+1]-e.pos[i] > 1) || (d <= -1 && e.pos[i-1]-e.pos[i] < -1) {
+			s := math.Copysign(1, d)
+			h := e.parabolic(i, s)
+			if e.q[i-1] < h && h < e.q[i+1] {
+				e.q[i] = h
+			} else {
+				e.q[i] = e.linear(i, s)
+			}
+			e.pos[i] += s
+		}
+	}
+	e.n++
+}
+
+func (e *p2Quantile) parabolic(i int, s float64) float64 {
+	return e.q[i] + s/(e.pos[i+1]-e.pos[i-1])*
+		((e.pos[i]-e.pos[i-1]+s)*(e.q[i+1]-e.q[i])/(e.pos[i+1]-e.pos[i])+
+			(e.pos[i+1]-e.pos[i]-s)*(e.q[i]-e.q[i-1])/(e.pos[i]-e.pos[i-1]))
+}
+
+func (e *p2Quantile) linear(i int, s float64) float64 {
+	j := i + int(s)
+	return e.q[i] + s*(e.q[j]-e.q[i])/(e.pos[j]-e.pos[i])
+}
+
+// Value returns the current estimate. With fewer than five observations it
+// falls back to the exact nearest-rank quantile; with none it returns NaN.
+func (e *p2Quantile) Value() float64 {
+	if e.n == 0 {
+		return math.NaN()
+	}
+	if e.n < 5 {
+		vals := append([]float64(nil), e.q[:e.n]...)
+		sort.Float64s(vals)
+		return vals[int(e.p*float64(e.n-1)+0.5)]
+	}
+	return e.q[2]
+}
+
+// insertPercentiles are the insert-size percentiles reported by -insertPercentiles.
+var insertPercentiles = []float64{0.25, 0.5, 0.75, 0.9}
+
+// insertSizeEstimator tracks approximate insert-size percentiles. It is not
+// safe for concurrent use; the writer goroutine owns it.
+type insertSizeEstimator struct {
+	estimators []*p2Quantile
+}
+
+func newInsertSizeEstimator() *insertSizeEstimator {
+	e := &insertSizeEstimator{}
+	for _, p := range insertPercentiles {
+		e.estimators = append(e.estimators, newP2Quantile(p))
+	}
+	return e
+}
+
+func (e *insertSizeEstimator) Add(size int) {
+	for _, q := range e.estimators {
+		q.Add(float64(size))
+	}
+}
+
+// String formats the estimates as "p25=.. p50=.. p75=.. p90=..".
+func (e *insertSizeEstimator) String() string {
+	parts := make([]string, len(e.estimators))
+	for i, q := range e.estimators {
+		parts[i] = fmt.Sprintf("p%.0f=%.1f", q.p*100, q.Value())
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,53 @@
+package trimmer
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestP2QuantileAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	// Bimodal small RNA-like insert sizes around 21 and 24 nt.
+	values := make([]float64, 0, 100000)
+	for i := 0; i < 100000; i++ {
+		center := 21.0
+		if rng.Float64() < 0.4 {
+			center = 24
+		}
+		values = append(values, math.Round(center+rng.NormFloat64()*1.5))
+	}
+
+	est := newInsertSizeEstimator()
+	for _, v := range values {
+		est.Add(int(v))
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	for i, p := range insertPercentiles {
+		exact := sorted[int(p*float64(len(sorted)-1))]
+		assert.InDelta(t, exact, est.estimators[i].Value(), 1.0, "p%.0f", p*100)
+	}
+}
+
+func TestP2QuantileUniform(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	q := newP2Quantile(0.9)
+	for i := 0; i < 50000; i++ {
+		q.Add(rng.Float64() * 100)
+	}
+	assert.InDelta(t, 90, q.Value(), 1.0)
+}
+
+func TestP2QuantileFewObservations(t *testing.T) {
+	q := newP2Quantile(0.5)
+	assert.True(t, math.IsNaN(q.Value()))
+	q.Add(30)
+	q.Add(10)
+	q.Add(20)
+	assert.Equal(t, 20.0, q.Value())
+}
@@ -0,0 +1,120 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// readerAtSize returns in as an io.ReaderAt with its size when it can be
+// read at arbitrary offsets: a regular file, or an in-memory reader.
+func readerAtSize(in io.Reader) (io.ReaderAt, int64, bool) {
+	switch r := in.(type) {
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil, 0, false
+		}
+		return r, info.Size(), true
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return r, r.Size(), true
+	}
+	return nil, 0, false
+}
+
+// recordStartAfter returns the offset of the first FASTQ record that starts
+// at or after offset, or size if there is none. A line is taken as a header
+// when it starts with @ and the line after next starts with +; a quality
+// line starting with @ fails this because two lines on is a sequence.
+func recordStartAfter(r io.ReaderAt, size, offset int64) (int64, error) {
+	if offset <= 0 {
+		return 0, nil
+	}
+	br := bufio.NewReader(io.NewSectionReader(r, offset-1, size-offset+1))
+	// Start at a line boundary: skip the rest of the line offset falls in.
+	pos := offset - 1
+	skipped, err := br.ReadSlice('\n')
+	for err == bufio.ErrBufferFull {
+		pos += int64(len(skipped))
+		skipped, err = br.ReadSlice('\n')
+	}
+	pos += int64(len(skipped))
+	if err == io.EOF {
+		return size, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var starts [3]int64
+	var firsts [3]byte
+	for lines := 0; ; lines++ {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			starts[lines%3], firsts[lines%3] = pos, line[0]
+			pos += int64(len(line))
+			if lines >= 2 && firsts[(lines-2)%3] == '@' && line[0] == '+' {
+				return starts[(lines-2)%3], nil
+			}
+		}
+		if err == io.EOF {
+			return size, nil
+		} else if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// fastqRanges splits an uncompressed FASTQ of size bytes into up to n
+// ranges of whole records, returned as the start of each range followed by
+// size. Ranges that would be empty are dropped.
+func fastqRanges(r io.ReaderAt, size int64, n int) ([]int64, error) {
+	bounds := []int64{0}
+	for i := 1; i < n; i++ {
+		start, err := recordStartAfter(r, size, size*int64(i)/int64(n))
+		if err != nil {
+			return nil, err
+		}
+		if start > bounds[len(bounds)-1] && start < size {
+			bounds = append(bounds, start)
+		}
+	}
+	return append(bounds, size), nil
+}
+
+// dispatchRanges parses each range of an uncompressed FASTQ in its own
+// goroutine, handing full batches to dispatch as the reader loop of
+// processStream does. It returns once every range has been read.
+func dispatchRanges(r io.ReaderAt, size int64, n int, opts *Options, dispatch func(readSource) error) error {
+	head := make([]byte, len(zstdMagic))
+	read, _ := r.ReadAt(head, 0)
+	if compressedFormat(head[:read]) != "" {
+		return fmt.Errorf("-readRanges needs an uncompressed FASTQ input")
+	}
+	bounds, err := fastqRanges(r, size, n)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(bounds)-1)
+	for i := 0; i+1 < len(bounds); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			section := io.NewSectionReader(r, bounds[i], bounds[i+1]-bounds[i])
+			errs[i] = dispatch(newFastqReader(section, opts))
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package trimmer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rangeTestInput has quality lines starting with @ and + to tempt the
+// record alignment.
+func rangeTestInput(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		insert := strings.Repeat("ACGT", 5+i%4)
+		seq := insert + "TGGAATTCTCGG"
+		qual := "@+" + strings.Repeat("I", len(seq)-2)
+		fmt.Fprintf(&b, "@R%d\n%s\n+\n%s\n", i, seq, qual)
+	}
+	return b.String()
+}
+
+func TestFastqRangesStartAtRecords(t *testing.T) {
+	input := rangeTestInput(50)
+	r := strings.NewReader(input)
+	bounds, err := fastqRanges(r, r.Size(), 9)
+	assert.NoError(t, err)
+	assert.Len(t, bounds, 10)
+	assert.Equal(t, int64(0), bounds[0])
+	assert.Equal(t, int64(len(input)), bounds[len(bounds)-1])
+	for _, b := range bounds[1 : len(bounds)-1] {
+		assert.Equal(t, "\n@R", input[b-1:b+2], "range at %d starts at a header", b)
+	}
+
+	// More ranges than records leaves out the empty ones.
+	one := rangeTestInput(1)
+	r = strings.NewReader(one)
+	bounds, err = fastqRanges(r, r.Size(), 8)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, int64(len(one))}, bounds)
+}
+
+func TestReadRangesEachReadOnce(t *testing.T) {
+	const n = 25000
+	input := rangeTestInput(n)
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8, MaxError: 0.1, PlainOutput: true, ReadRanges: 7}
+
+	var out bytes.Buffer
+	stats, err := processStream(strings.NewReader(input), &out, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(n), stats.TotalReads)
+	assert.Equal(t, int64(n), stats.TotalTrimmedReads)
+
+	var headers []string
+	scanner := bufio.NewScanner(&out)
+	for i := 0; scanner.Scan(); i++ {
+		if i%4 == 0 {
+			headers = append(headers, scanner.Text())
+		}
+	}
+	want := make([]string, n)
+	for i := range want {
+		want[i] = fmt.Sprintf("@R%d", i)
+	}
+	sort.Strings(headers)
+	sort.Strings(want)
+	assert.Equal(t, want, headers)
+}
+
+func TestReadRangesNeedsUncompressedFile(t *testing.T) {
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8, PlainOutput: true, ReadRanges: 2}
+	_, err := processStream(bufio.NewReader(strings.NewReader(rangeTestInput(3))), &bytes.Buffer{}, opts)
+	assert.EqualError(t, err, "-readRanges needs a regular input file")
+}
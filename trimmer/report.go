@@ -0,0 +1,89 @@
+package trimmer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// RunStats is the end-of-run summary written by -jsonReport.
+type RunStats struct {
+	TotalReads          int64 `json:"totalReads"`
+	TotalTrimmedReads   int64 `json:"totalTrimmedReads"`
+	AdapterMissingCount int64 `json:"adapterMissingCount"`
+	TooShortCount       int64 `json:"tooShortCount"`
+	LowQualityCount     int64 `json:"lowQualityCount"`
+	PolyTrimmedCount    int64 `json:"polyTrimmedCount"`
+	TooManyNCount       int64 `json:"tooManyNCount"`
+	LowBaseQualCount    int64 `json:"lowBaseQualCount"`
+	TooLongCount        int64 `json:"tooLongCount"`
+
+	// Counters of optional stages, left out when zero.
+	NoInsertCount         int64 `json:"noInsertCount,omitempty"`
+	TimeoutCount          int64 `json:"timeoutCount,omitempty"`
+	GCFilteredCount       int64 `json:"gcFilteredCount,omitempty"`
+	UnknownBarcodeCount   int64 `json:"unknownBarcodeCount,omitempty"`
+	DuplicateHeadersCount int64 `json:"duplicateHeadersCount,omitempty"`
+	SingletonsCount       int64 `json:"singletonsCount,omitempty"`
+	MergedCount           int64 `json:"mergedCount,omitempty"`
+	UnmergedCount         int64 `json:"unmergedCount,omitempty"`
+	UMIDuplicatesCount    int64 `json:"umiDuplicatesCount,omitempty"`
+
+	TrimmedPercentage float64 `json:"trimmedPercentage"`
+	DurationSeconds   float64 `json:"durationSeconds"`
+}
+
+func newRunStats(stats *Stats, duration time.Duration) RunStats {
+	return RunStats{
+		TotalReads:          stats.TotalReads,
+		TotalTrimmedReads:   stats.TotalTrimmedReads,
+		AdapterMissingCount: stats.AdapterMissing,
+		TooShortCount:       stats.TooShort,
+		LowQualityCount:     stats.LowQuality,
+		PolyTrimmedCount:    stats.PolyTrimmed,
+		TooManyNCount:       stats.TooManyN,
+		LowBaseQualCount:    stats.LowBaseQual,
+		TooLongCount:        stats.TooLong,
+
+		NoInsertCount:         stats.NoInsert,
+		TimeoutCount:          stats.Timeout,
+		GCFilteredCount:       stats.GCFiltered,
+		UnknownBarcodeCount:   stats.UnknownBarcode,
+		DuplicateHeadersCount: stats.DuplicateHeaders,
+		SingletonsCount:       stats.Singletons,
+		MergedCount:           stats.Merged,
+		UnmergedCount:         stats.Unmerged,
+		UMIDuplicatesCount:    stats.UMIDuplicates,
+
+		TrimmedPercentage: percentOf(stats.TotalTrimmedReads, stats.TotalReads),
+		DurationSeconds:   duration.Seconds(),
+	}
+}
+
+func writeJSONReport(path string, rs RunStats) error {
+	data, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// reportRun prints the text summary to w, unless Quiet is set, and writes
+// the -jsonReport file. With FailOnEmpty, a run that read nothing is an
+// error once both are out.
+func reportRun(w io.Writer, stats *Stats, opts *Options, duration time.Duration) error {
+	if !opts.Quiet {
+		printSummary(w, stats, opts, duration)
+	}
+	if opts.JSONReport != "" {
+		if err := writeJSONReport(opts.JSONReport, newRunStats(stats, duration)); err != nil {
+			return err
+		}
+	}
+	if opts.FailOnEmpty && stats.TotalReads == 0 {
+		return fmt.Errorf("no reads found in the input")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package trimmer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONReport(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.fastq")
+	reads := "@KEPT\nACGTACGTACGTACGTACGTACTGGAATTCTCGG\n+\nIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIII\n" +
+		"@SHORT\nACGTACGTACTGGAATTCTCGGGTGCCAAGG\n+\nIIIIIIIIIIIIIIIIIIIIIIIIIIIIIII\n" +
+		"@MISSING\nACGTACGTACGTACGTACGTACGT\n+\nIIIIIIIIIIIIIIIIIIIIIIII\n" +
+		"@LOWQ\nACGTACGTACGTACGTACGTACTGGAATTCTCGG\n+\n##################################\n"
+	assert.NoError(t, os.WriteFile(input, []byte(reads), 0644))
+	report := filepath.Join(dir, "report.json")
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8, MaxError: 0.1, PlainOutput: true, Quiet: true, JSONReport: report}
+
+	assert.NoError(t, ProcessReadsFast(input, filepath.Join(dir, "out.fastq"), opts))
+
+	data, err := os.ReadFile(report)
+	assert.NoError(t, err)
+	var got RunStats
+	assert.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, int64(4), got.TotalReads)
+	assert.Equal(t, int64(1), got.TotalTrimmedReads)
+	assert.Equal(t, int64(1), got.AdapterMissingCount)
+	assert.Equal(t, int64(1), got.TooShortCount)
+	assert.Equal(t, int64(1), got.LowQualityCount)
+	assert.Equal(t, 25.0, got.TrimmedPercentage)
+	assert.Greater(t, got.DurationSeconds, 0.0)
+	dropped := got.AdapterMissingCount + got.TooShortCount + got.LowQualityCount + got.TooManyNCount +
+		got.LowBaseQualCount + got.TooLongCount + got.NoInsertCount + got.TimeoutCount +
+		got.GCFilteredCount + got.UnknownBarcodeCount
+	assert.Equal(t, got.TotalReads, got.TotalTrimmedReads+dropped, "every read is accounted for")
+
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &fields))
+	assert.Contains(t, fields, "adapterMissingCount")
+	assert.NotContains(t, fields, "gcFilteredCount", "optional stages are left out when zero")
+}
+
+func TestRunStatsOptionalCounters(t *testing.T) {
+	stats := &Stats{TotalReads: 10, TotalTrimmedReads: 4, NoInsert: 1, Timeout: 1, GCFiltered: 2, UnknownBarcode: 2, DuplicateHeaders: 3, Singletons: 1, Merged: 5, Unmerged: 2}
+	data, err := json.Marshal(newRunStats(stats, 0))
+	assert.NoError(t, err)
+	var got RunStats
+	assert.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, RunStats{
+		TotalReads: 10, TotalTrimmedReads: 4, NoInsertCount: 1, TimeoutCount: 1, GCFilteredCount: 2,
+		UnknownBarcodeCount: 2, DuplicateHeadersCount: 3, SingletonsCount: 1, MergedCount: 5, UnmergedCount: 2,
+		TrimmedPercentage: 40,
+	}, got)
+	assert.Equal(t, got.TotalReads, got.TotalTrimmedReads+got.NoInsertCount+got.TimeoutCount+got.GCFilteredCount+got.UnknownBarcodeCount, "every read is accounted for")
+}
+
+func TestRunStatsEmptyInput(t *testing.T) {
+	rs := newRunStats(&Stats{}, 0)
+	_, err := json.Marshal(rs)
+	assert.NoError(t, err, "no reads must not give a NaN percentage")
+	assert.Zero(t, rs.TrimmedPercentage)
+}
+
+func TestReportRunEmptyGzipInput(t *testing.T) {
+	var gz bytes.Buffer
+	assert.NoError(t, gzip.NewWriter(&gz).Close())
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8}
+
+	stats, err := processStream(&gz, &bytes.Buffer{}, opts)
+	assert.NoError(t, err)
+	var summary bytes.Buffer
+	assert.NoError(t, reportRun(&summary, stats, &opts, time.Second))
+	assert.NotContains(t, summary.String(), "NaN")
+	assert.Contains(t, summary.String(), "No reads found in the input")
+	assert.Contains(t, summary.String(), "Percentage of trimmed reads: 0.00%")
+
+	opts.FailOnEmpty = true
+	assert.EqualError(t, reportRun(&summary, stats, &opts, time.Second), "no reads found in the input")
+	stats.TotalReads = 1
+	assert.NoError(t, reportRun(&summary, stats, &opts, time.Second))
+}
+
+func TestReportRunZeroByteFile(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "empty.fq")
+	assert.NoError(t, os.WriteFile(inputFile, nil, 0644))
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8}
+
+	// The same path a CLI run takes, from the file name to the summary.
+	stats, err := processReadsCtx(context.Background(), inputFile, filepath.Join(dir, "out.fq.gz"), opts)
+	assert.NoError(t, err)
+	var summary bytes.Buffer
+	assert.NoError(t, reportRun(&summary, stats, &opts, time.Second))
+	assert.NotContains(t, summary.String(), "NaN")
+	assert.Contains(t, summary.String(), "No reads found in the input")
+	assert.Contains(t, summary.String(), "Percentage of trimmed reads: 0.00%")
+}
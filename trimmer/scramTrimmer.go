@@ -0,0 +1,1597 @@
+// Package trimmer holds the adapter trimming engine behind the scramTrimmer
+// command: TrimRead trims a single FastqRead according to Options, and the
+// ProcessReads functions run it over whole files.
+package trimmer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+type FastqRead struct {
+	Header   string
+	Sequence string
+	Quality  string
+
+	original *FastqRead // untrimmed read, kept only with Options.KeepOriginal
+	umi      string     // bases moved out of the read by UMI5 and UMI3, written into the header
+
+	polyTrimmed bool  // a PolyTrim tail was cut from the insert
+	index       int64 // position among the input reads, set by processStream
+}
+
+// Options holds the trimming parameters applied to every read.
+type Options struct {
+	Adapter              string
+	MinLen               int
+	Trim5                int
+	Trim3                int
+	Min5Match            int
+	MaxError             float64       // values <= 0 disable the quality filter
+	ReverseInput         bool          // reverse sequence and quality before any trimming
+	HeaderLen            bool          // append " len=N" to each output header
+	NoQualFilter         bool          // skip the mean error check entirely
+	Rsyncable            bool          // write rsync-friendly gzip output
+	SkipFailedOutputs    bool          // drop a failing output destination instead of aborting
+	IndelRefine          int           // max indel shift when refining the adapter boundary
+	StatsInterval        time.Duration // print a counter snapshot to stderr this often; 0 disables
+	DetectNoInsert       bool          // report adapter-only reads as "no insert" rather than "too short"
+	NWildcard            bool          // let N in the read match any adapter base
+	InQualBase           int           // quality offset of the input (33 or 64)
+	OutQualBase          int           // quality offset to write; re-encoded when it differs from InQualBase
+	InsertPercentiles    bool          // estimate and report insert-size percentiles
+	VerifyOutput         bool          // re-read the written output and check it after the run
+	Seed2                string        // optional second seed that must also match
+	Seed2Gap             int           // bases between the end of the first seed and the start of Seed2
+	TraceFraction        float64       // fraction of reads to trace to TraceFile
+	TraceFile            string
+	PFM                  *PositionMatrix   // match the adapter by scoring this matrix instead of a seed
+	PFMMinScore          float64           // minimum log2-odds score for a PFM match
+	TouchOutput          bool              // always produce a valid (possibly empty) output, even for a zero-byte input; now always the case, kept for existing callers
+	KmerIndex            bool              // locate the seed with a packed k-mer index instead of strings.Index
+	KeepOriginal         bool              // also write the untrimmed read, its ID suffixed with ":orig"
+	TrimTrailingSpace    bool              // strip trailing spaces/tabs from sequence and quality lines
+	ContaminationProfile string            // write the cumulative adapter-start curve to this file
+	QCReport             string            // write per-position base composition and mean error of the kept reads to this TSV
+	CountSidecar         bool              // write the record count to <output>.count
+	PreferMatch          string            // trim at the "earliest" acceptable adapter hit (default) or the "latest" of those with the fewest mismatches
+	MaxReadProcTime      time.Duration     // give up on a read after this long searching for the adapter (0 disables)
+	SoftTrim             bool              // keep reads untrimmed when trimming would leave fewer than MinLen bases
+	Funnel               bool              // print the survivors after each filter stage
+	CRLF                 bool              // end output lines with \r\n
+	OpticalDup           bool              // estimate the optical duplicate rate from header tile coordinates
+	OpticalDupDist       int               // pixel radius for OpticalDup
+	MoreAdapters         []string          // further 3' adapters searched alongside Adapter; the earliest hit wins
+	AdapterTrim3         []int             // Trim3 for Adapter then each of MoreAdapters; Trim3 is used when empty
+	AnnotateAll          string            // write every read tagged with its fate to this gzipped FASTQ
+	QualityDist          string            // write the per-Phred count of output bases to this TSV
+	AutoMaxErrorReads    int               // calibrate MaxError from this many leading reads (0 disables)
+	AutoMaxErrorPct      float64           // percentile of the calibration mean errors used as MaxError
+	TooShortOutput       string            // write adapter-trimmed reads rejected as too short to this gzipped FASTQ
+	Parquet              string            // write a per-read outcome row to this Parquet file
+	Merge                bool              // merge overlapping mates from Input2 into single reads before trimming
+	Input2               string            // R2 FASTQ, read in step with the input, for Merge or paired trimming
+	MergeMinOverlap      int               // fewest overlapping bases for Merge to join two mates
+	DedupHeaders         string            // "error", "warn" or "drop" on a repeated read ID ("" disables the check)
+	DedupWindow          int               // remember only this many recent read IDs for DedupHeaders (0 remembers all)
+	WobblePos            []int             // 1-based seed positions that match any read base
+	StatsBinary          string            // write the counters and histograms as a gob-encoded blob to this file
+	HPCompressMatch      bool              // search for the adapter with homopolymer runs collapsed
+	DiffAgainst          string            // compare the output with this previous output by read ID
+	DiffSequence         bool              // with DiffAgainst, also report reads whose sequence changed
+	DiffReport           string            // with DiffAgainst, list each differing read ID in this file
+	DecompressCmd        string            // read the input through this external command instead of gzip
+	MinAdapterScore      int               // accept any position where the adapter aligns with at least this score, no seed needed (0 disables)
+	GzBlockSize          int               // pgzip block size in bytes, 0 for the default
+	GzBlocks             int               // pgzip blocks compressed in parallel, 0 for the default
+	GzipLevel            int               // gzip compression level, 1-9 or pgzip.HuffmanOnly; 0 for the default
+	BarcodeAdapters      map[string]string // 3' adapter per header barcode; reads with an unlisted barcode are dropped
+	SplitByAdapter       string            // write the untouched reads with no adapter found to this gzipped FASTQ
+	InfoFile             string            // write a cutadapt --info-file style line per read to this file
+	MinGC                float64           // drop trimmed reads below this GC percentage
+	MaxGC                float64           // drop trimmed reads above this GC percentage (0 means no upper limit)
+	TwoPass              bool              // run a survey pass and print recommendations before trimming
+	UMIDedup             bool              // keep one read per UMI and trimmed sequence, the one with the best quality
+	ConstQual            byte              // write every output quality as this character; 0 keeps the real scores
+	PlainOutput          bool              // write uncompressed FASTQ instead of gzip
+	LengthPrior          []LengthPeak      // choose among several adapter hits by how expected the insert length is
+	InsertEndBed         string            // write a bedGraph of insert-end reference positions, from pos= header fields, to this file
+	MaxAdapterMismatch   int               // mismatched bases allowed in the adapter seed
+	AdapterErrorRate     float64           // match the whole adapter overlap, allowing this fraction of it to mismatch, instead of the seed (0 disables)
+	MinOverlap           int               // bases of adapter a seed hit must extend to before it counts (0 disables)
+	Collapse             bool              // write each distinct trimmed sequence once, as FASTA with its count, instead of the reads
+	CollapseMaxUnique    int               // with Collapse, spill the counts to disk past this many distinct sequences (0 never spills)
+	CollapseTmpDir       string            // directory for Collapse spill files; empty for the system default
+	LabelFile            string            // write one fate byte per read, in input order, to this file
+	Output2              string            // R2 output for paired trimming of the input and Input2 mates
+	Adapter2             string            // 3' adapter for the R2 mates in paired trimming; empty uses Adapter
+	KeepSingletons       bool              // in paired trimming, write a kept mate whose partner is dropped to a .singletons file beside its output
+	MaskCycles           []int             // 1-based read cycles that match any adapter base in the search; the output keeps them
+	BGZF                 bool              // write BGZF, blocked gzip that htslib tools can seek in, instead of plain gzip
+	Gzi                  bool              // with BGZF, also write a .gzi block index next to each output file
+	Discarded            string            // write every read TrimRead rejects, untouched and tagged reason:<why>, to this gzipped FASTQ
+	JSONReport           string            // write the end-of-run counters as JSON to this file
+	Quiet                bool              // skip the text summary
+	TruncateTo           int               // cut kept reads longer than this down to their first TruncateTo bases (0 disables)
+	LengthHist           string            // write the number of output reads of each length to this TSV
+	SplitByMode          bool              // also report the counters separately for inserts up to and longer than the insert-size mode
+	MinPartial3          int               // trim an adapter prefix of at least this many bases at the very 3' end of reads with no full seed (0 disables)
+	ReadRanges           int               // parse an uncompressed input file in this many byte ranges at once (0 or 1 reads it sequentially)
+	AutoQualBaseReads    int               // set InQualBase from the lowest quality character in this many leading reads (0 disables)
+	QualCutoff           int               // before adapter trimming, trim 3' bases while the mean Phred quality of the last QualWindow bases is below this (0 disables)
+	QualWindow           int               // window for QualCutoff; 0 means 4
+	ProgressInterval     time.Duration     // print the reads read so far and the read rate to stderr this often; 0 disables
+	BatchSize            int               // reads handed to a worker at a time; 0 means 10000
+	Workers              int               // batches trimmed at once; 0 means one per CPU
+	Ordered              bool              // write kept reads in input order, holding back batches that finish early
+	MaxLineLen           int               // longest FASTQ line read, in bytes; 0 means 16 MiB
+	Adapter5             string            // 5' adapter removed, with everything before it, from the start of reads before the 3' search
+	Adapter5MaxOffset    int               // how many bases further into the read than a complete Adapter5 at the start its end may be found
+	UMI5                 int               // move this many bases from the start of the read into the header as its UMI
+	UMI3                 int               // move this many bases just before the adapter into the header as its UMI
+	FailOnEmpty          bool              // report a run that read no reads as an error, after its summary
+	PolyTrim             string            // bases, A, G or AG, whose homopolymer tails are cut after adapter trimming
+	PolyMin              int               // shortest PolyTrim run cut; 0 means 10
+	IUPAC                bool              // let ambiguous adapter bases such as N and R match any read base they stand for
+	MaxN                 float64           // drop trimmed reads with more N bases than this count, or than this fraction of the length when below 1 (0 disables)
+	MinBaseQual          int               // drop trimmed reads with any base of a lower Phred score (0 disables)
+	MaxLen               int               // drop reads longer than this once trimmed, before TruncateTo (0 disables)
+
+	tracer     *tracer             // set by ProcessReadsFast when tracing is enabled
+	kmer       *kmerIndex          // built by prepare when KmerIndex is set
+	alts       []*Options          // one per MoreAdapters, built by prepare
+	wobbleSeed string              // seed with WobblePos marked, built by prepare
+	hp         *Options            // homopolymer-compressed search options, built by prepare
+	annotator  *annotator          // set by processReads when AnnotateAll is given
+	shortSink  *fastqSink          // set by processReads when TooShortOutput is given
+	missSink   *fastqSink          // set by processReads when SplitByAdapter is given
+	discarded  *discardSink        // set by processReads when Discarded is given
+	info       *infoWriter         // set by processReads when InfoFile is given
+	collapse   *collapser          // set by processReads when Collapse is given
+	labels     *labelWriter        // set by processReads when LabelFile is given
+	parquet    *parquetReport      // set by processReads when Parquet is given
+	byBarcode  map[string]*Options // one per BarcodeAdapters entry, built by prepare
+	gzi        *gziIndex           // set by processReads when Gzi is given
+	prepared   bool                // set by prepare
+}
+
+// Prepare checks the adapter seeds and builds the matchers derived from the
+// options, such as those for MoreAdapters, BarcodeAdapters and WobblePos.
+// TrimRead and ProcessBatch prepare a copy of options that have not been
+// through it, so calling it once up front only saves that work per call.
+// Options changed afterwards need preparing again.
+func (o *Options) Prepare() error {
+	if err := o.checkSeeds(); err != nil {
+		return err
+	}
+	o.prepare()
+	return nil
+}
+
+// preparedOptions returns opts, or a prepared copy if it has not been
+// through Prepare.
+func preparedOptions(opts *Options) (*Options, error) {
+	if opts.prepared {
+		return opts, nil
+	}
+	p := *opts
+	if err := p.Prepare(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// prepare builds the derived matchers that are computed once per run.
+func (o *Options) prepare() {
+	if o.KmerIndex && o.PFM == nil {
+		o.kmer = newKmerIndex(o.Adapter[:o.Min5Match])
+	}
+	o.hp = nil
+	if o.HPCompressMatch {
+		o.hp = o.hpOptions()
+	}
+	o.wobbleSeed = ""
+	if len(o.WobblePos) > 0 {
+		seed := []byte(o.Adapter[:o.Min5Match])
+		for _, pos := range o.WobblePos {
+			if pos >= 1 && pos <= len(seed) {
+				seed[pos-1] = wobbleBase
+			}
+		}
+		o.wobbleSeed = string(seed)
+	}
+	o.prepared = true
+	o.alts = nil
+	for _, a := range o.MoreAdapters {
+		alt := *o
+		alt.Adapter, alt.MoreAdapters, alt.alts, alt.BarcodeAdapters = a, nil, nil, nil
+		alt.prepare()
+		o.alts = append(o.alts, &alt)
+	}
+	o.byBarcode = nil
+	if len(o.BarcodeAdapters) > 0 {
+		o.byBarcode = make(map[string]*Options, len(o.BarcodeAdapters))
+		for barcode, a := range o.BarcodeAdapters {
+			sample := *o
+			sample.Adapter, sample.BarcodeAdapters = a, nil
+			sample.prepare()
+			o.byBarcode[barcode] = &sample
+		}
+	}
+}
+
+// adapterSeq is the i-th adapter, counting Adapter as 0 and MoreAdapters
+// from 1.
+func (o *Options) adapterSeq(i int) string {
+	if i == 0 {
+		return o.Adapter
+	}
+	return o.MoreAdapters[i-1]
+}
+
+// adapterTrim3 is the 3' trim that goes with the i-th adapter, counting
+// Adapter as 0 and MoreAdapters from 1.
+func (o *Options) adapterTrim3(i int) int {
+	if len(o.AdapterTrim3) == 0 {
+		return o.Trim3
+	}
+	return o.AdapterTrim3[i]
+}
+
+func (o *Options) qualFilterEnabled() bool {
+	return !o.NoQualFilter && o.MaxError > 0
+}
+
+func (o *Options) gcFilterEnabled() bool {
+	return o.MinGC > 0 || (o.MaxGC > 0 && o.MaxGC < 100)
+}
+
+// tooManyN reports whether sequence has more N bases than maxN allows: a
+// count when maxN is 1 or more, a fraction of the length below that.
+func tooManyN(sequence string, maxN float64) bool {
+	n := float64(strings.Count(sequence, "N") + strings.Count(sequence, "n"))
+	if maxN < 1 {
+		return len(sequence) > 0 && n/float64(len(sequence)) > maxN
+	}
+	return n > maxN
+}
+
+// lineEnding is the terminator written after each output line.
+func (o *Options) lineEnding() string {
+	if o.CRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// DefaultMaxLineLen is the longest FASTQ line read when MaxLineLen is not
+// set, enough for a 16 Mb long read.
+const DefaultMaxLineLen = 16 << 20
+
+func (o *Options) maxLineLen() int {
+	if o.MaxLineLen <= 0 {
+		return DefaultMaxLineLen
+	}
+	return o.MaxLineLen
+}
+
+// newLineScanner scans lines of up to maxLineLen bytes from r, starting
+// with a small buffer.
+func newLineScanner(r io.Reader, maxLineLen int) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, minInt(64*1024, maxLineLen)), maxLineLen)
+	return scanner
+}
+
+// DefaultBatchSize is the number of reads handed to a worker at a time when
+// BatchSize is not set.
+const DefaultBatchSize = 10000
+
+func (o *Options) batchSize() int {
+	if o.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return o.BatchSize
+}
+
+// workers is the number of batches trimmed at once, one per CPU unless
+// Workers is set.
+func (o *Options) workers() int {
+	if o.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return o.Workers
+}
+
+// qualBase is the quality offset of the input, Phred+33 unless set.
+func (o *Options) qualBase() int {
+	if o.InQualBase == 0 {
+		return 33
+	}
+	return o.InQualBase
+}
+
+// DefaultQualWindow is the QualCutoff window when QualWindow is not set.
+const DefaultQualWindow = 4
+
+func (o *Options) qualWindow() int {
+	if o.QualWindow <= 0 {
+		return DefaultQualWindow
+	}
+	return o.QualWindow
+}
+
+// qualityTrim3 removes bases from the 3' end of seq and qual while the mean
+// Phred+33 quality of the last window bases, or of the whole read once it is
+// shorter, is below cutoff. Both are cut at the same position.
+func qualityTrim3(seq, qual string, cutoff, window int) (string, string) {
+	if cutoff <= 0 || window <= 0 {
+		return seq, qual
+	}
+	end := minInt(len(seq), len(qual))
+	for end > 0 {
+		w := minInt(window, end)
+		sum := 0
+		for i := end - w; i < end; i++ {
+			sum += int(qual[i]) - 33
+		}
+		if sum >= cutoff*w {
+			break
+		}
+		end--
+	}
+	return seq[:end], qual[:end]
+}
+
+func PhredToError(qual byte, base int) float64 {
+	return math.Pow(10, -(float64(qual)-float64(base))/10.0)
+}
+
+func MeanError(quality []byte, base int) float64 {
+	total := 0.0
+	for _, q := range quality {
+		total += PhredToError(q, base)
+	}
+	return total / float64(len(quality))
+}
+
+// hasBaseBelow reports whether any base in quality, encoded with the given
+// offset, scores below minQual.
+func hasBaseBelow(quality string, minQual, base int) bool {
+	for i := 0; i < len(quality); i++ {
+		if int(quality[i])-base < minQual {
+			return true
+		}
+	}
+	return false
+}
+
+// gcPercent is the percentage of G and C bases in sequence.
+func gcPercent(sequence string) float64 {
+	if len(sequence) == 0 {
+		return 0
+	}
+	gc := 0
+	for i := 0; i < len(sequence); i++ {
+		switch sequence[i] {
+		case 'G', 'C', 'g', 'c':
+			gc++
+		}
+	}
+	return float64(gc) / float64(len(sequence)) * 100
+}
+
+func reverseString(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// Reasons TrimRead gives for dropping a read. The messages double as the fate
+// names written to the trace, info and parquet outputs.
+var (
+	ErrAdapterMissing = errors.New("adapter missing")
+	ErrTooShort       = errors.New("too short")
+	ErrLowQuality     = errors.New("low quality")
+	ErrNoInsert       = errors.New("no insert")
+	ErrTimeout        = errors.New("timeout")
+	ErrUnknownBarcode = errors.New("unknown barcode")
+	ErrGCFiltered     = errors.New("gc filtered")
+	ErrTooManyN       = errors.New("too many N")
+	ErrLowBaseQual    = errors.New("low base quality")
+	ErrTooLong        = errors.New("too long")
+)
+
+// TrimRead trims read according to opts. A dropped read comes back with one
+// of the Err* reasons above, and as nil except with ErrTooShort: that read is
+// still returned, trimmed as far as its bounds allow, for -tooShortOutput.
+func TrimRead(read *FastqRead, opts *Options) (*FastqRead, error) {
+	opts, err := preparedOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return trimReadTrace(read, opts, nil)
+}
+
+// trimReadTrace is TrimRead that additionally fills in tr, when non-nil,
+// with the intermediate values behind the decision.
+//
+// A read rejected as too short is still returned, trimmed as far as the
+// 5'/3' bounds allow, alongside the error so it can be routed to
+// -tooShortOutput.
+func trimReadTrace(read *FastqRead, opts *Options, tr *trimTrace) (*FastqRead, error) {
+	if opts.byBarcode != nil {
+		sample, ok := opts.byBarcode[headerBarcode(read.Header)]
+		if !ok {
+			return nil, ErrUnknownBarcode
+		}
+		opts = sample
+	}
+
+	sequence := read.Sequence
+	quality := read.Quality
+	if opts.ReverseInput {
+		sequence = reverseString(sequence)
+		quality = reverseString(quality)
+	}
+	if opts.QualCutoff > 0 {
+		// qualityTrim3 reads Phred+33, so shift the cutoff to the input
+		// offset rather than re-encoding the qualities.
+		sequence, quality = qualityTrim3(sequence, quality, opts.QualCutoff+opts.qualBase()-33, opts.qualWindow())
+	}
+
+	search := sequence
+	if len(opts.MaskCycles) > 0 {
+		search = maskCycles(sequence, opts.MaskCycles, opts.ReverseInput)
+	}
+	if opts.Adapter5 != "" {
+		// The 3' search and every offset after this, Trim5 included, are
+		// in what follows the 5' adapter.
+		if cut := adapter5End(search, opts); cut > 0 {
+			sequence, quality, search = sequence[cut:], quality[cut:], search[cut:]
+		}
+	}
+
+	dl := newDeadline(opts.MaxReadProcTime)
+	var adapterIndex, which int
+	if opts.hp != nil {
+		adapterIndex, which = findAdapterHP(search, opts, dl)
+	} else {
+		adapterIndex, which = findAnyAdapter(search, opts, dl)
+	}
+	if adapterIndex == -1 && opts.MinPartial3 > 0 {
+		adapterIndex = partialAdapterAtEnd(search, opts.Adapter, opts.MinPartial3, opts.Min5Match)
+	}
+	trim3 := opts.adapterTrim3(which)
+	if adapterIndex == adapterTimeout {
+		return nil, ErrTimeout
+	}
+	if tr != nil {
+		tr.AdapterIndex = adapterIndex
+		if adapterIndex >= 0 {
+			tr.Adapter, tr.AdapterNum = opts.adapterSeq(which), which+1
+		}
+	}
+
+	if adapterIndex == -1 {
+		return nil, ErrAdapterMissing
+	}
+
+	if opts.DetectNoInsert && adapterIndex <= opts.UMI5+opts.Trim5 {
+		return nil, ErrNoInsert
+	}
+
+	// UMI bases come first at the 5' end and next to the adapter at the
+	// 3' end, with Trim5 and Trim3 cutting inside them.
+	start := opts.UMI5 + opts.Trim5
+	end := adapterIndex - trim3 - opts.UMI3
+	// A 3' trim reaching back past the 5' trim leaves no insert at all,
+	// whatever MinLen says; clamp rather than slice backwards.
+	overTrimmed := end < start
+	if overTrimmed {
+		if opts.DetectNoInsert {
+			return nil, ErrNoInsert
+		}
+		// The 5' trim can itself run past the end of a short read.
+		start = minInt(start, len(sequence))
+		end = start
+	}
+	if tr != nil {
+		tr.Start, tr.End = start, end
+	}
+
+	var polyTrimmed bool
+	if opts.PolyTrim != "" && start < end && end <= len(sequence) {
+		tail, _ := trimPolyTail(sequence[start:end], quality[start:end], opts.PolyTrim, opts.polyMin())
+		polyTrimmed = len(tail) < end-start
+		end = start + len(tail)
+		if tr != nil {
+			tr.End = end
+		}
+	}
+
+	insertStart, insertEnd := start, end
+	tooShort := overTrimmed || end-start < opts.MinLen
+	if tooShort && opts.SoftTrim {
+		// Soft trimming keeps the whole read rather than losing a short
+		// insert.
+		start, end = 0, len(sequence)
+		tooShort = end-start < opts.MinLen
+		if tr != nil {
+			tr.Start, tr.End = start, end
+		}
+	}
+	if tooShort {
+		// Keep the insert within the read, and not reversed, whatever
+		// the trims above did to it.
+		insertStart = minInt(maxInt(insertStart, 0), len(sequence))
+		insertEnd = minInt(maxInt(insertEnd, insertStart), len(sequence))
+		short := &FastqRead{
+			Header:   read.Header,
+			Sequence: sequence[insertStart:insertEnd],
+			Quality:  quality[insertStart:insertEnd],
+		}
+		return short, ErrTooShort
+	}
+	if opts.MaxLen > 0 && end-start > opts.MaxLen {
+		return nil, ErrTooLong
+	}
+
+	if opts.TruncateTo > 0 && end-start > opts.TruncateTo {
+		end = start + opts.TruncateTo
+	}
+
+	trimmedSequence := sequence[start:end]
+	trimmedQuality := quality[start:end]
+
+	if opts.MaxN > 0 && tooManyN(trimmedSequence, opts.MaxN) {
+		return nil, ErrTooManyN
+	}
+
+	if opts.qualFilterEnabled() {
+		meanErr := MeanError([]byte(trimmedQuality), opts.qualBase())
+		if tr != nil {
+			tr.MeanError = meanErr
+		}
+		if meanErr >= opts.MaxError {
+			return nil, ErrLowQuality
+		}
+	}
+
+	if opts.MinBaseQual > 0 && hasBaseBelow(trimmedQuality, opts.MinBaseQual, opts.qualBase()) {
+		return nil, ErrLowBaseQual
+	}
+
+	if opts.gcFilterEnabled() {
+		gc := gcPercent(trimmedSequence)
+		if gc < opts.MinGC || (opts.MaxGC > 0 && gc > opts.MaxGC) {
+			return nil, ErrGCFiltered
+		}
+	}
+
+	trimmedRead := &FastqRead{
+		Header:   read.Header,
+		Sequence: trimmedSequence,
+		Quality:  trimmedQuality,
+
+		polyTrimmed: polyTrimmed,
+	}
+	if (opts.UMI5 > 0 || opts.UMI3 > 0) && adapterIndex-opts.UMI3 >= opts.UMI5 {
+		trimmedRead.umi = joinUMI(sequence[:opts.UMI5], sequence[adapterIndex-opts.UMI3:adapterIndex])
+	}
+	if opts.KeepOriginal {
+		trimmedRead.original = read
+	}
+	return trimmedRead, nil
+}
+
+// DefaultPolyMin is the shortest PolyTrim run cut when PolyMin is not set.
+const DefaultPolyMin = 10
+
+func (o *Options) polyMin() int {
+	if o.PolyMin <= 0 {
+		return DefaultPolyMin
+	}
+	return o.PolyMin
+}
+
+// trimPolyTail cuts a run of at least minRun of one of bases, such as the
+// poly-G a two-colour sequencer reads past the end of a short fragment,
+// from the 3' end of seq and qual. Runs of different bases in turn are
+// all cut, so AAAAAAAAAAGGGGGGGGGG goes entirely with bases AG.
+func trimPolyTail(seq, qual, bases string, minRun int) (string, string) {
+	end := len(seq)
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for i := 0; i < len(bases); i++ {
+			run := 0
+			for run < end && seq[end-run-1] == bases[i] {
+				run++
+			}
+			if run >= minRun {
+				end -= run
+				trimmed = true
+			}
+		}
+	}
+	return seq[:end], qual[:end]
+}
+
+// joinUMI writes the 5' and 3' UMI of a read as one, joined by + when
+// there are both.
+func joinUMI(umi5, umi3 string) string {
+	if umi5 != "" && umi3 != "" {
+		return umi5 + "+" + umi3
+	}
+	return umi5 + umi3
+}
+
+// ProcessBatch trims batch, sending the kept reads to resultsChan and
+// counting every read in stats, and calls wg.Done when finished. Options
+// that fail Prepare trim nothing, so callers should run Prepare first to
+// see the error.
+func ProcessBatch(
+	batch []*FastqRead,
+	opts *Options,
+	resultsChan chan<- *FastqRead,
+	wg *sync.WaitGroup,
+	stats *Stats,
+) {
+	defer wg.Done()
+
+	prepared, err := preparedOptions(opts)
+	if err != nil {
+		return
+	}
+	opts = prepared
+
+	var sampler *rand.Rand
+	if opts.tracer != nil {
+		sampler = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	var profile *adapterProfileBatch
+	if stats.AdapterProfile != nil {
+		profile = &adapterProfileBatch{}
+		defer stats.AdapterProfile.merge(profile)
+	}
+
+	var rows []parquetRow
+	if opts.parquet != nil {
+		rows = make([]parquetRow, 0, len(batch))
+		defer func() { opts.parquet.writeRows(rows) }()
+	}
+
+	var qc *qcProfileBatch
+	if stats.QC != nil {
+		qc = &qcProfileBatch{}
+		defer stats.QC.merge(qc)
+	}
+
+	var fates *insertFatesBatch
+	if stats.InsertFates != nil {
+		fates = &insertFatesBatch{}
+		defer stats.InsertFates.merge(fates)
+	}
+
+	var labels []byte
+	if opts.labels != nil && len(batch) > 0 {
+		labels = make([]byte, 0, len(batch))
+		defer func() { opts.labels.write(batch[0].index, labels) }()
+	}
+
+	for _, read := range batch {
+		var tr *trimTrace
+		sampled := sampler != nil && sampler.Float64() < opts.TraceFraction
+		if sampled || profile != nil || rows != nil || opts.info != nil || stats.InsertEnds != nil || fates != nil {
+			tr = newTrimTrace(read)
+		}
+		trimmedRead, err := trimReadTrace(read, opts, tr)
+		if sampled {
+			tr.Decision = "kept"
+			if err != nil {
+				tr.Decision = err.Error()
+			}
+			opts.tracer.write(tr)
+		}
+		if profile != nil {
+			profile.add(tr.AdapterIndex, len(read.Sequence))
+		}
+		if rows != nil {
+			rows = append(rows, newParquetRow(read, tr, trimmedRead, err))
+		}
+		if opts.info != nil {
+			opts.info.write(read, tr, opts)
+		}
+		if labels != nil {
+			labels = append(labels, fateLabel(err))
+		}
+		if fates != nil && tr.AdapterIndex >= 0 {
+			fates.add(maxInt(tr.End-tr.Start, 0), fateLabel(err))
+		}
+		if stats.InsertEnds != nil && err == nil {
+			stats.InsertEnds.add(read.Header, tr.End)
+		}
+		if opts.shortSink != nil && errors.Is(err, ErrTooShort) {
+			opts.shortSink.write(trimmedRead)
+		}
+		if opts.missSink != nil && errors.Is(err, ErrAdapterMissing) {
+			opts.missSink.write(read)
+		}
+		if opts.discarded != nil && err != nil {
+			opts.discarded.write(read, err)
+		}
+		if opts.annotator != nil && err != nil {
+			opts.annotator.write(read, err)
+		}
+		if err != nil {
+			stats.countDropped(err)
+			continue
+		}
+		if trimmedRead.polyTrimmed {
+			atomic.AddInt64(&stats.PolyTrimmed, 1)
+		}
+		if qc != nil {
+			qc.add(trimmedRead.Sequence, trimmedRead.Quality, opts.qualBase())
+		}
+		if stats.UMIDedup != nil {
+			umi := trimmedRead.umi
+			if umi == "" {
+				umi = headerUMI(trimmedRead.Header)
+			}
+			if umi != "" {
+				// Annotated, and relabelled if dropped, once the copy to
+				// keep is known.
+				trimmedRead.index = read.index
+				stats.UMIDedup.add(umi, trimmedRead)
+				continue
+			}
+		}
+		if opts.annotator != nil {
+			opts.annotator.write(trimmedRead, nil)
+		}
+		resultsChan <- trimmedRead
+	}
+}
+
+func outputHeader(read *FastqRead, opts *Options) string {
+	header := read.Header
+	if read.umi != "" {
+		header += " " + umiTag + read.umi
+	}
+	if opts.HeaderLen {
+		header += " len=" + strconv.Itoa(len(read.Sequence))
+	}
+	return header
+}
+
+// recodeQuality shifts each quality character from the inBase offset to the
+// outBase offset, clamping scores to the range the output encoding can hold.
+func recodeQuality(quality string, inBase, outBase int) string {
+	maxPhred := '~' - outBase
+	b := make([]byte, len(quality))
+	for i := 0; i < len(quality); i++ {
+		phred := int(quality[i]) - inBase
+		if phred < 0 {
+			phred = 0
+		} else if phred > maxPhred {
+			phred = maxPhred
+		}
+		b[i] = byte(phred + outBase)
+	}
+	return string(b)
+}
+
+func outputQuality(read *FastqRead, opts *Options) string {
+	if opts.ConstQual != 0 {
+		return strings.Repeat(string(opts.ConstQual), len(read.Quality))
+	}
+	if opts.InQualBase != 0 && opts.OutQualBase != 0 && opts.InQualBase != opts.OutQualBase {
+		return recodeQuality(read.Quality, opts.InQualBase, opts.OutQualBase)
+	}
+	return read.Quality
+}
+
+// originalSuffix marks the untrimmed copy of a read written by -keepOriginal.
+const originalSuffix = ":orig"
+
+// suffixReadID appends suffix to the read ID, before any description.
+func suffixReadID(header, suffix string) string {
+	if i := strings.IndexAny(header, " \t"); i >= 0 {
+		return header[:i] + suffix + header[i:]
+	}
+	return header + suffix
+}
+
+func writeRecord(writer *bufio.Writer, read *FastqRead, opts *Options) {
+	eol := opts.lineEnding()
+	writer.WriteString(outputHeader(read, opts) + eol)
+	writer.WriteString(read.Sequence + eol)
+	writer.WriteString("+" + eol)
+	writer.WriteString(outputQuality(read, opts) + eol)
+}
+
+// Writer goroutine
+func writeResults(
+	writer *bufio.Writer,
+	opts *Options,
+	resultsChan <-chan *FastqRead,
+	doneChan chan<- struct{},
+	stats *Stats,
+) {
+	for read := range resultsChan {
+		if opts.collapse != nil {
+			opts.collapse.add(read.Sequence)
+			atomic.AddInt64(&stats.TotalTrimmedReads, 1)
+			if stats.Lengths != nil {
+				stats.Lengths.add(len(read.Sequence))
+			}
+			continue
+		}
+		if read.original != nil {
+			orig := *read.original
+			orig.Header = suffixReadID(orig.Header, originalSuffix)
+			writeRecord(writer, &orig, opts)
+		}
+		writeRecord(writer, read, opts)
+		atomic.AddInt64(&stats.TotalTrimmedReads, 1)
+		if stats.Lengths != nil {
+			stats.Lengths.add(len(read.Sequence))
+		}
+		if stats.InsertSizes != nil {
+			stats.InsertSizes.Add(len(read.Sequence))
+		}
+		if stats.QualityDist != nil {
+			stats.QualityDist.add(read.Quality, opts.qualBase())
+		}
+	}
+	if opts.collapse != nil {
+		opts.collapse.err = opts.collapse.finish(writer, opts.lineEnding())
+	}
+	writer.Flush()
+	close(doneChan)
+}
+
+func Comma(value int64) string {
+	str := strconv.FormatInt(value, 10)
+	result := ""
+	count := 0
+	for i := len(str) - 1; i >= 0; i-- {
+		if count > 0 && count%3 == 0 {
+			result = "," + result
+		}
+		result = string(str[i]) + result
+		count++
+	}
+	return result
+}
+
+// ProcessReadsFast trims inputFile into outputFile and prints the run
+// summary. Either may be StdioPath, and inputFile may also list several
+// comma-separated files, which are trimmed as one input into the one output.
+func ProcessReadsFast(inputFile, outputFile string, opts Options) error {
+	return ProcessReadsFastCtx(context.Background(), inputFile, outputFile, opts)
+}
+
+// ProcessReadsFastCtx is ProcessReadsFast that stops reading once ctx is
+// cancelled. The reads taken in by then are still trimmed and written, and
+// their summary printed, before it returns ctx.Err().
+func ProcessReadsFastCtx(ctx context.Context, inputFile, outputFile string, opts Options) error {
+	startTime := time.Now()
+
+	stats, err := processReadsCtx(ctx, inputFile, outputFile, opts)
+	if err != nil {
+		if stats != nil && !opts.Quiet {
+			printSummary(SummaryWriter(outputFile), stats, &opts, time.Since(startTime))
+		}
+		return err
+	}
+
+	return reportRun(SummaryWriter(outputFile), stats, &opts, time.Since(startTime))
+}
+
+// StdioPath as the input or output file name means stdin or stdout.
+const StdioPath = "-"
+
+// SummaryWriter is where the run summary goes: stdout, unless the trimmed
+// reads are written there.
+func SummaryWriter(outputFile string) io.Writer {
+	if outputFile == StdioPath {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// readSource yields the input reads one at a time, returning io.EOF after
+// the last.
+type readSource interface {
+	next() (*FastqRead, error)
+}
+
+// fastqReader parses four-line FASTQ records.
+type fastqReader struct {
+	scanner           *bufio.Scanner
+	trimTrailingSpace bool
+	maxLineLen        int // the scanner's line limit, for the error when a line exceeds it
+	line              int // lines read so far
+}
+
+// newFastqReader reads FASTQ from r, allowing lines of up to
+// opts.MaxLineLen bytes.
+func newFastqReader(r io.Reader, opts *Options) *fastqReader {
+	maxLineLen := opts.maxLineLen()
+	return &fastqReader{scanner: newLineScanner(r, maxLineLen), trimTrailingSpace: opts.TrimTrailingSpace, maxLineLen: maxLineLen}
+}
+
+// scan advances to the next line. It returns false at the end of the
+// input, and an error if the scanner stopped short of it.
+func (f *fastqReader) scan() (bool, error) {
+	if f.scanner.Scan() {
+		f.line++
+		return true, nil
+	}
+	err := f.scanner.Err()
+	if err == bufio.ErrTooLong {
+		maxLineLen := f.maxLineLen
+		if maxLineLen == 0 {
+			maxLineLen = bufio.MaxScanTokenSize
+		}
+		return false, fmt.Errorf("error reading file: a line is longer than %d bytes; raise -maxLineLen", maxLineLen)
+	} else if err != nil {
+		return false, fmt.Errorf("error reading file: %v", err)
+	}
+	return false, nil
+}
+
+func (f *fastqReader) next() (*FastqRead, error) {
+	scanner := f.scanner
+	if ok, err := f.scan(); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, io.EOF
+	}
+	header := scanner.Text()
+	if !strings.HasPrefix(header, "@") {
+		return nil, fmt.Errorf("invalid fastq file: expected '@' at the beginning of header line, got: %s", header)
+	}
+
+	// The rest of the record must follow; the input ending first means it
+	// was cut short.
+	start := f.line
+	rest := func() (string, error) {
+		ok, err := f.scan()
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("truncated FASTQ: record starting at line %d is incomplete", start)
+		}
+		return scanner.Text(), nil
+	}
+
+	sequence, err := rest()
+	if err != nil {
+		return nil, err
+	}
+
+	plus, err := rest()
+	if err != nil {
+		return nil, err
+	}
+	if plus != "+" {
+		return nil, fmt.Errorf("invalid fastq file: expected '+' line, got: %s", plus)
+	}
+
+	quality, err := rest()
+	if err != nil {
+		return nil, err
+	}
+	if f.trimTrailingSpace {
+		sequence = strings.TrimRight(sequence, " \t")
+		quality = strings.TrimRight(quality, " \t")
+	}
+	if len(sequence) != len(quality) {
+		return nil, fmt.Errorf("invalid fastq file: sequence and quality strings must have the same length, got: %d and %d", len(sequence), len(quality))
+	}
+
+	return &FastqRead{
+		Header:   header,
+		Sequence: sequence,
+		Quality:  quality,
+	}, nil
+}
+
+// processReads runs the trimming pipeline for one input and returns its
+// counters without printing anything. Either file may be StdioPath, and
+// inputFile a comma-separated list of files read as one input.
+func processReads(inputFile, outputFile string, opts Options) (*Stats, error) {
+	return processReadsCtx(context.Background(), inputFile, outputFile, opts)
+}
+
+// processReadsCtx is processReads that stops at the cancellation of ctx,
+// returning the counters of the partial run along with ctx.Err().
+func processReadsCtx(ctx context.Context, inputFile, outputFile string, opts Options) (*Stats, error) {
+	var in io.Reader = os.Stdin
+	if inputFiles := strings.Split(inputFile, ","); len(inputFiles) > 1 {
+		combined := newCombinedInput(inputFiles)
+		defer combined.Close()
+		in = combined
+	} else if inputFile != StdioPath {
+		inFile, err := os.Open(inputFile)
+		if err != nil {
+			return nil, err
+		}
+		defer inFile.Close()
+		in = inFile
+	}
+
+	var out io.Writer = os.Stdout
+	var outFiles []*os.File
+	if outputFile != StdioPath {
+		var err error
+		if outFiles, err = createOutputs(outputFile); err != nil {
+			return nil, err
+		}
+		fan := &fanoutWriter{skipFailed: opts.SkipFailedOutputs}
+		for _, f := range outFiles {
+			defer f.Close()
+			fan.dests = append(fan.dests, namedWriter{f.Name(), f})
+		}
+		out = fan
+	}
+
+	if opts.Gzi {
+		opts.gzi = &gziIndex{}
+	}
+
+	stats, err := processStreamCtx(ctx, in, out, opts)
+	if err != nil {
+		return stats, err
+	}
+
+	if opts.gzi != nil {
+		for _, f := range outFiles {
+			if err := opts.gzi.writeFile(f.Name()); err != nil {
+				return nil, fmt.Errorf("error writing gzi index: %v", err)
+			}
+		}
+	}
+
+	if opts.CountSidecar {
+		if err := writeCountSidecars(outFiles, recordsWritten(stats, &opts)); err != nil {
+			return nil, fmt.Errorf("error writing count sidecar: %v", err)
+		}
+	}
+
+	if opts.DiffAgainst != "" {
+		var report io.Writer
+		if opts.DiffReport != "" {
+			reportOut, err := os.Create(opts.DiffReport)
+			if err != nil {
+				return nil, err
+			}
+			defer reportOut.Close()
+			report = reportOut
+		}
+		if stats.Diff, err = diffOutputs(opts.DiffAgainst, outFiles[0].Name(), opts.DiffSequence, report); err != nil {
+			return nil, fmt.Errorf("error comparing outputs: %v", err)
+		}
+	}
+
+	if opts.VerifyOutput {
+		for _, f := range outFiles {
+			if info, err := os.Stat(f.Name()); err != nil || !info.Mode().IsRegular() {
+				continue // FIFOs and the like can't be re-read
+			}
+			records, err := verifyOutput(f.Name(), &opts)
+			if err != nil {
+				return nil, fmt.Errorf("output verification failed for %s: %v", f.Name(), err)
+			}
+			if expected := recordsWritten(stats, &opts); records != expected {
+				return nil, fmt.Errorf("output verification failed for %s: found %d records, expected %d", f.Name(), records, expected)
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// processStream trims the FASTQ or BAM reads from in, compressed or not, and
+// writes the kept reads to out, handling every side output except those
+// that re-read the main output file.
+func processStream(in io.Reader, out io.Writer, opts Options) (*Stats, error) {
+	return processStreamCtx(context.Background(), in, out, opts)
+}
+
+// processStreamCtx is processStream that stops reading in once ctx is
+// cancelled. The reads taken in by then are trimmed and written, every
+// output flushed and closed as usual, and the counters so far come back
+// along with ctx.Err().
+func processStreamCtx(ctx context.Context, in io.Reader, out io.Writer, opts Options) (*Stats, error) {
+	if err := opts.Prepare(); err != nil {
+		return nil, err
+	}
+
+	var input io.Reader
+	if opts.DecompressCmd != "" {
+		dc, err := startDecompressor(opts.DecompressCmd, in)
+		if err != nil {
+			return nil, err
+		}
+		defer dc.Close()
+		input = dc
+	} else {
+		r, release, err := maybeDecompress(in)
+		switch {
+		case err == io.EOF:
+			// A zero-byte input holds no reads; still write a valid empty output.
+			input = strings.NewReader("")
+		case err != nil:
+			return nil, err
+		default:
+			defer release()
+			input = r
+		}
+	}
+
+	gw, err := newOutputWriter(out, &opts)
+	if err != nil {
+		return nil, err
+	}
+	defer gw.Close()
+	writer := bufio.NewWriter(gw)
+
+	if opts.Collapse {
+		opts.collapse = newCollapser(opts.CollapseMaxUnique, opts.CollapseTmpDir, opts.maxLineLen())
+	}
+
+	// Create channels for processing
+	resultsChan := make(chan *FastqRead, 1000) // Buffer size can be adjusted
+	doneChan := make(chan struct{})
+
+	if opts.TraceFraction > 0 && opts.TraceFile != "" {
+		traceOut, err := os.Create(opts.TraceFile)
+		if err != nil {
+			return nil, err
+		}
+		defer traceOut.Close()
+		opts.tracer = newTracer(traceOut)
+	}
+
+	if opts.InfoFile != "" {
+		infoOut, err := os.Create(opts.InfoFile)
+		if err != nil {
+			return nil, err
+		}
+		defer infoOut.Close()
+		opts.info = newInfoWriter(infoOut)
+	}
+
+	if opts.AnnotateAll != "" {
+		annotateOut, err := os.Create(opts.AnnotateAll)
+		if err != nil {
+			return nil, err
+		}
+		defer annotateOut.Close()
+		opts.annotator = newAnnotator(annotateOut, &opts)
+	}
+
+	if opts.TooShortOutput != "" {
+		shortOut, err := os.Create(opts.TooShortOutput)
+		if err != nil {
+			return nil, err
+		}
+		defer shortOut.Close()
+		opts.shortSink = newFastqSink(shortOut, &opts)
+	}
+
+	if opts.SplitByAdapter != "" {
+		missOut, err := os.Create(opts.SplitByAdapter)
+		if err != nil {
+			return nil, err
+		}
+		defer missOut.Close()
+		opts.missSink = newFastqSink(missOut, &opts)
+	}
+
+	if opts.Discarded != "" {
+		discardOut, err := os.Create(opts.Discarded)
+		if err != nil {
+			return nil, err
+		}
+		defer discardOut.Close()
+		opts.discarded = newDiscardSink(discardOut, &opts)
+	}
+
+	if opts.LabelFile != "" {
+		labelOut, err := os.Create(opts.LabelFile)
+		if err != nil {
+			return nil, err
+		}
+		defer labelOut.Close()
+		opts.labels = newLabelWriter(labelOut)
+	}
+
+	if opts.Parquet != "" {
+		parquetOut, err := os.Create(opts.Parquet)
+		if err != nil {
+			return nil, err
+		}
+		defer parquetOut.Close()
+		if opts.parquet, err = newParquetReport(parquetOut); err != nil {
+			return nil, err
+		}
+	}
+
+	var wg sync.WaitGroup
+	stats := Stats{Lengths: &lengthHist{}}
+	if opts.InsertPercentiles {
+		stats.InsertSizes = newInsertSizeEstimator()
+	}
+	if opts.ContaminationProfile != "" {
+		stats.AdapterProfile = &adapterProfile{}
+	}
+	if opts.QCReport != "" {
+		stats.QC = &qcProfile{}
+	}
+	if opts.QualityDist != "" {
+		stats.QualityDist = &qualityDist{}
+	}
+	if opts.InsertEndBed != "" {
+		stats.InsertEnds = newInsertEndCounter()
+	}
+	if opts.SplitByMode {
+		stats.InsertFates = &insertFates{}
+	}
+	if opts.OpticalDup {
+		stats.OpticalDups = newOpticalDupCounter(opts.OpticalDupDist)
+	}
+	if opts.UMIDedup {
+		stats.UMIDedup = newUMIDeduper(opts.labels != nil || opts.annotator != nil)
+	}
+
+	if opts.StatsInterval > 0 {
+		stopReporter := startStatsReporter(os.Stderr, &stats, opts.StatsInterval)
+		defer stopReporter()
+	}
+	if opts.ProgressInterval > 0 {
+		stopProgress := startProgressReporter(os.Stderr, &stats, opts.ProgressInterval)
+		defer stopProgress()
+	}
+
+	// BAM qualities are stored without an offset, so they are written with
+	// the configured one and there is nothing to detect.
+	var source readSource
+	buffered := bufio.NewReader(input)
+	bam := isBAM(buffered)
+	if bam {
+		source = newBAMReader(buffered, opts.qualBase())
+	} else {
+		source = newFastqReader(buffered, &opts)
+	}
+
+	if opts.Merge {
+		mates, closeMates, err := openMateSource(opts.Input2, &opts)
+		if err != nil {
+			return nil, err
+		}
+		defer closeMates()
+		source = &mergingSource{r1: source, r2: mates, minOverlap: opts.MergeMinOverlap, maxMismatch: mergeMaxMismatch, stats: &stats}
+	}
+
+	if opts.DedupHeaders != "" {
+		source = newDedupSource(source, opts.DedupHeaders, opts.DedupWindow, os.Stderr, &stats)
+	}
+
+	if opts.AutoQualBaseReads > 0 && !bam {
+		sample, err := readSample(source, opts.AutoQualBaseReads)
+		if err != nil {
+			return nil, err
+		}
+		if base, ok := guessQualBase(sample); ok {
+			opts.InQualBase = base
+			opts.prepare() // the per-adapter copies carry it too
+			fmt.Fprintf(os.Stderr, "Detected Phred+%d qualities from %s reads\n", base, Comma(int64(len(sample))))
+		}
+		source = &replaySource{reads: sample, rest: source}
+	}
+
+	if opts.AutoMaxErrorReads > 0 {
+		sample, err := readSample(source, opts.AutoMaxErrorReads)
+		if err != nil {
+			return nil, err
+		}
+		if threshold, ok := calibrateMaxError(sample, &opts, opts.AutoMaxErrorPct); ok {
+			opts.MaxError = threshold
+			opts.prepare() // the per-adapter copies carry it too
+			fmt.Fprintf(os.Stderr, "Calibrated maxError from %s reads: %.4g\n", Comma(int64(len(sample))), threshold)
+		} else {
+			fmt.Fprintf(os.Stderr, "No inserts among %s calibration reads, keeping maxError %.4g\n", Comma(int64(len(sample))), opts.MaxError)
+		}
+		source = &replaySource{reads: sample, rest: source}
+	}
+
+	// Start writer goroutine. From here on every return goes through the
+	// shutdown below, so the writer and workers always finish.
+	go writeResults(writer, &opts, resultsChan, doneChan, &stats)
+
+	// A fixed pool of workers trims the batches, so at most Workers batches
+	// are being trimmed and as many more wait in jobs however long the input.
+	batchSize := opts.batchSize()
+	jobs := make(chan readBatch, opts.workers())
+	var reorder *reorderBuffer
+	if opts.Ordered {
+		reorder = newReorderBuffer(resultsChan, 2*opts.workers())
+	}
+	for i := 0; i < opts.workers(); i++ {
+		go func() {
+			for batch := range jobs {
+				if reorder != nil {
+					reorder.trimBatch(batch, &opts, &wg, &stats)
+				} else {
+					ProcessBatch(batch.reads, &opts, resultsChan, &wg, &stats)
+				}
+			}
+		}()
+	}
+	var batches int64
+	send := func(reads []*FastqRead) {
+		wg.Add(1)
+		jobs <- readBatch{seq: atomic.AddInt64(&batches, 1) - 1, reads: reads}
+	}
+
+	// dispatch reads source to the end, handing its reads to the workers in
+	// batches. Every read it counts is handed out, so a cancelled or failed
+	// run still trims and writes all of TotalReads.
+	dispatch := func(source readSource) error {
+		reads := make([]*FastqRead, 0, batchSize)
+		var err error
+		for {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+			var read *FastqRead
+			read, err = source.next()
+			if err != nil {
+				break
+			}
+			read.index = atomic.AddInt64(&stats.TotalReads, 1) - 1
+			reads = append(reads, read)
+			if stats.OpticalDups != nil {
+				stats.OpticalDups.add(read)
+			}
+
+			if len(reads) == batchSize {
+				send(reads)
+				reads = make([]*FastqRead, 0, batchSize)
+			}
+		}
+
+		// Process remaining reads
+		if len(reads) > 0 {
+			send(reads)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	var dispatchErr error
+	if opts.ReadRanges > 1 {
+		ra, size, ok := readerAtSize(in)
+		if !ok {
+			dispatchErr = fmt.Errorf("-readRanges needs a regular input file")
+		} else {
+			dispatchErr = dispatchRanges(ra, size, opts.ReadRanges, &opts, dispatch)
+		}
+	} else {
+		dispatchErr = dispatch(source)
+	}
+	close(jobs)
+
+	// Wait for all processing to complete
+	wg.Wait()
+	if stats.UMIDedup != nil {
+		stats.UMIDuplicates = stats.UMIDedup.duplicates()
+		for _, read := range stats.UMIDedup.representatives() {
+			if opts.annotator != nil {
+				opts.annotator.write(read, nil)
+			}
+			resultsChan <- read
+		}
+		for _, read := range stats.UMIDedup.droppedCopies() {
+			if opts.labels != nil {
+				opts.labels.write(read.index, []byte{labelUMIDuplicate})
+			}
+			if opts.annotator != nil {
+				opts.annotator.write(read, errUMIDuplicate)
+			}
+		}
+	}
+	close(resultsChan)
+
+	// Wait for writer to finish
+	<-doneChan
+	if opts.collapse != nil && opts.collapse.err != nil {
+		return nil, fmt.Errorf("error collapsing reads: %v", opts.collapse.err)
+	}
+	if err := gw.Close(); err != nil {
+		return nil, fmt.Errorf("error writing output: %v", err)
+	}
+	// The reads before a read error are written out, but the run failed.
+	if dispatchErr != nil && dispatchErr != ctx.Err() {
+		return nil, dispatchErr
+	}
+	if opts.annotator != nil {
+		if err := opts.annotator.close(); err != nil {
+			return nil, fmt.Errorf("error writing annotated reads: %v", err)
+		}
+	}
+	if opts.parquet != nil {
+		if err := opts.parquet.close(); err != nil {
+			return nil, fmt.Errorf("error writing parquet report: %v", err)
+		}
+	}
+	if opts.shortSink != nil {
+		if err := opts.shortSink.close(); err != nil {
+			return nil, fmt.Errorf("error writing too-short reads: %v", err)
+		}
+	}
+	if opts.discarded != nil {
+		if err := opts.discarded.close(); err != nil {
+			return nil, fmt.Errorf("error writing discarded reads: %v", err)
+		}
+	}
+	if opts.labels != nil {
+		if err := opts.labels.error(); err != nil {
+			return nil, fmt.Errorf("error writing labels: %v", err)
+		}
+	}
+	if opts.info != nil {
+		if err := opts.info.flush(); err != nil {
+			return nil, fmt.Errorf("error writing info file: %v", err)
+		}
+	}
+	if opts.missSink != nil {
+		if err := opts.missSink.close(); err != nil {
+			return nil, fmt.Errorf("error writing adapter-missing reads: %v", err)
+		}
+	}
+	if opts.tracer != nil {
+		if err := opts.tracer.flush(); err != nil {
+			return nil, fmt.Errorf("error writing trace: %v", err)
+		}
+	}
+	if stats.QualityDist != nil {
+		if err := stats.QualityDist.writeTSVFile(opts.QualityDist); err != nil {
+			return nil, fmt.Errorf("error writing quality distribution: %v", err)
+		}
+	}
+	if opts.LengthHist != "" {
+		if err := stats.Lengths.writeTSVFile(opts.LengthHist); err != nil {
+			return nil, fmt.Errorf("error writing length histogram: %v", err)
+		}
+	}
+	if stats.AdapterProfile != nil {
+		if err := stats.AdapterProfile.writeCurveFile(opts.ContaminationProfile); err != nil {
+			return nil, fmt.Errorf("error writing contamination profile: %v", err)
+		}
+	}
+	if stats.QC != nil {
+		if err := stats.QC.writeTSVFile(opts.QCReport); err != nil {
+			return nil, fmt.Errorf("error writing QC report: %v", err)
+		}
+	}
+	if stats.InsertEnds != nil {
+		if err := stats.InsertEnds.writeBedGraphFile(opts.InsertEndBed); err != nil {
+			return nil, fmt.Errorf("error writing insert-end bedGraph: %v", err)
+		}
+	}
+
+	if opts.StatsBinary != "" {
+		if err := writeStatsBlobFile(opts.StatsBinary, &stats); err != nil {
+			return nil, fmt.Errorf("error writing binary stats: %v", err)
+		}
+	}
+
+	return &stats, ctx.Err()
+}
+
+func printSummary(w io.Writer, stats *Stats, opts *Options, duration time.Duration) {
+	green, magenta := color.New(color.FgHiGreen), color.New(color.FgHiMagenta)
+
+	// An empty input reports 0% rather than dividing by zero.
+	trimmedReadPercentage := percentOf(stats.TotalTrimmedReads, stats.TotalReads)
+
+	if stats.TotalReads == 0 {
+		magenta.Fprintln(w, "\nNo reads found in the input")
+	}
+	fmt.Fprintf(w, "\nTotal reads: %s\n", Comma(stats.TotalReads))
+	fmt.Fprintf(w, "Trimmed reads: %s\n", Comma(stats.TotalTrimmedReads))
+	green.Fprintf(w, "Percentage of trimmed reads: %.2f%%\n", trimmedReadPercentage)
+	magenta.Fprintf(w, "\nAdapter missing count: %s\n", Comma(stats.AdapterMissing))
+	magenta.Fprintf(w, "Too short count: %s\n", Comma(stats.TooShort))
+	if opts.MaxLen > 0 {
+		magenta.Fprintf(w, "Too long count: %s\n", Comma(stats.TooLong))
+	}
+	magenta.Fprintf(w, "Low quality count: %s\n", Comma(stats.LowQuality))
+	if opts.MaxN > 0 {
+		magenta.Fprintf(w, "Too many N count: %s\n", Comma(stats.TooManyN))
+	}
+	if opts.MinBaseQual > 0 {
+		magenta.Fprintf(w, "Low base quality count: %s\n", Comma(stats.LowBaseQual))
+	}
+	if opts.gcFilterEnabled() {
+		magenta.Fprintf(w, "GC filtered count: %s\n", Comma(stats.GCFiltered))
+	}
+	if opts.DetectNoInsert {
+		magenta.Fprintf(w, "No insert count: %s\n", Comma(stats.NoInsert))
+	}
+	if opts.MaxReadProcTime > 0 {
+		magenta.Fprintf(w, "Skipped (timeout) count: %s\n", Comma(stats.Timeout))
+	}
+	if len(opts.BarcodeAdapters) > 0 {
+		magenta.Fprintf(w, "Unknown barcode count: %s\n", Comma(stats.UnknownBarcode))
+	}
+	if opts.DedupHeaders != "" {
+		magenta.Fprintf(w, "Duplicate read IDs: %s\n", Comma(stats.DuplicateHeaders))
+	}
+	if opts.PolyTrim != "" {
+		magenta.Fprintf(w, "Poly-%s tail trimmed count: %s\n", strings.Join(strings.Split(opts.PolyTrim, ""), "/"), Comma(stats.PolyTrimmed))
+	}
+	if opts.Output2 != "" {
+		fate := "dropped"
+		if opts.KeepSingletons {
+			fate = "written to the .singletons files"
+		}
+		magenta.Fprintf(w, "Mates whose partner was dropped (%s): %s\n", fate, Comma(stats.Singletons))
+	}
+	if opts.Merge {
+		magenta.Fprintf(w, "Merged pairs: %s\n", Comma(stats.Merged))
+		magenta.Fprintf(w, "Unmerged pairs: %s\n", Comma(stats.Unmerged))
+	}
+	if stats.Diff != nil {
+		fmt.Fprintf(w, "\nCompared with previous output: %s\n", stats.Diff)
+	}
+	if opts.Funnel {
+		fmt.Fprintln(w)
+		writeFunnel(w, funnelStages(stats, opts))
+	}
+	if stats.OpticalDups != nil {
+		fmt.Fprintf(w, "\nOptical duplicates: %s (%.2f%% of %s reads with tile coordinates)\n",
+			Comma(stats.OpticalDups.duplicates()), stats.OpticalDups.rate()*100, Comma(stats.OpticalDups.parsed))
+	}
+	if stats.UMIDedup != nil {
+		fmt.Fprintf(w, "\nUMI duplicates: %s (%.2f%% of kept reads with a UMI)\n",
+			Comma(stats.UMIDedup.duplicates()), stats.UMIDedup.rate()*100)
+	}
+	if stats.InsertSizes != nil {
+		fmt.Fprintf(w, "\nInsert size percentiles (approx.): %s\n", stats.InsertSizes)
+	}
+	if stats.InsertFates != nil {
+		fmt.Fprintln(w)
+		writeModeSplit(w, stats.InsertFates)
+	}
+	if stats.Lengths != nil && stats.Lengths.total() > 0 {
+		fmt.Fprintln(w, "\nTrimmed read lengths:")
+		stats.Lengths.writeCompact(w)
+	}
+	fmt.Fprintf(w, "\nApplication execution time: %s\n", duration)
+}
@@ -0,0 +1,24 @@
+package trimmer
+
+import (
+	"os"
+	"strconv"
+)
+
+// countSidecarSuffix is appended to an output path to name its record count file.
+const countSidecarSuffix = ".count"
+
+// writeCountSidecars writes the record count next to every regular output
+// file so tools can learn it without decompressing. Pipes are skipped.
+func writeCountSidecars(outFiles []*os.File, records int64) error {
+	for _, f := range outFiles {
+		if info, err := os.Stat(f.Name()); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		data := []byte(strconv.FormatInt(records, 10) + "\n")
+		if err := os.WriteFile(f.Name()+countSidecarSuffix, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package trimmer
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountSidecar(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.fastq.gz")
+	outputFile := filepath.Join(dir, "out.fastq.gz")
+
+	f, err := os.Create(inputFile)
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	for i := 0; i < 3; i++ {
+		gw.Write([]byte("@KEPT\nGATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJ\n"))
+	}
+	gw.Write([]byte("@DROPPED\nGGGGGGGGGGGGGGGGGGGGGGGGG\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJ\n"))
+	gw.Close()
+	f.Close()
+
+	opts := Options{Adapter: "ATCACG", MinLen: 18, Min5Match: 4, MaxError: 0.1, CountSidecar: true, VerifyOutput: true}
+	assert.NoError(t, ProcessReadsFast(inputFile, outputFile, opts))
+	data, err := os.ReadFile(outputFile + countSidecarSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, "3", strings.TrimSpace(string(data)))
+
+	opts.KeepOriginal = true
+	assert.NoError(t, ProcessReadsFast(inputFile, outputFile, opts))
+	data, err = os.ReadFile(outputFile + countSidecarSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, "6", strings.TrimSpace(string(data)), "counts records, not reads")
+}
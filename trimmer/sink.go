@@ -0,0 +1,38 @@
+package trimmer
+
+import (
+	"bufio"
+	"io"
+	"sync"
+
+	"github.com/klauspost/pgzip"
+)
+
+// fastqSink is a gzipped FASTQ side output shared by the workers, so writes
+// are serialised.
+type fastqSink struct {
+	mu   sync.Mutex
+	gz   *pgzip.Writer
+	w    *bufio.Writer
+	opts *Options
+}
+
+func newFastqSink(w io.Writer, opts *Options) *fastqSink {
+	gz := pgzip.NewWriter(w)
+	return &fastqSink{gz: gz, w: bufio.NewWriter(gz), opts: opts}
+}
+
+func (s *fastqSink) write(read *FastqRead) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeRecord(s.w, read, s.opts)
+}
+
+func (s *fastqSink) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return s.gz.Close()
+}
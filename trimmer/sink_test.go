@@ -0,0 +1,121 @@
+package trimmer
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/pgzip"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTooShortOutput(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Trim5: 2, Min5Match: 8, MaxError: 0.1}
+	opts.shortSink = newFastqSink(&buf, opts)
+
+	reads := []*FastqRead{
+		{Header: "@KEPT", Sequence: "ACGTACGTACGTACGTACGTACTGGAATTCTCGG", Quality: "IIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIII"},
+		{Header: "@SHORT", Sequence: "ACGTACGTACTGGAATTCTCGGGTGCCAAGG", Quality: "ABCDEFGHIJJJJJJJJJJJJJJJJJJJJJJ"},
+		{Header: "@MISSING", Sequence: "ACGTACGTACGTACGTACGTACGT", Quality: "IIIIIIIIIIIIIIIIIIIIIIII"},
+	}
+	resultsChan := make(chan *FastqRead, len(reads))
+	var wg sync.WaitGroup
+	var stats Stats
+	wg.Add(1)
+	ProcessBatch(reads, opts, resultsChan, &wg, &stats)
+	assert.NoError(t, opts.shortSink.close())
+	assert.Equal(t, int64(1), stats.TooShort)
+	assert.Len(t, resultsChan, 1)
+
+	gr, err := pgzip.NewReader(&buf)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, "@SHORT\nGTACGTAC\n+\nCDEFGHIJ\n", string(data), "only the too-short read, with the adapter and 5' bases trimmed")
+}
+
+func TestTooShortOutputPastEnd(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 5, Trim5: 15, Min5Match: 8, SoftTrim: true}
+	opts.shortSink = newFastqSink(&buf, opts)
+
+	read := &FastqRead{Header: "@ADAPTER", Sequence: "ACGTGGAATTCTCGG", Quality: "ABCDEFGHIJJJJJJ"}
+	resultsChan := make(chan *FastqRead, 1)
+	var wg sync.WaitGroup
+	var stats Stats
+	wg.Add(1)
+	ProcessBatch([]*FastqRead{read}, opts, resultsChan, &wg, &stats)
+	assert.NoError(t, opts.shortSink.close())
+	assert.Equal(t, int64(0), stats.TooShort, "soft trimming keeps the whole read")
+
+	buf.Reset()
+	opts.MinLen = 18
+	opts.shortSink = newFastqSink(&buf, opts)
+	wg.Add(1)
+	ProcessBatch([]*FastqRead{read}, opts, resultsChan, &wg, &stats)
+	assert.NoError(t, opts.shortSink.close())
+	assert.Equal(t, int64(1), stats.TooShort)
+
+	gr, err := pgzip.NewReader(&buf)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, "@ADAPTER\n\n+\n\n", string(data), "a 5' cut past the read leaves an empty insert")
+}
+
+func TestSplitByAdapter(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Trim5: 2, Min5Match: 8, MaxError: 0.1}
+	opts.missSink = newFastqSink(&buf, opts)
+
+	reads := []*FastqRead{
+		{Header: "@KEPT", Sequence: "ACGTACGTACGTACGTACGTACTGGAATTCTCGG", Quality: "IIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIII"},
+		{Header: "@SHORT", Sequence: "ACGTACGTACTGGAATTCTCGGGTGCCAAGG", Quality: "ABCDEFGHIJJJJJJJJJJJJJJJJJJJJJJ"},
+		{Header: "@MISSING", Sequence: "ACGTACGTACGTACGTACGTACGT", Quality: "ABCDEFGHIIIIIIIIIIIIIIII"},
+	}
+	resultsChan := make(chan *FastqRead, len(reads))
+	var wg sync.WaitGroup
+	var stats Stats
+	wg.Add(1)
+	ProcessBatch(reads, opts, resultsChan, &wg, &stats)
+	assert.NoError(t, opts.missSink.close())
+	assert.Equal(t, int64(1), stats.AdapterMissing)
+	assert.Len(t, resultsChan, 1)
+	assert.Equal(t, "@KEPT", (<-resultsChan).Header)
+
+	gr, err := pgzip.NewReader(&buf)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, "@MISSING\nACGTACGTACGTACGTACGTACGT\n+\nABCDEFGHIIIIIIIIIIIIIIII\n", string(data), "only the adapter-missing read, without the 5' trim")
+}
+
+func TestDiscarded(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Trim5: 2, Min5Match: 8, MaxError: 0.1}
+	opts.discarded = newDiscardSink(&buf, opts)
+
+	reads := []*FastqRead{
+		{Header: "@KEPT", Sequence: "ACGTACGTACGTACGTACGTACTGGAATTCTCGG", Quality: "IIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIII"},
+		{Header: "@SHORT 1:N:0", Sequence: "ACGTACGTACTGGAATTCTCGGGTGCCAAGG", Quality: "ABCDEFGHIJJJJJJJJJJJJJJJJJJJJJJ"},
+		{Header: "@MISSING", Sequence: "ACGTACGTACGTACGTACGTACGT", Quality: "ABCDEFGHIIIIIIIIIIIIIIII"},
+		{Header: "@LOWQ", Sequence: "ACGTACGTACGTACGTACGTACTGGAATTCTCGG", Quality: "##################################"},
+	}
+	resultsChan := make(chan *FastqRead, len(reads))
+	var wg sync.WaitGroup
+	var stats Stats
+	wg.Add(1)
+	ProcessBatch(reads, opts, resultsChan, &wg, &stats)
+	assert.NoError(t, opts.discarded.close())
+	assert.Len(t, resultsChan, 1)
+
+	gr, err := pgzip.NewReader(&buf)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, "@SHORT 1:N:0 reason:too_short\nACGTACGTACTGGAATTCTCGGGTGCCAAGG\n+\nABCDEFGHIJJJJJJJJJJJJJJJJJJJJJJ\n"+
+		"@MISSING reason:adapter_missing\nACGTACGTACGTACGTACGTACGT\n+\nABCDEFGHIIIIIIIIIIIIIIII\n"+
+		"@LOWQ reason:low_quality\nACGTACGTACGTACGTACGTACTGGAATTCTCGG\n+\n##################################\n", string(data))
+}
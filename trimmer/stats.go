@@ -0,0 +1,211 @@
+package trimmer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds the run counters. Workers update them concurrently, so every
+// access to the counters goes through sync/atomic.
+type Stats struct {
+	TotalReads        int64
+	TotalTrimmedReads int64
+	AdapterMissing    int64
+	TooShort          int64
+	LowQuality        int64
+	NoInsert          int64
+	Timeout           int64
+	Merged            int64
+	Unmerged          int64
+	DuplicateHeaders  int64
+	UnknownBarcode    int64
+	GCFiltered        int64
+	Singletons        int64
+	PolyTrimmed       int64
+	TooManyN          int64
+	LowBaseQual       int64
+	TooLong           int64
+	UMIDuplicates     int64 // set once the run is over
+
+	// InsertSizes is only touched by the writer goroutine; nil unless
+	// -insertPercentiles is set.
+	InsertSizes *insertSizeEstimator
+
+	// Lengths is only touched by the writer goroutine; set by every pipeline.
+	Lengths *lengthHist
+
+	// AdapterProfile is merged into by the workers under its own lock; nil
+	// unless -contaminationProfile is set.
+	AdapterProfile *adapterProfile
+
+	// InsertFates is merged into by the workers under its own lock; nil
+	// unless -splitByMode is set.
+	InsertFates *insertFates
+
+	// QC is merged into by the workers under its own lock; nil unless
+	// -qcReport is set.
+	QC *qcProfile
+
+	// InsertEnds is added to by the workers under its own lock; nil unless
+	// -insertEndBed is set.
+	InsertEnds *insertEndCounter
+
+	// QualityDist is only touched by the writer goroutine; nil unless
+	// -qualityDist is set.
+	QualityDist *qualityDist
+
+	// Diff is filled in after the output is written; nil unless
+	// -diffAgainst is set.
+	Diff *outputDiff
+
+	// OpticalDups is fed by the reader loop; nil unless -opticalDup is set.
+	OpticalDups *opticalDupCounter
+
+	// UMIDedup is added to by the workers under its own lock; nil unless
+	// -umiDedup is set.
+	UMIDedup *umiDeduper
+}
+
+// snapshot formats the current counters as a single line. The counters of
+// optional stages follow only once they are non-zero.
+func (s *Stats) snapshot() string {
+	line := fmt.Sprintf("reads=%s trimmed=%s adapterMissing=%s tooShort=%s lowQuality=%s noInsert=%s timeout=%s",
+		Comma(atomic.LoadInt64(&s.TotalReads)),
+		Comma(atomic.LoadInt64(&s.TotalTrimmedReads)),
+		Comma(atomic.LoadInt64(&s.AdapterMissing)),
+		Comma(atomic.LoadInt64(&s.TooShort)),
+		Comma(atomic.LoadInt64(&s.LowQuality)),
+		Comma(atomic.LoadInt64(&s.NoInsert)),
+		Comma(atomic.LoadInt64(&s.Timeout)),
+	)
+	for _, c := range []struct {
+		name    string
+		counter *int64
+	}{
+		{"tooManyN", &s.TooManyN},
+		{"lowBaseQual", &s.LowBaseQual},
+		{"tooLong", &s.TooLong},
+		{"gcFiltered", &s.GCFiltered},
+		{"unknownBarcode", &s.UnknownBarcode},
+		{"polyTrimmed", &s.PolyTrimmed},
+		{"duplicateHeaders", &s.DuplicateHeaders},
+	} {
+		if n := atomic.LoadInt64(c.counter); n != 0 {
+			line += fmt.Sprintf(" %s=%s", c.name, Comma(n))
+		}
+	}
+	return line
+}
+
+// startStatsReporter writes a counter snapshot to w every interval until the
+// returned stop function is called.
+func startStatsReporter(w io.Writer, stats *Stats, interval time.Duration) (stop func()) {
+	return everyInterval(interval, func() {
+		fmt.Fprintln(w, stats.snapshot())
+	})
+}
+
+// startProgressReporter writes the reads read so far, and how many a second
+// were read since the previous line, to w every interval until the returned
+// stop function is called.
+func startProgressReporter(w io.Writer, stats *Stats, interval time.Duration) (stop func()) {
+	last, lastTime := int64(0), time.Now()
+	return everyInterval(interval, func() {
+		reads, now := atomic.LoadInt64(&stats.TotalReads), time.Now()
+		rate := float64(reads-last) / now.Sub(lastTime).Seconds()
+		fmt.Fprintf(w, "Processed %s reads (%s reads/s)\n", Comma(reads), Comma(int64(rate)))
+		last, lastTime = reads, now
+	})
+}
+
+// everyInterval calls tick from its own goroutine every interval until the
+// returned stop function is called; stop waits for a running tick to end.
+func everyInterval(interval time.Duration, tick func()) (stop func()) {
+	ticker := time.NewTicker(interval)
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ticker.C:
+				tick()
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(quit)
+		<-done
+	}
+}
+
+// countDropped counts a read rejected by TrimRead under its reason.
+func (s *Stats) countDropped(err error) {
+	switch {
+	case errors.Is(err, ErrAdapterMissing):
+		atomic.AddInt64(&s.AdapterMissing, 1)
+	case errors.Is(err, ErrTooShort):
+		atomic.AddInt64(&s.TooShort, 1)
+	case errors.Is(err, ErrLowQuality):
+		atomic.AddInt64(&s.LowQuality, 1)
+	case errors.Is(err, ErrNoInsert):
+		atomic.AddInt64(&s.NoInsert, 1)
+	case errors.Is(err, ErrTimeout):
+		atomic.AddInt64(&s.Timeout, 1)
+	case errors.Is(err, ErrUnknownBarcode):
+		atomic.AddInt64(&s.UnknownBarcode, 1)
+	case errors.Is(err, ErrGCFiltered):
+		atomic.AddInt64(&s.GCFiltered, 1)
+	case errors.Is(err, ErrTooManyN):
+		atomic.AddInt64(&s.TooManyN, 1)
+	case errors.Is(err, ErrLowBaseQual):
+		atomic.AddInt64(&s.LowBaseQual, 1)
+	case errors.Is(err, ErrTooLong):
+		atomic.AddInt64(&s.TooLong, 1)
+	}
+}
+
+// add accumulates the counters of other into s. Writer-owned estimators
+// cannot be merged and are left untouched.
+func (s *Stats) add(other *Stats) {
+	s.TotalReads += other.TotalReads
+	s.TotalTrimmedReads += other.TotalTrimmedReads
+	s.AdapterMissing += other.AdapterMissing
+	s.TooShort += other.TooShort
+	s.LowQuality += other.LowQuality
+	s.NoInsert += other.NoInsert
+	s.Timeout += other.Timeout
+	s.Merged += other.Merged
+	s.Unmerged += other.Unmerged
+	s.DuplicateHeaders += other.DuplicateHeaders
+	s.UnknownBarcode += other.UnknownBarcode
+	s.GCFiltered += other.GCFiltered
+	s.Singletons += other.Singletons
+	s.PolyTrimmed += other.PolyTrimmed
+	s.TooManyN += other.TooManyN
+	s.LowBaseQual += other.LowBaseQual
+	s.TooLong += other.TooLong
+	s.UMIDuplicates += other.UMIDuplicates
+	if other.Lengths != nil {
+		if s.Lengths == nil {
+			s.Lengths = &lengthHist{}
+		}
+		s.Lengths.merge(other.Lengths)
+	}
+}
+
+// recordsWritten is the number of FASTQ records in the main output, which
+// differs from the kept-read count when each read is written twice.
+func recordsWritten(s *Stats, opts *Options) int64 {
+	n := atomic.LoadInt64(&s.TotalTrimmedReads)
+	if opts.KeepOriginal {
+		n *= 2
+	}
+	return n
+}
@@ -0,0 +1,88 @@
+package trimmer
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer is a bytes.Buffer safe for use by a background reporter.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestStatsReporter(t *testing.T) {
+	var stats Stats
+	var out syncBuffer
+
+	stop := startStatsReporter(&out, &stats, 5*time.Millisecond)
+	for i := 0; i < 10; i++ {
+		atomic.AddInt64(&stats.TotalReads, 1000)
+		atomic.AddInt64(&stats.TooShort, 1)
+		time.Sleep(3 * time.Millisecond)
+	}
+	stop()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.GreaterOrEqual(t, len(lines), 2, "expected periodic snapshots")
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "reads="), "unexpected snapshot line: %q", line)
+		assert.Contains(t, line, "adapterMissing=0")
+	}
+
+	// Nothing is written once stopped.
+	before := out.String()
+	time.Sleep(15 * time.Millisecond)
+	assert.Equal(t, before, out.String())
+	assert.Equal(t, "reads=10,000 trimmed=0 adapterMissing=0 tooShort=10 lowQuality=0 noInsert=0 timeout=0", stats.snapshot())
+
+	stats.TooLong, stats.GCFiltered, stats.DuplicateHeaders = 3, 1200, 2
+	assert.Equal(t, "reads=10,000 trimmed=0 adapterMissing=0 tooShort=10 lowQuality=0 noInsert=0 timeout=0 tooLong=3 gcFiltered=1,200 duplicateHeaders=2", stats.snapshot())
+}
+
+func TestProgressReporter(t *testing.T) {
+	var stats Stats
+	var out syncBuffer
+
+	stop := startProgressReporter(&out, &stats, 5*time.Millisecond)
+	for i := 0; i < 10; i++ {
+		atomic.AddInt64(&stats.TotalReads, 1000)
+		time.Sleep(3 * time.Millisecond)
+	}
+	stop()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.GreaterOrEqual(t, len(lines), 2, "expected periodic progress lines")
+	for _, line := range lines {
+		assert.Regexp(t, `^Processed [0-9,]+ reads \([0-9,]+ reads/s\)$`, line)
+	}
+}
+
+func TestProcessStreamProgress(t *testing.T) {
+	read := "@R\nACGTACGTACGTACGTACGTTGGAATTCTCGG\n+\n" + strings.Repeat("I", 32) + "\n"
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, ProgressInterval: time.Millisecond}
+
+	var out bytes.Buffer
+	stats, err := processStream(strings.NewReader(strings.Repeat(read, 20000)), &out, opts)
+	assert.NoError(t, err, "a ticker faster than the run must not hold it up")
+	assert.Equal(t, int64(20000), stats.TotalTrimmedReads)
+	assert.NotContains(t, out.String(), "Processed", "progress goes to stderr, not the output")
+}
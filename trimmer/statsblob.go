@@ -0,0 +1,120 @@
+package trimmer
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// statsBlobVersion is written first in every -statsBinary blob. Adding
+// fields keeps the version, since gob ignores fields a reader does not
+// know; renaming, removing or changing the meaning of one bumps it.
+const statsBlobVersion = 1
+
+// statsBlob is the gob-encoded -statsBinary record, version 1:
+//
+//	Version            int     statsBlobVersion
+//	TotalReads         int64   reads read from the input
+//	TotalTrimmedReads  int64   reads written
+//	AdapterMissing     int64   reads dropped per reason ...
+//	TooShort           int64
+//	LowQuality         int64
+//	NoInsert           int64
+//	Timeout            int64
+//	Merged, Unmerged   int64   pairs under -merge
+//	DuplicateHeaders   int64   repeats under -dedupHeaders
+//	UnknownBarcode     int64   reads dropped under -barcodeAdapters
+//	GCFiltered         int64   reads dropped under -minGC/-maxGC
+//	Singletons         int64   kept mates whose partner was dropped, under -o2
+//	PolyTrimmed        int64   kept reads whose tail -polyTrim cut
+//	TooManyN           int64   reads dropped under -maxN
+//	LowBaseQual        int64   reads dropped under -minBaseQual
+//	TooLong            int64   reads dropped under -maxLen
+//	UMIDuplicates      int64   kept reads dropped as copies under -umiDedup
+//	QualityCounts      []int64 output bases per Phred score; empty without -qualityDist
+//	AdapterStarts      []int64 reads whose adapter starts at each position; empty without -contaminationProfile
+type statsBlob struct {
+	Version           int
+	TotalReads        int64
+	TotalTrimmedReads int64
+	AdapterMissing    int64
+	TooShort          int64
+	LowQuality        int64
+	NoInsert          int64
+	Timeout           int64
+	Merged            int64
+	Unmerged          int64
+	DuplicateHeaders  int64
+	UnknownBarcode    int64
+	GCFiltered        int64
+	Singletons        int64
+	PolyTrimmed       int64
+	TooManyN          int64
+	LowBaseQual       int64
+	TooLong           int64
+	UMIDuplicates     int64
+	QualityCounts     []int64
+	AdapterStarts     []int64
+}
+
+func newStatsBlob(s *Stats) *statsBlob {
+	b := &statsBlob{
+		Version:           statsBlobVersion,
+		TotalReads:        s.TotalReads,
+		TotalTrimmedReads: s.TotalTrimmedReads,
+		AdapterMissing:    s.AdapterMissing,
+		TooShort:          s.TooShort,
+		LowQuality:        s.LowQuality,
+		NoInsert:          s.NoInsert,
+		Timeout:           s.Timeout,
+		Merged:            s.Merged,
+		Unmerged:          s.Unmerged,
+		DuplicateHeaders:  s.DuplicateHeaders,
+		UnknownBarcode:    s.UnknownBarcode,
+		GCFiltered:        s.GCFiltered,
+		Singletons:        s.Singletons,
+		PolyTrimmed:       s.PolyTrimmed,
+		TooManyN:          s.TooManyN,
+		LowBaseQual:       s.LowBaseQual,
+		TooLong:           s.TooLong,
+		UMIDuplicates:     s.UMIDuplicates,
+	}
+	if s.QualityDist != nil {
+		b.QualityCounts = append([]int64(nil), s.QualityDist.counts[:]...)
+	}
+	if s.AdapterProfile != nil {
+		s.AdapterProfile.mu.Lock()
+		b.AdapterStarts = append([]int64(nil), s.AdapterProfile.starts...)
+		s.AdapterProfile.mu.Unlock()
+	}
+	return b
+}
+
+func writeStatsBlob(w io.Writer, s *Stats) error {
+	return gob.NewEncoder(w).Encode(newStatsBlob(s))
+}
+
+// readStatsBlob decodes a blob, refusing versions it does not understand.
+func readStatsBlob(r io.Reader) (*statsBlob, error) {
+	var b statsBlob
+	if err := gob.NewDecoder(r).Decode(&b); err != nil {
+		return nil, err
+	}
+	if b.Version != statsBlobVersion {
+		return nil, fmt.Errorf("unsupported stats blob version %d, want %d", b.Version, statsBlobVersion)
+	}
+	return &b, nil
+}
+
+func writeStatsBlobFile(path string, s *Stats) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeStatsBlob(f, s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
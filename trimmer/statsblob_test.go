@@ -0,0 +1,52 @@
+package trimmer
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsBlobRoundTrip(t *testing.T) {
+	stats := &Stats{
+		TotalReads:        100,
+		TotalTrimmedReads: 70,
+		AdapterMissing:    10,
+		TooShort:          15,
+		LowQuality:        5,
+		QualityDist:       &qualityDist{},
+		AdapterProfile:    &adapterProfile{},
+	}
+	stats.QualityDist.add("II#", 33)
+	batch := &adapterProfileBatch{}
+	batch.add(3, 10)
+	batch.add(3, 10)
+	batch.add(-1, 10)
+	stats.AdapterProfile.merge(batch)
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeStatsBlob(&buf, stats))
+	blob, err := readStatsBlob(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, statsBlobVersion, blob.Version)
+	assert.Equal(t, int64(100), blob.TotalReads)
+	assert.Equal(t, int64(70), blob.TotalTrimmedReads)
+	assert.Equal(t, int64(15), blob.TooShort)
+	assert.Equal(t, int64(2), blob.QualityCounts[40])
+	assert.Equal(t, int64(1), blob.QualityCounts[2])
+	assert.Equal(t, []int64{0, 0, 0, 2}, blob.AdapterStarts)
+
+	// Histograms that were not collected stay empty.
+	buf.Reset()
+	assert.NoError(t, writeStatsBlob(&buf, &Stats{TotalReads: 1}))
+	blob, err = readStatsBlob(&buf)
+	assert.NoError(t, err)
+	assert.Empty(t, blob.QualityCounts)
+	assert.Empty(t, blob.AdapterStarts)
+
+	buf.Reset()
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(statsBlob{Version: statsBlobVersion + 1}))
+	_, err = readStatsBlob(&buf)
+	assert.EqualError(t, err, "unsupported stats blob version 2, want 1")
+}
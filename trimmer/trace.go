@@ -0,0 +1,59 @@
+package trimmer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+)
+
+// trimTrace records the decisions TrimRead made for a single read.
+type trimTrace struct {
+	Header       string
+	AdapterIndex int
+	Adapter      string // sequence of the adapter found at AdapterIndex
+	AdapterNum   int    // 1-based position of that adapter among those searched
+	Start, End   int
+	MeanError    float64 // NaN if the quality filter was not reached
+	Decision     string  // "kept" or the rejection reason
+}
+
+func newTrimTrace(read *FastqRead) *trimTrace {
+	return &trimTrace{Header: read.Header, AdapterIndex: -1, Start: -1, End: -1, MeanError: math.NaN()}
+}
+
+const traceColumns = "read\tadapterIndex\tstart\tend\tmeanError\tdecision"
+
+func (t *trimTrace) String() string {
+	meanErr := "NA"
+	if !math.IsNaN(t.MeanError) {
+		meanErr = strconv.FormatFloat(t.MeanError, 'g', 6, 64)
+	}
+	return fmt.Sprintf("%s\t%d\t%d\t%d\t%s\t%s", t.Header, t.AdapterIndex, t.Start, t.End, meanErr, t.Decision)
+}
+
+// tracer writes trimTraces from multiple workers to a single TSV.
+type tracer struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func newTracer(w io.Writer) *tracer {
+	t := &tracer{w: bufio.NewWriter(w)}
+	t.w.WriteString(traceColumns + "\n")
+	return t
+}
+
+func (t *tracer) write(tr *trimTrace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.w.WriteString(tr.String() + "\n")
+}
+
+func (t *tracer) flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.w.Flush()
+}
@@ -0,0 +1,73 @@
+package trimmer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessReadsFastTrace(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.fastq.gz")
+	traceFile := filepath.Join(dir, "trace.tsv")
+
+	f, err := os.Create(inputFile)
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte("@KEPT\nGATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC\n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ\n" +
+		"@MISSING\nGGGGGGGGGGGGGGGGGGGGGGGGGGGGGG\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJ\n" +
+		"@SHORT\nGATCGGAAGATCACGATCTCGTATGC\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJJ\n"))
+	gw.Close()
+	f.Close()
+
+	opts := Options{Adapter: "ATCACG", MinLen: 18, Min5Match: 4, MaxError: 0.1, TraceFraction: 1, TraceFile: traceFile}
+	assert.NoError(t, ProcessReadsFast(inputFile, filepath.Join(dir, "out.fastq.gz"), opts))
+
+	data, err := os.ReadFile(traceFile)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, traceColumns, lines[0])
+
+	byRead := map[string][]string{}
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		byRead[fields[0]] = fields
+	}
+	assert.Len(t, byRead, 3)
+	assert.Equal(t, []string{"@KEPT", "33", "0", "33", byRead["@KEPT"][4], "kept"}, byRead["@KEPT"])
+	assert.NotEqual(t, "NA", byRead["@KEPT"][4])
+	assert.Equal(t, []string{"@MISSING", "-1", "-1", "-1", "NA", "adapter missing"}, byRead["@MISSING"])
+	assert.Equal(t, []string{"@SHORT", "9", "0", "9", "NA", "too short"}, byRead["@SHORT"])
+}
+
+func TestProcessBatchTraceSampling(t *testing.T) {
+	reads := []*FastqRead{
+		{Header: "@R1", Sequence: "GATCGGAAGAGCACACGTCTATCACG", Quality: "JJJJJJJJJJJJJJJJJJJJJJJJJJ"},
+		{Header: "@R2", Sequence: "GATCGGAAGAGCACACGTCTATCACG", Quality: "JJJJJJJJJJJJJJJJJJJJJJJJJJ"},
+	}
+
+	for _, tc := range []struct {
+		fraction float64
+		want     int
+	}{{0, 0}, {1, 2}} {
+		var buf bytes.Buffer
+		opts := &Options{Adapter: "ATCACG", MinLen: 5, Min5Match: 4, MaxError: 0.1, TraceFraction: tc.fraction}
+		opts.tracer = newTracer(&buf)
+		resultsChan := make(chan *FastqRead, len(reads))
+		var wg sync.WaitGroup
+		var stats Stats
+		wg.Add(1)
+		ProcessBatch(reads, opts, resultsChan, &wg, &stats)
+		assert.NoError(t, opts.tracer.flush())
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, tc.want+1, "fraction %v", tc.fraction)
+		assert.Len(t, resultsChan, len(reads), "tracing must not change the results")
+	}
+}
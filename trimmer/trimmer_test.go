@@ -0,0 +1,1086 @@
+package trimmer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Utility function tests remain unchanged
+func TestPhredToError(t *testing.T) {
+	tests := []struct {
+		name      string
+		qual      byte
+		base      int
+		wantError float64
+	}{
+		{
+			name:      "MinimumQualityScore",
+			qual:      33,
+			base:      33,
+			wantError: 1.0,
+		},
+		{
+			name:      "QualityScoreOf43",
+			qual:      43,
+			base:      33,
+			wantError: 0.1,
+		},
+		{
+			name:      "QualityScoreOf60",
+			qual:      60,
+			base:      33,
+			wantError: 0.002,
+		},
+		{
+			name:      "MaximumQualityScore",
+			qual:      74,
+			base:      33,
+			wantError: math.Pow(10, -41/10.0),
+		},
+		{
+			name:      "Phred64MinimumQualityScore",
+			qual:      64,
+			base:      64,
+			wantError: 1.0,
+		},
+		{
+			name:      "Phred64QualityScoreOf30",
+			qual:      94,
+			base:      64,
+			wantError: 0.001,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gotError := PhredToError(tc.qual, tc.base)
+			if math.Abs(gotError-tc.wantError) > 1e-5 {
+				t.Errorf("PhredToError(%v, %d) = %v, want %v", tc.qual, tc.base, gotError, tc.wantError)
+			}
+		})
+	}
+}
+
+func TestMeanError(t *testing.T) {
+	tests := []struct {
+		name string
+		qual []byte
+		base int
+		want float64
+	}{
+		{
+			name: "EmptyQualityString",
+			qual: []byte{},
+			base: 33,
+			want: math.NaN(),
+		},
+		{
+			name: "AllMinimumQualityScores",
+			qual: []byte{33, 33, 33, 33, 33},
+			base: 33,
+			want: 1.0,
+		},
+		{
+			name: "MixedQualityScores",
+			qual: []byte{33, 43, 60, 70},
+			base: 33,
+			want: (1.0 + 0.1 + 0.002 + 0.0002) / 4,
+		},
+		{
+			name: "Phred64MixedQualityScores",
+			qual: []byte{64, 74, 91, 101},
+			base: 64,
+			want: (1.0 + 0.1 + 0.002 + 0.0002) / 4,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := MeanError(tc.qual, tc.base)
+			if math.IsNaN(tc.want) {
+				if !math.IsNaN(got) {
+					t.Errorf("MeanError(%v, %d) = %v, want NaN", tc.qual, tc.base, got)
+				}
+			} else if math.Abs(got-tc.want) > 1e-5 {
+				t.Errorf("MeanError(%v, %d) = %v, want %v", tc.qual, tc.base, got, tc.want)
+			}
+		})
+	}
+}
+
+// Updated test for ProcessBatch with channel-based implementation
+func TestProcessBatch(t *testing.T) {
+	resultsChan := make(chan *FastqRead, 100)
+	var wg sync.WaitGroup
+	var stats Stats
+	maxError := 0.1
+
+	t.Run("Adapter missing", func(t *testing.T) {
+		wg.Add(1)
+		read := &FastqRead{
+			Header:   "@ERR000589.1",
+			Sequence: "GATCGGAAGAGC",
+			Quality:  "BCCFFFFFFHHHH",
+		}
+		go ProcessBatch([]*FastqRead{read}, &Options{Adapter: "ACGTACGTAC", MinLen: 10, Trim5: 2, Trim3: 2, Min5Match: 10, MaxError: maxError}, resultsChan, &wg, &stats)
+		wg.Wait()
+		assert.Equal(t, int64(1), stats.AdapterMissing)
+
+		// Ensure channel is empty
+		select {
+		case read := <-resultsChan:
+			t.Errorf("Expected empty channel, got read: %v", read)
+		default:
+			// Channel is empty as expected
+		}
+	})
+
+	t.Run("Too short", func(t *testing.T) {
+		wg.Add(1)
+		read := &FastqRead{
+			Header:   "@ERR000589.1",
+			Sequence: "ATCG",
+			Quality:  "JJJJ",
+		}
+		go ProcessBatch([]*FastqRead{read}, &Options{Adapter: "ATCG", MinLen: 5, Trim5: 2, Trim3: 2, Min5Match: 4, MaxError: maxError}, resultsChan, &wg, &stats)
+		wg.Wait()
+		assert.Equal(t, int64(1), stats.TooShort) // Count is 2 because it's cumulative from previous test
+
+		select {
+		case read := <-resultsChan:
+			t.Errorf("Expected empty channel, got read: %v", read)
+		default:
+			// Channel is empty as expected
+		}
+	})
+
+	t.Run("Successful trimming - nextFlex", func(t *testing.T) {
+		wg.Add(1)
+		read := &FastqRead{
+			Header:   "@ERR000589.1",
+			Sequence: "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC",
+			Quality:  "BCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ",
+		}
+		expectedTrimmed := "TCGGAAGAGCACACGTCTGAACTCCAGTC"
+
+		go ProcessBatch([]*FastqRead{read}, &Options{Adapter: "ATCACG", MinLen: 5, Trim5: 2, Trim3: 2, Min5Match: 4, MaxError: maxError}, resultsChan, &wg, &stats)
+		wg.Wait()
+
+		// Read from channel
+		trimmedRead := <-resultsChan
+		assert.NotNil(t, trimmedRead)
+		assert.Equal(t, read.Header, trimmedRead.Header)
+		assert.Equal(t, expectedTrimmed, trimmedRead.Sequence, "Trimmed sequence does not match expected output")
+
+		// Optional: Also verify the quality string was trimmed to match
+		assert.Equal(t, len(trimmedRead.Quality), len(trimmedRead.Sequence),
+			"Quality string length should match sequence length")
+	})
+
+	t.Run("Successful trimming - no rnd", func(t *testing.T) {
+		wg.Add(1)
+		read := &FastqRead{
+			Header:   "@ERR000589.1",
+			Sequence: "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC",
+			Quality:  "BCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ",
+		}
+		expectedTrimmed := "GATCGGAAGAGCACACGTCTGAACTCCAGTCAC"
+
+		go ProcessBatch([]*FastqRead{read}, &Options{Adapter: "ATCACG", MinLen: 5, Trim5: 0, Trim3: 0, Min5Match: 4, MaxError: maxError}, resultsChan, &wg, &stats)
+		wg.Wait()
+
+		// Read from channel
+		trimmedRead := <-resultsChan
+		assert.NotNil(t, trimmedRead)
+		assert.Equal(t, read.Header, trimmedRead.Header)
+		assert.Equal(t, expectedTrimmed, trimmedRead.Sequence, "Trimmed sequence does not match expected output")
+
+		// Optional: Also verify the quality string was trimmed to match
+		assert.Equal(t, len(trimmedRead.Quality), len(trimmedRead.Sequence),
+			"Quality string length should match sequence length")
+	})
+
+	// Close the channel after all tests
+	close(resultsChan)
+}
+
+func TestTrimReadReverseInput(t *testing.T) {
+	// The adapter only appears once the read is reversed, so finding it
+	// proves the reversal happens before the adapter search and trims.
+	read := &FastqRead{
+		Header:   "@READ1",
+		Sequence: "AAAAGCACTAGGGCCCTTTAAACCCGGGTTT",
+		Quality:  "ABCDEFGHIJJJJJJJJJJJJJJJJJJJJJJ",
+	}
+	opts := &Options{Adapter: "ATCACG", MinLen: 5, Trim5: 2, Trim3: 1, Min5Match: 4, MaxError: 0.1}
+
+	_, err := TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing)
+
+	opts.ReverseInput = true
+	trimmed, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "TGGGCCCAAATTTCCCGG", trimmed.Sequence)
+	assert.Equal(t, "JJJJJJJJJJJJJJJJJJ", trimmed.Quality)
+	assert.Equal(t, "AAAAGCACTAGGGCCCTTTAAACCCGGGTTT", read.Sequence, "input read should not be modified")
+}
+
+func TestProcessBatchNoInsert(t *testing.T) {
+	resultsChan := make(chan *FastqRead, 10)
+	var wg sync.WaitGroup
+	var stats Stats
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Trim5: 2, Min5Match: 8, MaxError: 0.1, DetectNoInsert: true}
+
+	reads := []*FastqRead{
+		{Header: "@ADAPTER", Sequence: "TGGAATTCTCGGGTGCCAAGG", Quality: "JJJJJJJJJJJJJJJJJJJJJ"},
+		{Header: "@WITHIN_TRIM5", Sequence: "ACTGGAATTCTCGGGTGCCAA", Quality: "JJJJJJJJJJJJJJJJJJJJJ"},
+		{Header: "@SHORT", Sequence: "ACGTACGTTGGAATTCTCGGG", Quality: "JJJJJJJJJJJJJJJJJJJJJ"},
+	}
+	wg.Add(1)
+	ProcessBatch(reads, opts, resultsChan, &wg, &stats)
+
+	assert.Equal(t, int64(2), stats.NoInsert)
+	assert.Equal(t, int64(1), stats.TooShort)
+	assert.Empty(t, resultsChan)
+
+	// Without detection the same reads are all counted as too short.
+	stats = Stats{}
+	opts.DetectNoInsert = false
+	wg.Add(1)
+	ProcessBatch(reads, opts, resultsChan, &wg, &stats)
+	assert.Equal(t, int64(0), stats.NoInsert)
+	assert.Equal(t, int64(3), stats.TooShort)
+}
+
+func TestTrimReadSoftTrim(t *testing.T) {
+	long := &FastqRead{Header: "@LONG", Sequence: "ACGTACGTACGTACGTACGTTGGAATTCTCGG", Quality: strings.Repeat("J", 32)}
+	short := &FastqRead{Header: "@SHORT", Sequence: "ACGTACGTTGGAATTCTCGGGTGCCAAGG", Quality: strings.Repeat("J", 29)}
+	tiny := &FastqRead{Header: "@TINY", Sequence: "ACGTTGGAATTCTCGG", Quality: strings.Repeat("J", 16)}
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 18, Min5Match: 8, MaxError: 0.1}
+
+	_, err := TrimRead(short, opts)
+	assert.ErrorIs(t, err, ErrTooShort)
+
+	opts.SoftTrim = true
+	trimmed, err := TrimRead(long, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "ACGTACGTACGTACGTACGT", trimmed.Sequence, "long enough inserts are still trimmed")
+
+	trimmed, err = TrimRead(short, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, short.Sequence, trimmed.Sequence, "a read that would become too short is kept untrimmed")
+	assert.Equal(t, short.Quality, trimmed.Quality)
+
+	_, err = TrimRead(tiny, opts)
+	assert.ErrorIs(t, err, ErrTooShort, "a read shorter than minLen as a whole is still dropped")
+}
+
+func TestTrimReadNoQualFilter(t *testing.T) {
+	read := &FastqRead{
+		Header:   "@READ1",
+		Sequence: "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC",
+		Quality:  "##################################################",
+	}
+	opts := &Options{Adapter: "ATCACG", MinLen: 5, Min5Match: 4, MaxError: 0.1}
+
+	_, err := TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrLowQuality)
+
+	opts.NoQualFilter = true
+	trimmed, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "GATCGGAAGAGCACACGTCTGAACTCCAGTCAC", trimmed.Sequence)
+
+	opts.NoQualFilter = false
+	opts.MaxError = 0
+	_, err = TrimRead(read, opts)
+	assert.NoError(t, err, "maxError <= 0 should disable the filter")
+}
+
+func TestTrimReadTruncateTo(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinLen: 5, Trim5: 2, TruncateTo: 10}
+
+	long := &FastqRead{Header: "@LONG", Sequence: "NNACGTACGTACGTACGTTGGAATTCTCGG", Quality: "##ABCDEFGHIJKLMNOPIIIIIIIIIIII"}
+	kept, err := TrimRead(long, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "ACGTACGTAC", kept.Sequence, "the 5' end is kept")
+	assert.Equal(t, "ABCDEFGHIJ", kept.Quality)
+
+	short := &FastqRead{Header: "@SHORT", Sequence: "NNACGTACGTGGAATTCTCGG", Quality: "##ABCDEFGIIIIIIIIIIII"}
+	kept, err = TrimRead(short, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "ACGTACG", kept.Sequence, "shorter reads are unchanged")
+}
+
+func TestTrimReadTrim3PastStart(t *testing.T) {
+	// The adapter starts 3 bases in, so -trim3 10 would end the insert 7
+	// bases before the 5' trim.
+	read := &FastqRead{
+		Header:   "@READ1",
+		Sequence: "ACG" + "TGGAATTCTCGG",
+		Quality:  strings.Repeat("I", 15),
+	}
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, Trim5: 1, Trim3: 10}
+
+	short, err := TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrTooShort, "rejected even with no -minLen")
+	assert.Equal(t, "", short.Sequence)
+
+	opts.DetectNoInsert = true
+	_, err = TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrNoInsert)
+
+	opts.DetectNoInsert, opts.SoftTrim = false, true
+	kept, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, read.Sequence, kept.Sequence)
+
+	opts.SoftTrim = false
+	var stats Stats
+	resultsChan := make(chan *FastqRead, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ProcessBatch([]*FastqRead{read}, opts, resultsChan, &wg, &stats)
+	assert.Equal(t, int64(1), stats.TooShort)
+	assert.Len(t, resultsChan, 0)
+}
+
+func TestTrimReadTrim5PastEnd(t *testing.T) {
+	// A read that is all adapter, shorter than the 5' cut.
+	read := &FastqRead{Header: "@READ1", Sequence: "TGGAATTCTCGG", Quality: strings.Repeat("I", 12)}
+	for _, opts := range []*Options{
+		{Adapter: "TGGAATTCTCGG", Min5Match: 8, Trim5: 15},
+		{Adapter: "TGGAATTCTCGG", Min5Match: 8, UMI5: 15},
+		{Adapter: "TGGAATTCTCGG", Min5Match: 8, UMI3: 3},
+		{Adapter: "TGGAATTCTCGG", Min5Match: 8, Trim5: 15, UMI3: 3},
+	} {
+		var short *FastqRead
+		var err error
+		assert.NotPanics(t, func() { short, err = TrimRead(read, opts) }, "%+v", opts)
+		assert.ErrorIs(t, err, ErrTooShort)
+		if assert.NotNil(t, short) {
+			assert.Equal(t, "", short.Sequence)
+		}
+	}
+}
+
+func TestTrimReadGCRange(t *testing.T) {
+	// The insert left after trimming, 4 of 10 bases G or C: exactly 40%.
+	read := &FastqRead{
+		Header:   "@READ1",
+		Sequence: "AAGCATGCTT" + "TGGAATTCTCGG",
+		Quality:  strings.Repeat("I", 22),
+	}
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 5, Min5Match: 8}
+
+	for _, tc := range []struct {
+		minGC, maxGC float64
+		wantErr      bool
+	}{
+		{40, 100, false},
+		{40.01, 100, true},
+		{0, 40, false},
+		{0, 39.99, true},
+		{40, 40, false},
+		{0, 0, false}, // no upper limit
+	} {
+		opts.MinGC, opts.MaxGC = tc.minGC, tc.maxGC
+		trimmed, err := TrimRead(read, opts)
+		if tc.wantErr {
+			assert.ErrorIs(t, err, ErrGCFiltered, "range [%g, %g]", tc.minGC, tc.maxGC)
+		} else if assert.NoError(t, err, "range [%g, %g]", tc.minGC, tc.maxGC) {
+			assert.Equal(t, "AAGCATGCTT", trimmed.Sequence)
+		}
+	}
+}
+
+func TestGCPercent(t *testing.T) {
+	assert.Equal(t, 0.0, gcPercent(""))
+	assert.Equal(t, 0.0, gcPercent("ATAT"))
+	assert.Equal(t, 50.0, gcPercent("ACGT"))
+	assert.Equal(t, 100.0, gcPercent("GCgc"))
+}
+
+func TestWriteResultsHeaderLen(t *testing.T) {
+	var buf bytes.Buffer
+	resultsChan := make(chan *FastqRead, 1)
+	doneChan := make(chan struct{})
+	var stats Stats
+
+	resultsChan <- &FastqRead{
+		Header:   "@READ1",
+		Sequence: "TCGGAAGAGCACACGTCTGAACTCCAGTC",
+		Quality:  "CFFFFFFHHHHHJJJJJJJJJJJJJJJJJ",
+	}
+	close(resultsChan)
+	go writeResults(bufio.NewWriter(&buf), &Options{HeaderLen: true}, resultsChan, doneChan, &stats)
+	<-doneChan
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "@READ1 len=29", lines[0])
+	assert.Equal(t, "len="+strconv.Itoa(len(lines[1])), strings.Fields(lines[0])[1])
+	assert.Equal(t, int64(1), stats.TotalTrimmedReads)
+}
+
+func TestRecodeQuality(t *testing.T) {
+	phred64 := "@Jhh" // Phred 0, 10, 40, 40
+	phred33 := recodeQuality(phred64, 64, 33)
+	assert.Equal(t, "!+II", phred33)
+	assert.Equal(t, phred64, recodeQuality(phred33, 33, 64), "round trip should be lossless")
+
+	// Phred+33 scores above 62 cannot be represented in Phred+64.
+	assert.Equal(t, "~", recodeQuality("~", 33, 64))
+	// Old Solexa characters below the offset are clamped to zero.
+	assert.Equal(t, "!", recodeQuality(";", 64, 33))
+
+	var buf bytes.Buffer
+	resultsChan := make(chan *FastqRead, 1)
+	doneChan := make(chan struct{})
+	var stats Stats
+	resultsChan <- &FastqRead{Header: "@READ1", Sequence: "ACGT", Quality: phred64}
+	close(resultsChan)
+	go writeResults(bufio.NewWriter(&buf), &Options{InQualBase: 64, OutQualBase: 33}, resultsChan, doneChan, &stats)
+	<-doneChan
+	assert.Equal(t, "@READ1\nACGT\n+\n!+II\n", buf.String())
+}
+
+func TestWriteResultsConstQual(t *testing.T) {
+	var buf bytes.Buffer
+	writer := bufio.NewWriter(&buf)
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 5, Min5Match: 8, MaxError: 0.1, ConstQual: 'I'}
+
+	// The quality filter still sees the real scores.
+	_, err := TrimRead(&FastqRead{Header: "@LOW", Sequence: "ACGTACGT" + "TGGAATTCTCGG", Quality: "########" + "IIIIIIIIIIII"}, opts)
+	assert.ErrorIs(t, err, ErrLowQuality)
+	trimmed, err := TrimRead(&FastqRead{Header: "@READ1", Sequence: "ACGTACGT" + "TGGAATTCTCGG", Quality: "5?5?5?5?" + "IIIIIIIIIIII"}, opts)
+	assert.NoError(t, err)
+
+	resultsChan := make(chan *FastqRead, 1)
+	doneChan := make(chan struct{})
+	resultsChan <- trimmed
+	close(resultsChan)
+	var stats Stats
+	writeResults(writer, opts, resultsChan, doneChan, &stats)
+	<-doneChan
+
+	assert.Equal(t, "@READ1\nACGTACGT\n+\nIIIIIIII\n", buf.String())
+}
+
+func TestWriteResultsCRLF(t *testing.T) {
+	var buf bytes.Buffer
+	resultsChan := make(chan *FastqRead, 1)
+	doneChan := make(chan struct{})
+	var stats Stats
+	resultsChan <- &FastqRead{Header: "@READ1", Sequence: "ACGT", Quality: "IIII"}
+	close(resultsChan)
+	opts := &Options{MinLen: 4, CRLF: true}
+	go writeResults(bufio.NewWriter(&buf), opts, resultsChan, doneChan, &stats)
+	<-doneChan
+	assert.Equal(t, "@READ1\r\nACGT\r\n+\r\nIIII\r\n", buf.String())
+
+	records, err := verifyFastq(&buf, opts)
+	assert.NoError(t, err, "verification accepts CRLF output")
+	assert.Equal(t, int64(1), records)
+}
+
+func TestKeepOriginal(t *testing.T) {
+	read := &FastqRead{
+		Header:   "@READ1 sample=1",
+		Sequence: "GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC",
+		Quality:  "BCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ",
+	}
+	opts := &Options{Adapter: "ATCACG", MinLen: 5, Trim5: 2, Trim3: 2, Min5Match: 4, MaxError: 0.1, KeepOriginal: true}
+	trimmed, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	resultsChan := make(chan *FastqRead, 1)
+	doneChan := make(chan struct{})
+	var stats Stats
+	resultsChan <- trimmed
+	close(resultsChan)
+	go writeResults(bufio.NewWriter(&buf), opts, resultsChan, doneChan, &stats)
+	<-doneChan
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, []string{
+		"@READ1:orig sample=1", read.Sequence, "+", read.Quality,
+		"@READ1 sample=1", "TCGGAAGAGCACACGTCTGAACTCCAGTC", "+", trimmed.Quality,
+	}, lines)
+	assert.Equal(t, int64(1), stats.TotalTrimmedReads, "the pair counts as one kept read")
+	assert.Equal(t, "@R:orig", suffixReadID("@R", originalSuffix))
+}
+
+// Updated test for ProcessReadsFast
+func TestProcessReadsFast(t *testing.T) {
+	// Create test input file
+	inputFile := "test_input.fastq.gz"
+	outputFile := "test_output.fastq.gz"
+
+	f, err := os.Create(inputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw := gzip.NewWriter(f)
+	testReads := []string{
+		"@READ1\n",
+		"GATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC\n",
+		"+\n",
+		"BCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ\n",
+		"@READ2\n",
+		"ATCGATCCGATCGATCGATCGATCGATCGATCGATCGATCGATCGATCGA\n",
+		"+\n",
+		"BCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ\n",
+	}
+
+	for _, read := range testReads {
+		_, err := gw.Write([]byte(read))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gw.Close()
+	f.Close()
+
+	// Process reads
+	err = ProcessReadsFast(inputFile, outputFile, Options{
+		Adapter:      "ATCACG",
+		MinLen:       20,
+		Trim5:        2,
+		Trim3:        2,
+		Min5Match:    4,
+		MaxError:     0.1,
+		VerifyOutput: true,
+	})
+	assert.NoError(t, err)
+
+	// Verify output
+	out, err := os.Open(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	gr, err := gzip.NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gr.Close()
+
+	// Read entire output and verify it's valid FASTQ format
+	scanner := bufio.NewScanner(gr)
+	lineCount := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch lineCount % 4 {
+		case 0:
+			assert.True(t, strings.HasPrefix(line, "@"), "Header line should start with @")
+		case 2:
+			assert.Equal(t, "+", line, "Third line should be +")
+		case 1: // Sequence line
+			assert.True(t, len(line) >= 20, "Sequence should meet minimum length")
+		case 3: // Quality line
+			assert.Equal(t, len(scanner.Text()), len(line), "Quality string should match sequence length")
+		}
+		lineCount++
+	}
+
+	assert.NoError(t, scanner.Err())
+	assert.True(t, lineCount > 0, "Output file should contain data")
+
+	// Cleanup
+	os.Remove(inputFile)
+	os.Remove(outputFile)
+}
+
+func TestProcessReadsFastPlain(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.fastq")
+	outputFile := filepath.Join(dir, "out.fastq")
+	assert.NoError(t, os.WriteFile(inputFile, []byte(
+		"@READ1\nGATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC\n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ\n"), 0644))
+
+	opts := Options{Adapter: "ATCACG", MinLen: 20, Min5Match: 4, MaxError: 0.1, VerifyOutput: true}
+	assert.NoError(t, ProcessReadsFast(inputFile, filepath.Join(dir, "out.fastq.gz"), opts), "plain input, gzip output")
+
+	opts.PlainOutput = true
+	assert.NoError(t, ProcessReadsFast(inputFile, outputFile, opts))
+	data, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "@READ1\nGATCGGAAGAGCACACGTCTGAACTCCAGTCAC\n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJ\n", string(data))
+}
+
+func TestProcessReadsFastEmptyOutput(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Adapter: "ATCACG", MinLen: 18, Min5Match: 4, MaxError: 0.1}
+
+	assertEmptyGzip := func(path string) {
+		f, err := os.Open(path)
+		assert.NoError(t, err)
+		defer f.Close()
+		gr, err := gzip.NewReader(f)
+		assert.NoError(t, err, "output should be a complete gzip stream")
+		data, err := io.ReadAll(gr)
+		assert.NoError(t, err)
+		assert.Empty(t, data)
+	}
+
+	t.Run("AllReadsFiltered", func(t *testing.T) {
+		inputFile := filepath.Join(dir, "filtered.fastq.gz")
+		f, err := os.Create(inputFile)
+		assert.NoError(t, err)
+		gw := gzip.NewWriter(f)
+		gw.Write([]byte("@READ1\nGGGGGGGGGGGGGGGGGGGGGGGGG\n+\nJJJJJJJJJJJJJJJJJJJJJJJJJ\n"))
+		gw.Close()
+		f.Close()
+
+		outputFile := filepath.Join(dir, "filtered_out.fastq.gz")
+		assert.NoError(t, ProcessReadsFast(inputFile, outputFile, opts))
+		assertEmptyGzip(outputFile)
+	})
+
+	t.Run("ZeroByteInput", func(t *testing.T) {
+		inputFile := filepath.Join(dir, "empty.fastq.gz")
+		assert.NoError(t, os.WriteFile(inputFile, nil, 0644))
+		outputFile := filepath.Join(dir, "empty_out.fastq.gz")
+
+		// No reads, not an error, with or without -touchOutput.
+		assert.NoError(t, ProcessReadsFast(inputFile, outputFile, opts))
+		assertEmptyGzip(outputFile)
+
+		touch := opts
+		touch.TouchOutput = true
+		assert.NoError(t, ProcessReadsFast(inputFile, outputFile, touch))
+		assertEmptyGzip(outputFile)
+
+		// Nor is an empty stream, e.g. stdin.
+		stats, err := processStream(strings.NewReader(""), &bytes.Buffer{}, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), stats.TotalReads)
+	})
+}
+
+func TestProcessReadsFastTrailingSpace(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.fastq.gz")
+	outputFile := filepath.Join(dir, "out.fastq.gz")
+
+	f, err := os.Create(inputFile)
+	assert.NoError(t, err)
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte("@READ1\nGATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC\n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ  \n" +
+		"@READ2\nGATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC \n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ\t\n"))
+	gw.Close()
+	f.Close()
+
+	opts := Options{Adapter: "ATCACG", MinLen: 18, Min5Match: 4, MaxError: 0.1}
+	err = ProcessReadsFast(inputFile, outputFile, opts)
+	assert.ErrorContains(t, err, "sequence and quality strings must have the same length")
+
+	opts.TrimTrailingSpace = true
+	opts.VerifyOutput = true
+	assert.NoError(t, ProcessReadsFast(inputFile, outputFile, opts))
+}
+
+func TestProcessStream(t *testing.T) {
+	input := "@READ1\nGATCGGAAGAGCACACGTCTGAACTCCAGTCACATCACGATCTCGTATGC\n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJJFJJ\n" +
+		"@READ2\nATCGATCCGATCGATCGATC\n+\nJJJJJJJJJJJJJJJJJJJJ\n"
+	opts := Options{Adapter: "ATCACG", MinLen: 20, Min5Match: 4, MaxError: 0.1, PlainOutput: true}
+
+	var out bytes.Buffer
+	stats, err := processStream(strings.NewReader(input), &out, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalReads)
+	assert.Equal(t, int64(1), stats.AdapterMissing)
+	assert.Equal(t, "@READ1\nGATCGGAAGAGCACACGTCTGAACTCCAGTCAC\n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJ\n", out.String())
+
+	// Gzipped in, gzipped out.
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(input))
+	assert.NoError(t, gw.Close())
+	out.Reset()
+	opts.PlainOutput = false
+	_, err = processStream(&gz, &out, opts)
+	assert.NoError(t, err)
+	gr, err := gzip.NewReader(&out)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, "@READ1\nGATCGGAAGAGCACACGTCTGAACTCCAGTCAC\n+\nBCCFFFFFFHHHHHJJJJJJJJJJJJJJJJJJJ\n", string(data))
+}
+
+func TestProcessStreamWorkerPool(t *testing.T) {
+	read := "@R\nACGTACGTACGTACGTACGTTGGAATTCTCGG\n+\n" + strings.Repeat("I", 32) + "\n"
+	input := strings.Repeat(read, 50000)
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, BatchSize: 10, Workers: 2}
+
+	// 5000 batches would once have meant as many goroutines.
+	baseline := runtime.NumGoroutine()
+	var peak int64
+	stop := everyInterval(100*time.Microsecond, func() {
+		if n := int64(runtime.NumGoroutine()); n > atomic.LoadInt64(&peak) {
+			atomic.StoreInt64(&peak, n)
+		}
+	})
+	var out bytes.Buffer
+	stats, err := processStream(strings.NewReader(input), &out, opts)
+	stop()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50000), stats.TotalTrimmedReads)
+	assert.LessOrEqual(t, int(atomic.LoadInt64(&peak)), baseline+opts.Workers+4, "workers, writer and ticker only")
+
+	opts.BatchSize, opts.Workers = 0, 0
+	var defaults bytes.Buffer
+	_, err = processStream(strings.NewReader(input), &defaults, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, defaults.String(), out.String())
+}
+
+// cancelAfter cancels its context once n bytes of the input have been read.
+type cancelAfter struct {
+	r      io.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelAfter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if c.n -= n; c.n <= 0 {
+		c.cancel()
+	}
+	return n, err
+}
+
+func TestProcessStreamCancel(t *testing.T) {
+	read := "@R\nACGTACGTACGTACGTACGTTGGAATTCTCGG\n+\n" + strings.Repeat("I", 32) + "\n"
+	input := strings.Repeat(read, 50000)
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, BatchSize: 10, Workers: 2}
+
+	baseline := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	in := &cancelAfter{r: strings.NewReader(input), n: 100 * len(read), cancel: cancel}
+	var out bytes.Buffer
+	stats, err := processStreamCtx(ctx, in, &out, opts)
+	assert.ErrorIs(t, err, context.Canceled)
+	if assert.NotNil(t, stats, "the counters so far come back too") {
+		assert.Greater(t, stats.TotalReads, int64(0))
+		assert.Less(t, stats.TotalReads, int64(50000))
+		assert.Equal(t, int(stats.TotalTrimmedReads), strings.Count(out.String(), "@R\n"), "what was trimmed is written")
+		dropped := stats.AdapterMissing + stats.TooShort + stats.LowQuality + stats.NoInsert +
+			stats.Timeout + stats.UnknownBarcode + stats.GCFiltered + stats.TooManyN +
+			stats.LowBaseQual + stats.TooLong
+		assert.Equal(t, stats.TotalReads, stats.TotalTrimmedReads+dropped, "every read taken in is trimmed or dropped")
+	}
+
+	assertGoroutinesExit(t, baseline)
+}
+
+// assertGoroutinesExit checks that the goroutine count falls back to
+// baseline: the workers and the writer exit, if not necessarily before we
+// look.
+func assertGoroutinesExit(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "workers and writer exit")
+}
+
+func TestProcessStreamReadErrorShutsDown(t *testing.T) {
+	read := "@R\nACGTACGTACGTACGTACGTTGGAATTCTCGG\n+\n" + strings.Repeat("I", 32) + "\n"
+	input := strings.Repeat(read, 25) + "@CUT\nACGT\n"
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, BatchSize: 10, Workers: 2}
+
+	baseline := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		var out bytes.Buffer
+		_, err := processStream(strings.NewReader(input), &out, opts)
+		assert.ErrorContains(t, err, "truncated FASTQ")
+		assert.Equal(t, 25, strings.Count(out.String(), "@R\n"), "the complete records before the error are written")
+	}
+	assertGoroutinesExit(t, baseline)
+}
+
+func TestProcessStreamLongLine(t *testing.T) {
+	insert := strings.Repeat("ACGT", 25000) // 100 kB, past bufio.Scanner's default 64 kB
+	seq := insert + "TGGAATTCTCGG"
+	input := "@LONG\n" + seq + "\n+\n" + strings.Repeat("I", len(seq)) + "\n" +
+		"@NEXT\nACGTACGTACGTTGGAATTCTCGG\n+\nIIIIIIIIIIIIIIIIIIIIIIII\n"
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, Ordered: true}
+
+	var out bytes.Buffer
+	stats, err := processStream(strings.NewReader(input), &out, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalTrimmedReads, "the read after the long one is not lost")
+	assert.True(t, strings.HasPrefix(out.String(), "@LONG\n"+insert+"\n"))
+
+	// -verifyOutput re-reads the long record with the same line limit.
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.fastq")
+	assert.NoError(t, os.WriteFile(inputFile, []byte(input), 0644))
+	verify := opts
+	verify.VerifyOutput = true
+	stats, err = processReads(inputFile, filepath.Join(dir, "out.fastq"), verify)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalTrimmedReads)
+
+	opts.MaxLineLen = 64 * 1024
+	_, err = processStream(strings.NewReader(input), &out, opts)
+	assert.EqualError(t, err, "error reading file: a line is longer than 65536 bytes; raise -maxLineLen")
+}
+
+func TestFastqReaderTruncated(t *testing.T) {
+	for _, tc := range []struct {
+		name, input string
+	}{
+		{"header only", "@R1\nACGT\n+\nIIII\n@R2\n"},
+		{"header without newline", "@R1\nACGT\n+\nIIII\n@R2"},
+		{"no quality", "@R1\nACGT\n+\nIIII\n@R2\nACGT\n+\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			source := newFastqReader(strings.NewReader(tc.input), &Options{})
+			read, err := source.next()
+			assert.NoError(t, err)
+			assert.Equal(t, "@R1", read.Header)
+			_, err = source.next()
+			assert.EqualError(t, err, "truncated FASTQ: record starting at line 5 is incomplete")
+		})
+	}
+
+	var out bytes.Buffer
+	_, err := processStream(strings.NewReader("@R1\n"), &out, Options{Adapter: "TGGAATTCTCGG", Min5Match: 8})
+	assert.EqualError(t, err, "truncated FASTQ: record starting at line 1 is incomplete")
+}
+
+func TestQualityTrim3(t *testing.T) {
+	// 'I' is Q40, '+' Q10 and '#' Q2. A window of four averages the lone
+	// Q2 base at 13 with the good ones before it and keeps it.
+	seq := "ACGTACGTACGTACG"
+	qual := "IIIIIIIIIII+I##"
+
+	for _, tc := range []struct {
+		name           string
+		cutoff, window int
+		want           int
+	}{
+		{"disabled", 0, 4, 15},
+		{"window of one", 20, 1, 13},
+		{"window of four", 20, 4, 14},
+		{"high cutoff", 41, 4, 0},
+		{"window longer than the read", 20, 50, 15},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gotSeq, gotQual := qualityTrim3(seq, qual, tc.cutoff, tc.window)
+			assert.Equal(t, seq[:tc.want], gotSeq)
+			assert.Equal(t, qual[:tc.want], gotQual)
+		})
+	}
+}
+
+func TestTrimReadQualCutoff(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, QualCutoff: 20}
+	read := &FastqRead{
+		Header:   "@TAIL",
+		Sequence: "ACGTACGTACGTGGAATTCTCGG",
+		Quality:  "IIIIIIIIIIII5555555####",
+	}
+
+	kept, err := TrimRead(read, opts)
+	assert.NoError(t, err, "the adapter seed survives the quality trim")
+	assert.Equal(t, "ACGTACGTACG", kept.Sequence)
+
+	opts.QualCutoff = 30
+	_, err = TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing, "the tail holding the adapter is trimmed first")
+}
+
+func TestTrimReadQualCutoffReverseInput(t *testing.T) {
+	// The low-quality bases lead the input, so they only form a 3' tail
+	// to trim once the read is reversed.
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, QualCutoff: 20, ReverseInput: true}
+	read := &FastqRead{
+		Header:   "@TAIL",
+		Sequence: reverseString("ACGTACGTACGTGGAATTCTCGG"),
+		Quality:  "####5555555IIIIIIIIIIII",
+	}
+
+	kept, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "ACGTACGTACG", kept.Sequence)
+
+	opts.QualCutoff = 30
+	_, err = TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrAdapterMissing, "the reversed tail holding the adapter is trimmed first")
+}
+
+func TestTrimPolyTail(t *testing.T) {
+	const insert = "ACGTACGTACGTACGTACGC"
+	for _, tc := range []struct {
+		name, seq, bases string
+		want             string
+	}{
+		{"poly-A", insert + strings.Repeat("A", 15), "A", insert},
+		{"poly-A at the minimum", insert + strings.Repeat("A", 10), "A", insert},
+		{"poly-A too short", insert + strings.Repeat("A", 9), "A", insert + strings.Repeat("A", 9)},
+		{"poly-G", insert + strings.Repeat("G", 30), "G", insert},
+		{"poly-G not asked for", insert + strings.Repeat("G", 30), "A", insert + strings.Repeat("G", 30)},
+		{"poly-G then poly-A", insert + strings.Repeat("G", 12) + strings.Repeat("A", 10), "AG", insert},
+		{"whole read", strings.Repeat("A", 20), "A", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gotSeq, gotQual := trimPolyTail(tc.seq, qualityRamp(len(tc.seq)), tc.bases, 10)
+			assert.Equal(t, tc.want, gotSeq)
+			assert.Equal(t, qualityRamp(len(tc.seq))[:len(gotSeq)], gotQual)
+		})
+	}
+}
+
+func TestProcessStreamPolyTrim(t *testing.T) {
+	input := "@POLYG\nACGTACGTACGTACGTACGC" + strings.Repeat("G", 12) + "TGGAATTCTCGG\n+\n" + strings.Repeat("I", 44) + "\n" +
+		"@PLAIN\nACGTACGTACGTACGTACGCTGGAATTCTCGG\n+\n" + strings.Repeat("I", 32) + "\n" +
+		"@ALLG\nACGTAC" + strings.Repeat("G", 14) + "TGGAATTCTCGG\n+\n" + strings.Repeat("I", 32) + "\n"
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, PlainOutput: true, PolyTrim: "G", Ordered: true}
+
+	var out bytes.Buffer
+	stats, err := processStream(strings.NewReader(input), &out, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalTrimmedReads)
+	assert.Equal(t, int64(1), stats.PolyTrimmed)
+	assert.Equal(t, int64(1), stats.TooShort, "too short once the poly-G is cut")
+	assert.Equal(t, "@POLYG\nACGTACGTACGTACGTACGC\n+\n"+strings.Repeat("I", 20)+"\n"+
+		"@PLAIN\nACGTACGTACGTACGTACGC\n+\n"+strings.Repeat("I", 20)+"\n", out.String())
+}
+
+func TestTrimReadPolyTrimNoInsert(t *testing.T) {
+	read := &FastqRead{Header: "@READ1", Sequence: "AAAATGGAATTCTCGG", Quality: strings.Repeat("I", 16)}
+	for _, opts := range []*Options{
+		{Adapter: "TGGAATTCTCGG", Min5Match: 8, PolyTrim: "A", Trim5: 20},
+		{Adapter: "TGGAATTCTCGG", Min5Match: 8, PolyTrim: "A", UMI5: 20},
+		{Adapter: "TGGAATTCTCGG", Min5Match: 8, PolyTrim: "A", Trim3: 6},
+	} {
+		var err error
+		assert.NotPanics(t, func() { _, err = TrimRead(read, opts) }, "%+v", opts)
+		assert.ErrorIs(t, err, ErrTooShort)
+	}
+}
+
+func TestTrimReadMaxN(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinLen: 5}
+	newRead := func(insert string) *FastqRead {
+		seq := insert + "TGGAATTCTCGG"
+		return &FastqRead{Header: "@R", Sequence: seq, Quality: strings.Repeat("I", len(seq))}
+	}
+	twoN := newRead("ACGTNACGTNACGTACGTAC")   // 2 of 20
+	threeN := newRead("NCGTNACGTNACGTACGTAC") // 3 of 20
+
+	for _, tc := range []struct {
+		name    string
+		maxN    float64
+		dropped []bool // for twoN, threeN
+	}{
+		{"disabled", 0, []bool{false, false}},
+		{"count", 2, []bool{false, true}},
+		{"count of one", 1, []bool{true, true}},
+		{"fraction", 0.1, []bool{false, true}},
+		{"any N", 0.001, []bool{true, true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts.MaxN = tc.maxN
+			for i, read := range []*FastqRead{twoN, threeN} {
+				_, err := TrimRead(read, opts)
+				if tc.dropped[i] {
+					assert.ErrorIs(t, err, ErrTooManyN, read.Sequence)
+				} else {
+					assert.NoError(t, err, read.Sequence)
+				}
+			}
+		})
+	}
+
+	// The count is of the trimmed read: Ns in the adapter do not count.
+	opts.MaxN = 1
+	_, err := TrimRead(newRead("ACGTACGTACGTACGTACGT"+"NNNN"), opts)
+	assert.ErrorIs(t, err, ErrTooManyN)
+	read := &FastqRead{Header: "@R", Sequence: "ACGTACGTACGTACGTACGTTGGAATTCTCGGNNNN", Quality: strings.Repeat("I", 36)}
+	_, err = TrimRead(read, opts)
+	assert.NoError(t, err)
+
+	var stats Stats
+	stats.countDropped(ErrTooManyN)
+	assert.Equal(t, int64(1), stats.TooManyN)
+}
+
+func TestTrimReadMinBaseQual(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinLen: 5, MaxError: 0.1}
+	insert := "ACGTACGTACGTACGTACGT"
+	seq := insert + "TGGAATTCTCGG"
+	quality := []byte(strings.Repeat("I", len(seq)))
+	quality[7] = '+' // a single Q10 base in the insert
+	read := &FastqRead{Header: "@R", Sequence: seq, Quality: string(quality)}
+
+	_, err := TrimRead(read, opts)
+	assert.NoError(t, err, "the mean error alone lets it through")
+
+	opts.MinBaseQual = 20
+	_, err = TrimRead(read, opts)
+	assert.ErrorIs(t, err, ErrLowBaseQual)
+
+	opts.MinBaseQual = 10
+	_, err = TrimRead(read, opts)
+	assert.NoError(t, err, "Q10 is not below 10")
+
+	// Only the trimmed read counts: a poor base in the adapter does not.
+	opts.MinBaseQual = 20
+	quality = []byte(strings.Repeat("I", len(seq)))
+	quality[len(insert)+3] = '#'
+	_, err = TrimRead(&FastqRead{Header: "@R", Sequence: seq, Quality: string(quality)}, opts)
+	assert.NoError(t, err)
+
+	var stats Stats
+	stats.countDropped(ErrLowBaseQual)
+	assert.Equal(t, int64(1), stats.LowBaseQual)
+}
+
+func TestTrimReadMaxLen(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinLen: 5, MaxLen: 20}
+	newRead := func(insert string) *FastqRead {
+		seq := insert + "TGGAATTCTCGG"
+		return &FastqRead{Header: "@R", Sequence: seq, Quality: strings.Repeat("I", len(seq))}
+	}
+
+	trimmed, err := TrimRead(newRead(strings.Repeat("ACGT", 5)), opts)
+	assert.NoError(t, err, "20 bases is within the limit")
+	assert.Len(t, trimmed.Sequence, 20)
+
+	_, err = TrimRead(newRead(strings.Repeat("ACGT", 5)+"A"), opts)
+	assert.ErrorIs(t, err, ErrTooLong, "21 bases is not")
+
+	// The limit applies to the trimmed read, not the raw one.
+	opts.Trim5 = 1
+	_, err = TrimRead(newRead(strings.Repeat("ACGT", 5)+"A"), opts)
+	assert.NoError(t, err)
+
+	var stats Stats
+	stats.countDropped(ErrTooLong)
+	assert.Equal(t, int64(1), stats.TooLong)
+}
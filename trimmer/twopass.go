@@ -0,0 +1,100 @@
+package trimmer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// surveyOptions are opts for the first pass of -twoPass: the same trimming
+// parameters, collecting the length and quality profiles, with every file
+// output switched off.
+func (o Options) surveyOptions() Options {
+	o.TwoPass = false
+	o.InsertPercentiles = true
+	o.QualityDist = os.DevNull // only the in-memory counts are wanted
+	o.TraceFraction, o.TraceFile = 0, ""
+	o.ContaminationProfile = ""
+	o.QCReport = ""
+	o.InsertEndBed = ""
+	o.CountSidecar = false
+	o.VerifyOutput = false
+	o.AnnotateAll = ""
+	o.TooShortOutput = ""
+	o.SplitByAdapter = ""
+	o.InfoFile = ""
+	o.LabelFile = ""
+	o.Discarded = ""
+	o.LengthHist = ""
+	o.Parquet = ""
+	o.StatsBinary = ""
+	o.DiffAgainst, o.DiffReport = "", ""
+	return o
+}
+
+// runTwoPass trims inputFile twice: a survey pass whose output is thrown
+// away and whose profile and recommendations are written to w, then the
+// real run. It returns the counters of both passes.
+func runTwoPass(inputFile, outputFile string, opts Options, w io.Writer) (survey, final *Stats, err error) {
+	surveyOpts := opts.surveyOptions()
+	if survey, err = processReads(inputFile, os.DevNull, surveyOpts); err != nil {
+		return nil, nil, fmt.Errorf("survey pass: %v", err)
+	}
+	writeSurvey(w, survey, &surveyOpts)
+
+	if final, err = processReads(inputFile, outputFile, opts); err != nil {
+		return survey, nil, err
+	}
+	return survey, final, nil
+}
+
+// ProcessReadsTwoPass is ProcessReadsFast preceded by a survey of the input.
+func ProcessReadsTwoPass(inputFile, outputFile string, opts Options) error {
+	startTime := time.Now()
+
+	w := SummaryWriter(outputFile)
+	_, stats, err := runTwoPass(inputFile, outputFile, opts, w)
+	if err != nil {
+		return err
+	}
+
+	return reportRun(w, stats, &opts, time.Since(startTime))
+}
+
+// percentOf is n as a percentage of total, or 0 for an empty total.
+func percentOf(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+// writeSurvey prints the first-pass profile followed by suggested parameter
+// changes.
+func writeSurvey(w io.Writer, stats *Stats, opts *Options) {
+	found := stats.TotalReads - stats.AdapterMissing - stats.Timeout - stats.UnknownBarcode
+	fmt.Fprintf(w, "Survey pass: %s reads\n", Comma(stats.TotalReads))
+	fmt.Fprintf(w, "  Adapter found: %s (%.2f%%)\n", Comma(found), percentOf(found, stats.TotalReads))
+	fmt.Fprintf(w, "  Would keep: %s (%.2f%%)\n", Comma(stats.TotalTrimmedReads), percentOf(stats.TotalTrimmedReads, stats.TotalReads))
+	fmt.Fprintf(w, "  Kept insert lengths: %s\n", stats.InsertSizes)
+	fmt.Fprintf(w, "  Kept base quality: mean Q%.1f, %.2f%% >= Q30\n", stats.QualityDist.mean(), stats.QualityDist.fractionAtLeast(30)*100)
+
+	var advice []string
+	if stats.TotalReads > 0 && percentOf(found, stats.TotalReads) < 50 {
+		advice = append(advice, "the adapter is missing from most reads; check -a and -min5Match")
+	}
+	if found > 0 && percentOf(stats.TooShort, found) > 25 {
+		advice = append(advice, fmt.Sprintf("%.2f%% of reads with an adapter are shorter than -minLen %d; consider lowering it", percentOf(stats.TooShort, found), opts.MinLen))
+	}
+	if opts.qualFilterEnabled() && found > 0 && percentOf(stats.LowQuality, found) > 10 {
+		advice = append(advice, fmt.Sprintf("%.2f%% of reads fail the quality filter; consider raising -maxError from %g", percentOf(stats.LowQuality, found), opts.MaxError))
+	}
+	if len(advice) == 0 {
+		advice = append(advice, "none; the parameters look reasonable for this input")
+	}
+	fmt.Fprintln(w, "Recommendations:")
+	for _, a := range advice {
+		fmt.Fprintf(w, "  - %s\n", a)
+	}
+}
@@ -0,0 +1,45 @@
+package trimmer
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunTwoPass(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.fastq.gz")
+	output := filepath.Join(dir, "out.fastq.gz")
+	writeGzipFile(t, input, ""+
+		"@KEPT1\nACGTACGTACGTACGTACGTTGGAATTCTCGG\n+\nIIIIIIIIIIIIIIIIIIIIIIIIIIIIIIII\n"+
+		"@KEPT2\nCCCCACGTACGTACGTACGTTGGAATTCTCGG\n+\n5555555555555555555555555555555I\n"+
+		"@SHORT\nACGTTGGAATTCTCGG\n+\nIIIIIIIIIIIIIIII\n"+
+		"@MISSING\nACGTACGTACGTACGTACGT\n+\nIIIIIIIIIIIIIIIIIIII\n")
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 10, Min5Match: 8, InQualBase: 33, OutQualBase: 33}
+
+	var report bytes.Buffer
+	survey, final, err := runTwoPass(input, output, opts, &report)
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(4), survey.TotalReads)
+	for _, s := range []*Stats{survey, final} {
+		assert.Equal(t, int64(2), s.TotalTrimmedReads)
+		assert.Equal(t, int64(1), s.TooShort)
+		assert.Equal(t, int64(1), s.AdapterMissing)
+	}
+	assert.Equal(t, survey.TotalReads, final.TotalReads)
+	assert.Nil(t, final.QualityDist, "the survey settings do not leak into the real run")
+
+	var ids []string
+	assert.NoError(t, readFastqGz(output, func(r *FastqRead) { ids = append(ids, r.Header) }))
+	assert.Equal(t, []string{"@KEPT1", "@KEPT2"}, ids)
+
+	out := report.String()
+	assert.Contains(t, out, "Survey pass: 4 reads")
+	assert.Contains(t, out, "Adapter found: 3 (75.00%)")
+	assert.Contains(t, out, "Kept base quality: mean Q30.0, 50.00% >= Q30")
+	assert.True(t, strings.Contains(out, "shorter than -minLen 10"), out)
+}
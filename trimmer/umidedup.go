@@ -0,0 +1,148 @@
+package trimmer
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// errUMIDuplicate is the fate of a kept read that -umiDedup drops as a
+// copy, written as fate=umi-duplicate by -annotateAll.
+var errUMIDuplicate = errors.New("umi duplicate")
+
+// umiTag introduces the UMI in a read's description, e.g. "@READ1 UMI:ACGT".
+const umiTag = "UMI:"
+
+// headerUMI returns the UMI recorded in a read header, or "" if there is
+// none. A "UMI:" field in the description is used first; otherwise the
+// umi_tools convention of appending "_UMI" to the read ID is recognised.
+func headerUMI(header string) string {
+	id, desc := header, ""
+	if i := strings.IndexAny(header, " \t"); i >= 0 {
+		id, desc = header[:i], header[i+1:]
+	}
+	for _, field := range strings.Fields(desc) {
+		if strings.HasPrefix(field, umiTag) {
+			return field[len(umiTag):]
+		}
+	}
+	if i := strings.LastIndexByte(id, '_'); i >= 0 {
+		return id[i+1:]
+	}
+	return ""
+}
+
+// umiKey identifies a molecule: reads sharing both are PCR copies.
+type umiKey struct {
+	umi, sequence string
+}
+
+// umiDeduper keeps, for each UMI and trimmed sequence, the copy with the
+// highest total base quality. Workers add to it concurrently; the
+// survivors are only written once every batch is done. With keepDropped
+// it also holds on to the copies it drops, so their labels and annotations
+// can be written then too.
+type umiDeduper struct {
+	mu          sync.Mutex
+	best        map[umiKey]*FastqRead
+	reads       int64
+	keepDropped bool
+	dropped     []*FastqRead
+}
+
+func newUMIDeduper(keepDropped bool) *umiDeduper {
+	return &umiDeduper{best: make(map[umiKey]*FastqRead), keepDropped: keepDropped}
+}
+
+// add records read, which must carry a UMI.
+func (d *umiDeduper) add(umi string, read *FastqRead) {
+	key := umiKey{umi, read.Sequence}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reads++
+	kept, ok := d.best[key]
+	switch {
+	case !ok:
+		d.best[key] = read
+	case betterCopy(read, kept):
+		d.best[key] = read
+		d.drop(kept)
+	default:
+		d.drop(read)
+	}
+}
+
+func (d *umiDeduper) drop(read *FastqRead) {
+	if d.keepDropped {
+		d.dropped = append(d.dropped, read)
+	}
+}
+
+// droppedCopies returns the reads dropped as copies, in input order; empty
+// unless keepDropped was set.
+func (d *umiDeduper) droppedCopies() []*FastqRead {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sort.Slice(d.dropped, func(i, j int) bool { return d.dropped[i].index < d.dropped[j].index })
+	return d.dropped
+}
+
+// betterCopy reports whether a should replace b as the representative of
+// their molecule. Ties go to the smaller header, so the choice does not
+// depend on which worker got there first.
+func betterCopy(a, b *FastqRead) bool {
+	qa, qb := qualitySum(a.Quality), qualitySum(b.Quality)
+	if qa != qb {
+		return qa > qb
+	}
+	return a.Header < b.Header
+}
+
+func qualitySum(quality string) int {
+	sum := 0
+	for i := 0; i < len(quality); i++ {
+		sum += int(quality[i])
+	}
+	return sum
+}
+
+// representatives returns one read per molecule, ordered by UMI and then
+// sequence.
+func (d *umiDeduper) representatives() []*FastqRead {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	keys := make([]umiKey, 0, len(d.best))
+	for k := range d.best {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].umi != keys[j].umi {
+			return keys[i].umi < keys[j].umi
+		}
+		return keys[i].sequence < keys[j].sequence
+	})
+	reads := make([]*FastqRead, len(keys))
+	for i, k := range keys {
+		reads[i] = d.best[k]
+	}
+	return reads
+}
+
+// duplicates is the number of UMI-carrying reads dropped as copies.
+func (d *umiDeduper) duplicates() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reads - int64(len(d.best))
+}
+
+// rate is the duplicate fraction of the kept reads that carried a UMI.
+func (d *umiDeduper) rate() float64 {
+	d.mu.Lock()
+	reads := d.reads
+	d.mu.Unlock()
+	if reads == 0 {
+		return 0
+	}
+	return float64(d.duplicates()) / float64(reads)
+}
@@ -0,0 +1,140 @@
+package trimmer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/pgzip"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderUMI(t *testing.T) {
+	assert.Equal(t, "ACGT+TTGC", headerUMI("@READ1 UMI:ACGT+TTGC"))
+	assert.Equal(t, "ACGT", headerUMI("@READ1 1:N:0:GGTT UMI:ACGT"))
+	assert.Equal(t, "GATTACA", headerUMI("@M001:1:FC:1:1101:100:200_GATTACA 1:N:0:GGTT"))
+	assert.Equal(t, "", headerUMI("@READ1 1:N:0:GGTT"))
+}
+
+func TestUMIDedup(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", MinLen: 5, Min5Match: 8}
+	read := func(header, insert, qual string) *FastqRead {
+		return &FastqRead{Header: header, Sequence: insert + "TGGAATTCTCGG", Quality: qual + "IIIIIIIIIIII"}
+	}
+	reads := []*FastqRead{
+		read("@A1 UMI:AAAA", "ACGTACGT", "55555555"),
+		read("@A2 UMI:AAAA", "ACGTACGT", "IIIIIIII"), // best copy of AAAA/ACGTACGT
+		read("@A3 UMI:AAAA", "ACGTACGT", "IIII5555"),
+		read("@B1 UMI:CCCC", "ACGTACGT", "55555555"), // same sequence, other UMI
+		read("@C1 UMI:AAAA", "TTTTGGGG", "55555555"), // same UMI, other sequence
+		read("@D1", "ACGTACGT", "55555555"),          // no UMI: passed through
+	}
+
+	var stats Stats
+	stats.UMIDedup = newUMIDeduper(false)
+	resultsChan := make(chan *FastqRead, len(reads))
+	var wg sync.WaitGroup
+	// Split across two batches as the workers would.
+	wg.Add(2)
+	go ProcessBatch(reads[:3], opts, resultsChan, &wg, &stats)
+	go ProcessBatch(reads[3:], opts, resultsChan, &wg, &stats)
+	wg.Wait()
+
+	assert.Len(t, resultsChan, 1, "only the read without a UMI is streamed")
+	assert.Equal(t, "@D1", (<-resultsChan).Header)
+
+	var headers []string
+	for _, r := range stats.UMIDedup.representatives() {
+		headers = append(headers, r.Header)
+		assert.Equal(t, 8, len(r.Sequence), "representatives are trimmed")
+	}
+	assert.Equal(t, []string{"@A2 UMI:AAAA", "@C1 UMI:AAAA", "@B1 UMI:CCCC"}, headers)
+	assert.Equal(t, int64(2), stats.UMIDedup.duplicates())
+	assert.InDelta(t, 2.0/5, stats.UMIDedup.rate(), 1e-9)
+}
+
+func TestUMIDedupCountsAndLabelsCopies(t *testing.T) {
+	var input strings.Builder
+	for _, r := range []struct{ header, insert, qual string }{
+		{"@A1 UMI:AAAA", "ACGTACGT", "55555555"},
+		{"@A2 UMI:AAAA", "ACGTACGT", "IIIIIIII"},
+		{"@A3 UMI:AAAA", "ACGTACGT", "IIII5555"},
+		{"@B1 UMI:CCCC", "ACGTACGT", "55555555"},
+		{"@D1", "ACGTACGT", "55555555"},
+		{"@S1 UMI:GGGG", "ACG", "III"},
+	} {
+		input.WriteString(r.header + "\n" + r.insert + "TGGAATTCTCGG\n+\n" + r.qual + "IIIIIIIIIIII\n")
+	}
+	dir := t.TempDir()
+	opts := Options{Adapter: "TGGAATTCTCGG", MinLen: 5, Min5Match: 8, PlainOutput: true, UMIDedup: true,
+		LabelFile: filepath.Join(dir, "labels.bin"), AnnotateAll: filepath.Join(dir, "annotated.fastq.gz")}
+
+	var out bytes.Buffer
+	stats, err := processStream(strings.NewReader(input.String()), &out, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.UMIDuplicates)
+	assert.Equal(t, int64(3), stats.TotalTrimmedReads)
+	assert.Equal(t, stats.TotalReads, stats.TotalTrimmedReads+stats.TooShort+stats.UMIDuplicates, "every read is accounted for")
+	stages := funnelStages(stats, &opts)
+	assert.Equal(t, funnelStage{"UMI copy kept", 3}, stages[len(stages)-1])
+
+	labels, err := os.ReadFile(opts.LabelFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{labelUMIDuplicate, labelKept, labelUMIDuplicate, labelKept, labelKept, labelTooShort}, labels)
+
+	f, err := os.Open(opts.AnnotateAll)
+	assert.NoError(t, err)
+	defer f.Close()
+	gr, err := pgzip.NewReader(f)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	var headers []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "@") {
+			headers = append(headers, line)
+		}
+	}
+	sort.Strings(headers)
+	assert.Equal(t, []string{
+		"@A1 UMI:AAAA fate=umi-duplicate",
+		"@A2 UMI:AAAA fate=kept",
+		"@A3 UMI:AAAA fate=umi-duplicate",
+		"@B1 UMI:CCCC fate=kept",
+		"@D1 fate=kept",
+		"@S1 UMI:GGGG fate=too-short",
+	}, headers, "each read is annotated once, copies with their own fate")
+}
+
+func TestBetterCopyTieBreak(t *testing.T) {
+	a := &FastqRead{Header: "@A", Quality: "II"}
+	b := &FastqRead{Header: "@B", Quality: "II"}
+	assert.True(t, betterCopy(a, b))
+	assert.False(t, betterCopy(b, a))
+}
+
+func TestTrimReadExtractUMI(t *testing.T) {
+	opts := &Options{Adapter: "TGGAATTCTCGG", Min5Match: 8, MinLen: 10, UMI5: 4, UMI3: 4, Trim5: 1, PlainOutput: true}
+	read := &FastqRead{
+		Header:   "@READ1",
+		Sequence: "ACGT" + "N" + "CCCCGGGGCCCCGGGG" + "TTGC" + "TGGAATTCTCGG",
+		Quality:  "1234" + "#" + "IIIIIIIIIIIIIIII" + "5678" + "IIIIIIIIIIII",
+	}
+
+	trimmed, err := TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "CCCCGGGGCCCCGGGG", trimmed.Sequence, "UMI and -trim5 bases are removed")
+	assert.Equal(t, "IIIIIIIIIIIIIIII", trimmed.Quality)
+	assert.Equal(t, "@READ1 UMI:ACGT+TTGC", outputHeader(trimmed, opts))
+	assert.Equal(t, "ACGT+TTGC", headerUMI(outputHeader(trimmed, opts)), "-umiDedup reads it back")
+
+	opts.UMI3 = 0
+	trimmed, err = TrimRead(read, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "@READ1 UMI:ACGT", outputHeader(trimmed, opts))
+}
@@ -0,0 +1,78 @@
+package trimmer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// verifyOutput re-reads a written output file and checks that every record
+// is well-formed FASTQ with matching sequence and quality lengths and a
+// sequence that satisfies the length constraints: MinLen, and MaxLen or a
+// shorter TruncateTo. The untrimmed -keepOriginal copies are exempt from
+// the upper bound. It returns the number of
+// records found.
+func verifyOutput(path string, opts *Options) (int64, error) {
+	r, err := openMaybeCompressed(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	return verifyFastq(r, opts)
+}
+
+func verifyFastq(r io.Reader, opts *Options) (int64, error) {
+	scanner := newLineScanner(r, opts.maxLineLen())
+	maxLen := opts.MaxLen
+	if opts.TruncateTo > 0 && (maxLen == 0 || opts.TruncateTo < maxLen) {
+		maxLen = opts.TruncateTo
+	}
+	var records int64
+	line := 0
+	next := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		line++
+		if opts.CRLF {
+			return strings.TrimSuffix(scanner.Text(), "\r"), true
+		}
+		return scanner.Text(), true
+	}
+
+	for {
+		header, ok := next()
+		if !ok {
+			break
+		}
+		start := line
+		if !strings.HasPrefix(header, "@") {
+			return records, fmt.Errorf("line %d: expected header starting with '@', got: %s", start, header)
+		}
+		sequence, ok1 := next()
+		plus, ok2 := next()
+		quality, ok3 := next()
+		if !ok1 || !ok2 || !ok3 {
+			return records, fmt.Errorf("record starting at line %d is incomplete", start)
+		}
+		if !strings.HasPrefix(plus, "+") {
+			return records, fmt.Errorf("line %d: expected '+' line, got: %s", start+2, plus)
+		}
+		if len(sequence) != len(quality) {
+			return records, fmt.Errorf("record starting at line %d: sequence and quality lengths differ (%d and %d)", start, len(sequence), len(quality))
+		}
+		if len(sequence) < opts.MinLen {
+			return records, fmt.Errorf("record starting at line %d: length %d is below minLen %d", start, len(sequence), opts.MinLen)
+		}
+		original := opts.KeepOriginal && strings.HasSuffix(readID(header), originalSuffix)
+		if maxLen > 0 && len(sequence) > maxLen && !original {
+			return records, fmt.Errorf("record starting at line %d: length %d is above the %d base limit", start, len(sequence), maxLen)
+		}
+		records++
+	}
+	if err := scanner.Err(); err != nil {
+		return records, err
+	}
+	return records, nil
+}
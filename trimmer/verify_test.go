@@ -0,0 +1,53 @@
+package trimmer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyFastq(t *testing.T) {
+	opts := &Options{MinLen: 4}
+	good := "@R1\nACGTAC\n+\nJJJJJJ\n@R2\nACGT\n+\nJJJJ\n"
+
+	n, err := verifyFastq(strings.NewReader(good), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "QualityTruncated", data: "@R1\nACGTAC\n+\nJJJJJ\n", wantErr: "sequence and quality lengths differ"},
+		{name: "MissingPlus", data: "@R1\nACGTAC\nJJJJJJ\n+\n", wantErr: "expected '+' line"},
+		{name: "BadHeader", data: good + "R3\nACGT\n+\nJJJJ\n", wantErr: "line 9: expected header"},
+		{name: "TooShort", data: "@R1\nACG\n+\nJJJ\n", wantErr: "below minLen"},
+		{name: "Incomplete", data: "@R1\nACGT\n", wantErr: "incomplete"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := verifyFastq(strings.NewReader(tc.data), opts)
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestVerifyFastqMaxLen(t *testing.T) {
+	data := "@R1\nACGT\n+\nJJJJ\n@R2\nACGTAC\n+\nJJJJJJ\n"
+
+	for _, opts := range []*Options{{MaxLen: 5}, {TruncateTo: 5}, {MaxLen: 8, TruncateTo: 5}} {
+		_, err := verifyFastq(strings.NewReader(data), opts)
+		assert.EqualError(t, err, "record starting at line 5: length 6 is above the 5 base limit")
+	}
+	n, err := verifyFastq(strings.NewReader(data), &Options{MaxLen: 6})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	// The untrimmed -keepOriginal copy may be longer.
+	orig := "@R1\nACGT\n+\nJJJJ\n@R1:orig extra\nACGTAC\n+\nJJJJJJ\n"
+	n, err = verifyFastq(strings.NewReader(orig), &Options{MaxLen: 5, KeepOriginal: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}